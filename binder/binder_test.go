@@ -3,6 +3,7 @@ package binder_test
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/uaxe/infra/binder"
 )
@@ -26,3 +27,21 @@ func ExampleHeader() {
 	// "application/json"
 	// ""
 }
+
+type DstQuery struct {
+	Page int      `query:"page"`
+	Tags []string `query:"tag"`
+}
+
+func ExampleQuery() {
+	q, _ := url.ParseQuery("page=2&tag=a&tag=b")
+	var dst DstQuery
+	err := binder.Query.Binding(q, &dst)
+	fmt.Printf("%#v\n", err)
+	fmt.Printf("%#v\n", dst.Page)
+	fmt.Printf("%#v\n", dst.Tags)
+	// Output:
+	// <nil>
+	// 2
+	// []string{"a", "b"}
+}
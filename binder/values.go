@@ -0,0 +1,52 @@
+package binder
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/uaxe/infra/zreflect"
+)
+
+var (
+	_     Binder = (*values)(nil)
+	Query        = &values{tag: "query"}
+	Form         = &values{tag: "form"}
+	Path         = &values{tag: "path"}
+)
+
+// values binds url.Values or a map[string]string to the fields tagged with
+// its tag, the strings being parsed to the types of the fields.
+type values struct {
+	tag string
+}
+
+func (v *values) Name() string {
+	return v.tag
+}
+
+func (v *values) Binding(src any, dst any) error {
+	switch x := src.(type) {
+	case url.Values:
+		return zreflect.MapBindStruct(ValuesMap(x), dst, v.Name())
+	case map[string]string:
+		m := make(map[string]any, len(x))
+		for k, val := range x {
+			m[strings.ToLower(k)] = val
+		}
+		return zreflect.MapBindStruct(m, dst, v.Name())
+	case map[string]any:
+		return zreflect.MapBindStruct(x, dst, v.Name())
+	default:
+		return errors.New(`src not url values`)
+	}
+}
+
+// ValuesMap lowercases the keys of vs, keeping every value of a key.
+func ValuesMap(vs url.Values) map[string]any {
+	m := make(map[string]any, len(vs))
+	for k, v := range vs {
+		m[strings.ToLower(k)] = v
+	}
+	return m
+}
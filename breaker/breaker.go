@@ -6,7 +6,8 @@ import (
 	"log"
 	"strings"
 	"sync"
-	"time"
+
+	"github.com/uaxe/infra/clock"
 )
 
 const (
@@ -46,7 +47,8 @@ type (
 	}
 
 	circuitBreaker struct {
-		name string
+		name  string
+		clock clock.Clock
 		throttle
 	}
 
@@ -62,14 +64,14 @@ type (
 )
 
 func NewBreaker(opts ...Option) Breaker {
-	var b circuitBreaker
+	b := circuitBreaker{clock: clock.Real}
 	for _, opt := range opts {
 		opt(&b)
 	}
 	if len(b.name) == 0 {
-		b.name = time.Now().Format(timeFormat)
+		b.name = b.clock.Now().Format(timeFormat)
 	}
-	b.throttle = newLoggedThrottle(b.name, newGoogleBreaker())
+	b.throttle = newLoggedThrottle(b.name, newGoogleBreaker(b.clock), b.clock)
 	return &b
 }
 
@@ -108,17 +110,24 @@ func WithName(name string) Option {
 	}
 }
 
+// WithClock lets the breaker measure its window by c.
+func WithClock(c clock.Clock) Option {
+	return func(b *circuitBreaker) {
+		b.clock = c
+	}
+}
+
 type loggedThrottle struct {
 	name string
 	internalThrottle
 	errWin *errorWindow
 }
 
-func newLoggedThrottle(name string, t internalThrottle) loggedThrottle {
+func newLoggedThrottle(name string, t internalThrottle, clk clock.Clock) loggedThrottle {
 	return loggedThrottle{
 		name:             name,
 		internalThrottle: t,
-		errWin:           new(errorWindow),
+		errWin:           &errorWindow{clock: clk},
 	}
 }
 
@@ -166,11 +175,12 @@ type errorWindow struct {
 	index   int
 	count   int
 	lock    sync.Mutex
+	clock   clock.Clock
 }
 
 func (ew *errorWindow) add(reason string) {
 	ew.lock.Lock()
-	ew.reasons[ew.index] = fmt.Sprintf("%s %s", Time().Format(timeFormat), reason)
+	ew.reasons[ew.index] = fmt.Sprintf("%s %s", ew.clock.Now().Format(timeFormat), reason)
 	ew.index = (ew.index + 1) % numHistoryReasons
 	ew.count = MinInt(ew.count+1, numHistoryReasons)
 	ew.lock.Unlock()
@@ -1,7 +1,12 @@
 package breaker
 
 import (
+	"errors"
 	"fmt"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/clock"
 )
 
 func ExampleBreaker() {
@@ -13,3 +18,53 @@ func ExampleBreaker() {
 	// true
 	// <nil>
 }
+
+func TestRollingWindowClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	rw := NewRollingWindow(4, time.Second, WithWindowClock(fake))
+	sum := func() (s float64) {
+		rw.Reduce(func(b *Bucket) { s += b.Sum })
+		return
+	}
+
+	rw.Add(1)
+	fake.Add(time.Second)
+	rw.Add(2)
+	fake.Add(time.Second)
+	rw.Add(3)
+	if s := sum(); s != 6 {
+		t.Fatalf("sum %v", s)
+	}
+
+	// the first two buckets expire
+	fake.Add(3 * time.Second)
+	if s := sum(); s != 3 {
+		t.Fatalf("sum %v", s)
+	}
+	fake.Add(time.Minute)
+	if s := sum(); s != 0 {
+		t.Fatalf("sum %v", s)
+	}
+}
+
+func TestBreakerClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	b := NewBreaker(WithName("clock"), WithClock(fake))
+	fail := errors.New("fail")
+
+	opened := false
+	for i := 0; i < 1000 && !opened; i++ {
+		if err := b.Do(func() error { return fail }); errors.Is(err, ErrServiceUnavailable) {
+			opened = true
+		}
+	}
+	if !opened {
+		t.Fatal("breaker should open after failures")
+	}
+
+	// once the window has passed the history is gone and requests are allowed
+	fake.Add(windowDuration + time.Second)
+	if _, err := b.Allow(); err != nil {
+		t.Fatal(err)
+	}
+}
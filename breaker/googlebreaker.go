@@ -3,6 +3,8 @@ package breaker
 import (
 	"math"
 	"time"
+
+	"github.com/uaxe/infra/clock"
 )
 
 const (
@@ -21,9 +23,9 @@ type googleBreaker struct {
 	proba *Proba
 }
 
-func newGoogleBreaker() *googleBreaker {
+func newGoogleBreaker(clk clock.Clock) *googleBreaker {
 	bucketDuration := time.Duration(int64(windowDuration) / int64(buckets))
-	st := NewRollingWindow(buckets, bucketDuration)
+	st := NewRollingWindow(buckets, bucketDuration, WithWindowClock(clk))
 	return &googleBreaker{
 		stat:  st,
 		k:     k,
@@ -3,6 +3,8 @@ package breaker
 import (
 	"sync"
 	"time"
+
+	"github.com/uaxe/infra/clock"
 )
 
 type (
@@ -18,6 +20,7 @@ type (
 		offset        int
 		ignoreCurrent bool
 		lastTime      time.Duration // start time of the last bucket
+		clock         clock.Clock
 	}
 )
 
@@ -32,11 +35,12 @@ func NewRollingWindow(size int, interval time.Duration, opts ...RollingWindowOpt
 		size:     size,
 		win:      newWindow(size),
 		interval: interval,
-		lastTime: Now(),
+		clock:    clock.Real,
 	}
 	for _, opt := range opts {
 		opt(w)
 	}
+	w.lastTime = w.now()
 	return w
 }
 
@@ -68,7 +72,7 @@ func (rw *RollingWindow) Reduce(fn func(b *Bucket)) {
 }
 
 func (rw *RollingWindow) span() int {
-	offset := int(Since(rw.lastTime) / rw.interval)
+	offset := int((rw.now() - rw.lastTime) / rw.interval)
 	if 0 <= offset && offset < rw.size {
 		return offset
 	}
@@ -99,11 +103,15 @@ func (rw *RollingWindow) updateOffset() {
 	}
 
 	rw.offset = (offset + span) % rw.size
-	now := Now()
+	now := rw.now()
 	// align to interval time boundary
 	rw.lastTime = now - (now-rw.lastTime)%rw.interval
 }
 
+func (rw *RollingWindow) now() time.Duration {
+	return rw.clock.Now().Sub(initTime)
+}
+
 // Bucket defines the bucket that holds sum and num of additions.
 type Bucket struct {
 	Sum   float64
@@ -156,3 +164,10 @@ func IgnoreCurrentBucket() RollingWindowOption {
 		w.ignoreCurrent = true
 	}
 }
+
+// WithWindowClock lets the RollingWindow measure time by c.
+func WithWindowClock(c clock.Clock) RollingWindowOption {
+	return func(w *RollingWindow) {
+		w.clock = c
+	}
+}
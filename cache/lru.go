@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/uaxe/infra/clock"
 )
 
 // Package lru implements an LRU c.
@@ -27,6 +29,8 @@ type Cache struct {
 	evictedCache *sync.Map
 
 	evictedRate int
+
+	clock clock.Clock
 }
 
 type entry struct {
@@ -36,6 +40,13 @@ type entry struct {
 
 type Option func(c *Cache)
 
+// SetClock drives the eviction loop by clk instead of the real time.
+func SetClock(clk clock.Clock) Option {
+	return func(c *Cache) {
+		c.clock = clk
+	}
+}
+
 func SetEvictedRate(rate int) Option {
 	return func(c *Cache) {
 		c.evictedRate = rate
@@ -52,6 +63,7 @@ func New(maxEntries int, opts ...Option) *Cache {
 		cache:        make(map[any]*list.Element),
 		evictedCache: &sync.Map{},
 		evictedRate:  maxEntries / 1000,
+		clock:        clock.Real,
 	}
 	if c.evictedRate < 5000 {
 		c.evictedRate = 5000
@@ -83,7 +95,7 @@ func (c *Cache) evicted() {
 				}()
 				return true
 			})
-			time.Sleep(100 * time.Millisecond)
+			c.clock.Sleep(100 * time.Millisecond)
 		}
 	}()
 }
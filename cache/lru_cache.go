@@ -9,7 +9,7 @@ import (
 )
 
 type Entry struct {
-	Timerid uint32
+	Timerid uint64
 	Value   any
 }
 
@@ -29,9 +29,10 @@ func NewLRUCache(
 	ctx context.Context,
 	maxcapacity int,
 	expiredTw *schedule.TimerWheel,
-	OnEvicted func(k, v any)) *LRUCache {
+	OnEvicted func(k, v any),
+	opts ...Option) *LRUCache {
 
-	c := New(maxcapacity)
+	c := New(maxcapacity, opts...)
 	c.OnEvicted = func(key, value any) {
 		vv := value.(Entry)
 		if OnEvicted != nil {
@@ -78,7 +79,7 @@ func (l *LRUCache) Put(key, v any, ttl time.Duration) chan time.Time {
 			}
 			timerid, ch := l.tw.AddTimer(ttl, func(_ time.Time) {
 				l.cache.Remove(key)
-			}, func(_ time.Time) {})
+			}, nil)
 			vv.Timerid = timerid
 			ttlChan = ch
 		}
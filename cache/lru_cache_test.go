@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/uaxe/infra/cache"
+	"github.com/uaxe/infra/clock"
 	"github.com/uaxe/infra/schedule"
 )
 
@@ -93,3 +95,53 @@ func BenchmarkLRUCache_Put(pb *testing.B) {
 		}
 	})
 }
+
+func BenchmarkLRUCache_PutTTL(b *testing.B) {
+	tw := schedule.NewTimerWheel(100*time.Millisecond, 100)
+	defer tw.Stop()
+	lru := cache.NewLRUCache(context.TODO(), 2000000, tw, nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		uid := strconv.Itoa(i % 1000000)
+		lru.Put(uid, Demo{Uid: uid}, time.Duration(i%3600+1)*time.Second)
+	}
+}
+
+func TestLRUCache_TTLClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC))
+	tw := schedule.NewTimerWheel(100*time.Millisecond, 10, schedule.WithWheelClock(fake))
+	defer tw.Stop()
+	evicted := make(chan any, 1)
+	lru := cache.NewLRUCache(context.TODO(), 10, tw, func(k, v any) {
+		evicted <- k
+	}, cache.SetClock(fake))
+
+	expired := lru.Put("100777", Demo{Uid: "100777"}, 10*time.Second)
+	fake.Add(9 * time.Second)
+	if !lru.Contains("100777") {
+		t.FailNow()
+	}
+
+	fake.Add(time.Second + 100*time.Millisecond)
+	<-expired
+	if lru.Contains("100777") {
+		t.FailNow()
+	}
+
+	// evictions are reported by a loop sleeping on the clock
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case k := <-evicted:
+			if k != "100777" {
+				t.Fatalf("evicted %v", k)
+			}
+			return
+		case <-deadline:
+			t.Fatal("eviction not reported")
+		case <-time.After(time.Millisecond):
+			fake.Add(100 * time.Millisecond)
+		}
+	}
+}
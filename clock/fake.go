@@ -0,0 +1,181 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+var _ Clock = (*Fake)(nil)
+
+// Fake is a manually advanced Clock. Timers and tickers created from it
+// fire only when Add or Set moves the time past their deadline.
+type Fake struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	clock  *Fake
+	until  time.Time
+	period time.Duration
+	c      chan time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.lock)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	w := &fakeWaiter{clock: f, c: make(chan time.Time, 1)}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.schedule(w, d)
+	return w
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	w := &fakeWaiter{clock: f, period: d, c: make(chan time.Time, 1)}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.schedule(w, d)
+	return fakeTicker{w}
+}
+
+// Add advances the clock by d, firing every timer and ticker that falls due
+// in order of their deadlines.
+func (f *Fake) Add(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Set moves the clock forward to t, firing everything due on the way.
+func (f *Fake) Set(t time.Time) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for len(f.waiters) > 0 && !f.waiters[0].until.After(t) {
+		w := f.waiters[0]
+		f.now = w.until
+		f.remove(w)
+		select {
+		case w.c <- f.now:
+		default:
+		}
+		if w.period > 0 {
+			f.schedule(w, w.period)
+		}
+	}
+	if t.After(f.now) {
+		f.now = t
+	}
+}
+
+// Waiters returns the number of pending timers and tickers.
+func (f *Fake) Waiters() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil blocks until at least n timers or tickers are pending, which
+// lets tests wait for the code under test to arm its next timer.
+func (f *Fake) BlockUntil(n int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+func (f *Fake) schedule(w *fakeWaiter, d time.Duration) {
+	w.until = f.now.Add(d)
+	if d <= 0 {
+		select {
+		case w.c <- f.now:
+		default:
+		}
+		return
+	}
+	i := sort.Search(len(f.waiters), func(i int) bool {
+		return f.waiters[i].until.After(w.until)
+	})
+	f.waiters = append(f.waiters, nil)
+	copy(f.waiters[i+1:], f.waiters[i:])
+	f.waiters[i] = w
+	f.cond.Broadcast()
+}
+
+func (f *Fake) remove(w *fakeWaiter) bool {
+	for i := range f.waiters {
+		if f.waiters[i] == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.clock.lock.Lock()
+	defer w.clock.lock.Unlock()
+	return w.clock.remove(w)
+}
+
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	w.clock.lock.Lock()
+	defer w.clock.lock.Unlock()
+	active := w.clock.remove(w)
+	w.clock.schedule(w, d)
+	return active
+}
+
+type fakeTicker struct {
+	w *fakeWaiter
+}
+
+func (t fakeTicker) C() <-chan time.Time {
+	return t.w.c
+}
+
+func (t fakeTicker) Stop() {
+	t.w.Stop()
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.w.clock.lock.Lock()
+	defer t.w.clock.lock.Unlock()
+	t.w.period = d
+	t.w.clock.remove(t.w)
+	t.w.clock.schedule(t.w, d)
+}
@@ -0,0 +1,52 @@
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/clock"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+
+	timer := fake.NewTimer(2 * time.Second)
+	ticker := fake.NewTicker(time.Second)
+	stopped := fake.NewTimer(time.Second)
+	if !stopped.Stop() || fake.Waiters() != 2 {
+		t.Fatalf("waiters %d", fake.Waiters())
+	}
+
+	fake.Add(time.Second)
+	if now := <-ticker.C(); !now.Equal(start.Add(time.Second)) {
+		t.Fatalf("tick at %v", now)
+	}
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	fake.Add(1500 * time.Millisecond)
+	if now := <-timer.C(); !now.Equal(start.Add(2 * time.Second)) {
+		t.Fatalf("timer at %v", now)
+	}
+	if now := fake.Now(); !now.Equal(start.Add(2500 * time.Millisecond)) {
+		t.Fatalf("now %v", now)
+	}
+
+	ticker.Stop()
+	if fake.Waiters() != 0 {
+		t.Fatalf("waiters %d", fake.Waiters())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		fake.Sleep(time.Minute)
+		close(done)
+	}()
+	fake.BlockUntil(1)
+	fake.Add(time.Minute)
+	<-done
+}
@@ -4,8 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"runtime/debug"
 	"sync"
+
+	"github.com/uaxe/infra/threading"
 )
 
 type WorkUnit struct {
@@ -15,6 +16,10 @@ type WorkUnit struct {
 	work  WorkFunc
 	Value any
 	Err   error
+
+	queue    string
+	priority int
+	ready    chan struct{}
 }
 
 func (wu *WorkUnit) Get() (any, error) {
@@ -42,19 +47,66 @@ func (wu *WorkUnit) Error(err error) {
 
 type WorkFunc func(ctx context.Context) (any, error)
 
+const DefaultQueue = "default"
+
+// vtimeScale is the virtual time a queue of weight 1 is charged per task.
+const vtimeScale = 1 << 20
+
+type QueueOption func(wu *WorkUnit)
+
+// OnQueue sends the work to the named sub-queue, DefaultQueue otherwise.
+func OnQueue(name string) QueueOption {
+	return func(wu *WorkUnit) {
+		wu.queue = name
+	}
+}
+
+// WithPriority sets the priority level of the work, 0 by default. Waiting
+// work of a higher level is always started first.
+func WithPriority(priority int) QueueOption {
+	return func(wu *WorkUnit) {
+		wu.priority = priority
+	}
+}
+
+// QueueStats is the breakdown of a sub-queue.
+type QueueStats struct {
+	Weight    int
+	Waiting   int
+	Running   int
+	Completed uint64
+}
+
+type subQueue struct {
+	weight    int
+	vtime     uint64
+	levels    map[int][]*WorkUnit
+	waiting   int
+	running   int
+	completed uint64
+}
+
+// GPool runs work on at most capacity goroutines. Waiting work is started
+// by priority level first, then by weighted fair queuing across the named
+// sub-queues, so that a busy sub-queue cannot starve the others.
 type GPool struct {
-	limiter chan any
-	ctx     context.Context
-	cancel  context.CancelFunc
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock     sync.Mutex
+	capacity int
+	running  int
+	vclock   uint64
+	queues   map[string]*subQueue
 }
 
 func NewLimitPool(ctx context.Context, maxcapacity int) *GPool {
-	limiter := make(chan any, maxcapacity)
 	ctx, cancel := context.WithCancel(ctx)
 	return &GPool{
-		ctx:     ctx,
-		cancel:  cancel,
-		limiter: limiter,
+		ctx:      ctx,
+		cancel:   cancel,
+		capacity: maxcapacity,
+		queues:   make(map[string]*subQueue, 4),
 	}
 }
 
@@ -63,68 +115,244 @@ var (
 	ErrQueueContextDone = errors.New("context is done")
 )
 
-func (p *GPool) Queue(ctx context.Context, work WorkFunc) (*WorkUnit, error) {
+func (p *GPool) Queue(ctx context.Context, work WorkFunc, opts ...QueueOption) (*WorkUnit, error) {
 	wu := &WorkUnit{ch: make(chan *any, 1), work: work, ctx: ctx}
+	for _, opt := range opts {
+		opt(wu)
+	}
 	return wu, p.queue(wu)
 }
 
+// Resize changes the capacity of the pool. Shrinking does not interrupt
+// running work, new work waits until enough of it completed.
+func (p *GPool) Resize(capacity int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.capacity = capacity
+	p.dispatch()
+}
+
+// SetQueueWeight sets the share of the capacity given to the named sub-queue
+// when several are waiting, 1 by default.
+func (p *GPool) SetQueueWeight(name string, weight int) {
+	if weight <= 0 {
+		weight = 1
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.subQueue(name).weight = weight
+}
+
 func (p *GPool) queue(wu *WorkUnit) error {
+	if wu.queue == "" {
+		wu.queue = DefaultQueue
+	}
+	wu.ready = make(chan struct{})
+
+	p.lock.Lock()
+	q := p.subQueue(wu.queue)
+	if q.waiting == 0 && q.vtime < p.vclock {
+		// an idle queue does not bank credit for the time it was idle
+		q.vtime = p.vclock
+	}
+	q.levels[wu.priority] = append(q.levels[wu.priority], wu)
+	q.waiting++
+	p.dispatch()
+	p.lock.Unlock()
+
 	select {
+	case <-wu.ready:
 	case <-wu.ctx.Done():
-		wu.Error(ErrQueueContextDone)
+		p.abandon(wu)
 		return wu.Err
-	case p.limiter <- nil:
-		go func() {
-			defer func() {
-				<-p.limiter
-				if err := recover(); nil != err {
-					fmt.Println("handler", "GPool|Queue|Panic|%v|%s", err, string(debug.Stack()))
-					wu.Error(fmt.Errorf("%v", err))
-				}
-			}()
-
-			select {
-			case <-p.ctx.Done():
-				wu.Error(ErrQueueContextDone)
-				return
-			case <-wu.ctx.Done():
-				wu.Error(ErrQueueContextDone)
-				return
-			default:
-			}
-			val, err := wu.work(wu.ctx)
-			if nil != err {
-				wu.Error(err)
-			} else {
-				wu.AttachValue(val)
-			}
-		}()
 	case <-p.ctx.Done():
-		wu.Error(ErrQueueContextDone)
+		p.abandon(wu)
 		return wu.Err
 	}
+
+	go func() {
+		defer func() {
+			p.release(wu)
+			if err := recover(); nil != err {
+				threading.ReportPanic("pool.GPool", err)
+				wu.Error(fmt.Errorf("%v", err))
+			}
+		}()
+
+		select {
+		case <-p.ctx.Done():
+			wu.Error(ErrQueueContextDone)
+			return
+		case <-wu.ctx.Done():
+			wu.Error(ErrQueueContextDone)
+			return
+		default:
+		}
+		val, err := wu.work(wu.ctx)
+		if nil != err {
+			wu.Error(err)
+		} else {
+			wu.AttachValue(val)
+		}
+	}()
 	return nil
 }
 
+// abandon withdraws waiting work whose context is done. Work started in the
+// meantime gives its slot back.
+func (p *GPool) abandon(wu *WorkUnit) {
+	p.lock.Lock()
+	started := true
+	q := p.queues[wu.queue]
+	level := q.levels[wu.priority]
+	for i := range level {
+		if level[i] == wu {
+			q.levels[wu.priority] = append(level[:i], level[i+1:]...)
+			q.waiting--
+			started = false
+			break
+		}
+	}
+	p.lock.Unlock()
+	if started {
+		p.release(wu)
+	}
+	wu.Error(ErrQueueContextDone)
+}
+
+func (p *GPool) release(wu *WorkUnit) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	q := p.queues[wu.queue]
+	q.running--
+	q.completed++
+	p.running--
+	p.dispatch()
+}
+
+// dispatch starts waiting work while there is capacity left, p.lock must be held.
+func (p *GPool) dispatch() {
+	for p.running < p.capacity {
+		var (
+			next     *subQueue
+			priority int
+		)
+		for _, q := range p.queues {
+			for level, waiting := range q.levels {
+				if len(waiting) == 0 {
+					continue
+				}
+				if next == nil || level > priority || (level == priority && q.vtime < next.vtime) {
+					next, priority = q, level
+				}
+			}
+		}
+		if next == nil {
+			return
+		}
+
+		wu := next.levels[priority][0]
+		next.levels[priority] = next.levels[priority][1:]
+		if len(next.levels[priority]) == 0 {
+			delete(next.levels, priority)
+		}
+		next.waiting--
+		next.running++
+		p.running++
+		// a higher priority can pick a sub-queue behind the clock, which
+		// must not move backwards for the sub-queues created later
+		if next.vtime > p.vclock {
+			p.vclock = next.vtime
+		}
+		next.vtime += vtimeScale / uint64(next.weight)
+		close(wu.ready)
+	}
+}
+
+// subQueue returns the named sub-queue, creating it, p.lock must be held.
+func (p *GPool) subQueue(name string) *subQueue {
+	q, ok := p.queues[name]
+	if !ok {
+		q = &subQueue{weight: 1, vtime: p.vclock, levels: make(map[int][]*WorkUnit, 1)}
+		p.queues[name] = q
+	}
+	return q
+}
+
+// Monitor returns the number of running works and the capacity of the pool.
 func (p *GPool) Monitor() (int, int) {
-	return len(p.limiter), cap(p.limiter)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.running, p.capacity
+}
+
+// MonitorQueues returns the breakdown of every sub-queue by name.
+func (p *GPool) MonitorQueues() map[string]QueueStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	stats := make(map[string]QueueStats, len(p.queues))
+	for name, q := range p.queues {
+		stats[name] = QueueStats{
+			Weight:    q.weight,
+			Waiting:   q.waiting,
+			Running:   q.running,
+			Completed: q.completed,
+		}
+	}
+	return stats
 }
 
 func (p *GPool) Close() {
 	p.cancel()
-	close(p.limiter)
+}
+
+// BatchStrategy decides how Batch.Wait handles failing works.
+type BatchStrategy int
+
+const (
+	// BatchPartial waits for every work and leaves errors on the units,
+	// Wait returns the ordered partial results and a nil error.
+	BatchPartial BatchStrategy = iota
+	// BatchFailFast cancels the remaining works on the first error, which
+	// Wait returns.
+	BatchFailFast
+	// BatchCollectAll waits for every work, Wait returns their errors joined.
+	BatchCollectAll
+)
+
+type BatchOption func(b *Batch)
+
+// WithBatchStrategy sets how errors are handled, BatchPartial by default.
+func WithBatchStrategy(strategy BatchStrategy) BatchOption {
+	return func(b *Batch) {
+		b.strategy = strategy
+	}
+}
+
+// WithBatchLimit bounds the number of works of the batch running at once,
+// independently of the capacity of the pool.
+func WithBatchLimit(limit int) BatchOption {
+	return func(b *Batch) {
+		b.limit = limit
+	}
 }
 
 type Batch struct {
-	gopool *GPool
-	works  []WorkFunc
+	gopool   *GPool
+	works    []WorkFunc
+	strategy BatchStrategy
+	limit    int
 }
 
-func (p *GPool) NewBatch() *Batch {
-	return &Batch{
+func (p *GPool) NewBatch(opts ...BatchOption) *Batch {
+	b := &Batch{
 		gopool: p,
 		works:  make([]WorkFunc, 0, 5),
 	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
 }
 
 func (p *Batch) Queue(work WorkFunc) *Batch {
@@ -132,37 +360,101 @@ func (p *Batch) Queue(work WorkFunc) *Batch {
 	return p
 }
 
+// Wait runs the works and waits for them in order. Works not completed when
+// ctx is done fail with ErrQueueTimeout, works canceled by a failing sibling
+// with ErrQueueContextDone.
 func (p *Batch) Wait(ctx context.Context) ([]*WorkUnit, error) {
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		lock  sync.Mutex
+		first error
+		wg    sync.WaitGroup
+	)
+	fail := func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if first == nil {
+			first = err
+			if p.strategy == BatchFailFast {
+				cancel()
+			}
+		}
+	}
+	var limiter chan struct{}
+	if p.limit > 0 {
+		limiter = make(chan struct{}, p.limit)
+	}
+
 	wus := make([]*WorkUnit, 0, len(p.works))
 	for i := range p.works {
 		wu := &WorkUnit{
-			ctx:  ctx,
+			ctx:  batchCtx,
 			work: p.works[i],
 			ch:   make(chan *any, 1),
 		}
-		_ = p.gopool.queue(wu)
-
 		wus = append(wus, wu)
+
+		if limiter != nil {
+			select {
+			case limiter <- struct{}{}:
+			case <-batchCtx.Done():
+				wu.Error(ErrQueueContextDone)
+				continue
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-wu.ch
+			if limiter != nil {
+				<-limiter
+			}
+			if nil != wu.Err {
+				fail(wu.Err)
+			}
+		}()
+		_ = p.gopool.queue(wu)
 	}
 
 	for i := range wus {
 		select {
 		case <-p.gopool.ctx.Done():
-			for j := i; j < len(wus); j++ {
-				if nil == wus[j].Err {
-					wus[j].Error(ErrQueueTimeout)
-				}
-			}
-			return wus, nil
-		case <-wus[i].ctx.Done():
-			for j := i; j < len(wus); j++ {
-				if nil == wus[j].Err {
-					wus[j].Error(ErrQueueTimeout)
-				}
+			abortWorks(wus[i:], ErrQueueTimeout, fail)
+		case <-batchCtx.Done():
+			if nil != ctx.Err() {
+				abortWorks(wus[i:], ErrQueueTimeout, fail)
+			} else {
+				abortWorks(wus[i:], ErrQueueContextDone, fail)
 			}
-			return wus, nil
 		case <-wus[i].ch:
+			continue
 		}
+		break
+	}
+	cancel()
+	wg.Wait()
+
+	switch p.strategy {
+	case BatchFailFast:
+		return wus, first
+	case BatchCollectAll:
+		errs := make([]error, 0, len(wus))
+		for i, wu := range wus {
+			if nil != wu.Err {
+				errs = append(errs, fmt.Errorf("batch work %d: %w", i, wu.Err))
+			}
+		}
+		return wus, errors.Join(errs...)
 	}
 	return wus, nil
 }
+
+// abortWorks fails the works not yet completed with err.
+func abortWorks(wus []*WorkUnit, err error, fail func(error)) {
+	fail(err)
+	for _, wu := range wus {
+		wu.Error(err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -210,3 +211,217 @@ func TestGPool_Cancel(t *testing.T) {
 
 	cancel()
 }
+
+// waitQueues blocks until the sub-queues of p have the given waiting counts.
+func waitQueues(t *testing.T, p *pool.GPool, waiting map[string]int) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats := p.MonitorQueues()
+		ok := true
+		for name, n := range waiting {
+			if stats[name].Waiting != n {
+				ok = false
+			}
+		}
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("queues %+v", stats)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGPool_Fairness(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gpool := pool.NewLimitPool(ctx, 1)
+
+	release := make(chan struct{})
+	_, _ = gpool.Queue(ctx, func(ctx context.Context) (any, error) {
+		<-release
+		return nil, nil
+	})
+
+	order := make(chan string, 16)
+	queue := func(name string, opts ...pool.QueueOption) {
+		go func() {
+			_, _ = gpool.Queue(ctx, func(ctx context.Context) (any, error) {
+				order <- name
+				return nil, nil
+			}, append(opts, pool.OnQueue(name))...)
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		queue("noisy")
+	}
+	waitQueues(t, gpool, map[string]int{"noisy": 8})
+	queue("quiet")
+	queue("quiet")
+	waitQueues(t, gpool, map[string]int{"noisy": 8, "quiet": 2})
+	queue("urgent", pool.WithPriority(1))
+	waitQueues(t, gpool, map[string]int{"noisy": 8, "quiet": 2, "urgent": 1})
+
+	close(release)
+	if first := <-order; first != "urgent" {
+		t.Fatalf("higher priority should start first, got %s", first)
+	}
+	quiet := 0
+	for i := 0; i < 4; i++ {
+		if <-order == "quiet" {
+			quiet++
+		}
+	}
+	if quiet != 2 {
+		t.Fatalf("quiet queue starved behind noisy one")
+	}
+	for i := 0; i < 6; i++ {
+		<-order
+	}
+
+	stats := gpool.MonitorQueues()
+	if stats["noisy"].Waiting != 0 || stats["quiet"].Completed != 2 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
+
+func TestGPool_Resize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gpool := pool.NewLimitPool(ctx, 1)
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, _ = gpool.Queue(ctx, func(ctx context.Context) (any, error) {
+				started <- struct{}{}
+				<-release
+				return nil, nil
+			})
+		}()
+	}
+	waitQueues(t, gpool, map[string]int{pool.DefaultQueue: 2})
+	if running, capacity := gpool.Monitor(); running != 1 || capacity != 1 {
+		t.Fatalf("monitor %d/%d", running, capacity)
+	}
+
+	gpool.Resize(3)
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+	if running, _ := gpool.Monitor(); running != 3 {
+		t.Fatalf("running %d", running)
+	}
+
+	gpool.Resize(1)
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for running, _ := gpool.Monitor(); running != 0; running, _ = gpool.Monitor() {
+		if time.Now().After(deadline) {
+			t.Fatalf("running %d", running)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	timeout, cancelTimeout := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelTimeout()
+	wu, err := gpool.Queue(timeout, func(ctx context.Context) (any, error) {
+		return "a", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := wu.Get(); v != "a" {
+		t.Fatalf("got %v", v)
+	}
+}
+
+func TestBatch_Strategies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gpool := pool.NewLimitPool(ctx, 10)
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+
+	started, canceled := make(chan struct{}), make(chan struct{})
+	wus, err := gpool.NewBatch(pool.WithBatchStrategy(pool.BatchFailFast)).Queue(func(ctx context.Context) (any, error) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}).Queue(func(ctx context.Context) (any, error) {
+		<-started
+		return nil, errA
+	}).Wait(ctx)
+	if !errors.Is(err, errA) {
+		t.Fatalf("fail-fast should return the first error, got %v", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("siblings should be canceled")
+	}
+	if wus[0].Err == nil {
+		t.Fatalf("canceled sibling: %v", wus[0].Err)
+	}
+
+	wus, err = gpool.NewBatch(pool.WithBatchStrategy(pool.BatchCollectAll)).Queue(func(ctx context.Context) (any, error) {
+		return nil, errA
+	}).Queue(func(ctx context.Context) (any, error) {
+		return "ok", nil
+	}).Queue(func(ctx context.Context) (any, error) {
+		return nil, errB
+	}).Wait(ctx)
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatalf("collect-all should join every error, got %v", err)
+	}
+	if v, _ := wus[1].Get(); v != "ok" {
+		t.Fatalf("got %v", v)
+	}
+
+	wus, err = gpool.NewBatch().Queue(func(ctx context.Context) (any, error) {
+		return nil, errA
+	}).Queue(func(ctx context.Context) (any, error) {
+		return "b", nil
+	}).Wait(ctx)
+	if err != nil || !errors.Is(wus[0].Err, errA) || wus[1].Value != "b" {
+		t.Fatalf("partial results: %v %v %v", err, wus[0].Err, wus[1].Value)
+	}
+}
+
+func TestBatch_Limit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	gpool := pool.NewLimitPool(ctx, 100)
+
+	var running, peak int32
+	batch := gpool.NewBatch(pool.WithBatchLimit(2))
+	for i := 0; i < 8; i++ {
+		i := i
+		batch.Queue(func(ctx context.Context) (any, error) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return i, nil
+		})
+	}
+	wus, err := batch.Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, wu := range wus {
+		if wu.Value != i {
+			t.Fatalf("results out of order: %d %v", i, wu.Value)
+		}
+	}
+	if p := atomic.LoadInt32(&peak); p != 2 {
+		t.Fatalf("peak concurrency %d", p)
+	}
+}
@@ -0,0 +1,329 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/uaxe/infra/threading"
+)
+
+var (
+	ErrPoolRejected = errors.New("pool queue is full")
+	ErrPoolShutdown = errors.New("pool is shut down")
+)
+
+// RejectPolicy decides what Submit does when the task queue is full.
+type RejectPolicy int
+
+const (
+	// RejectBlock waits until the queue has room.
+	RejectBlock RejectPolicy = iota
+	// RejectDrop fails the submission with ErrPoolRejected.
+	RejectDrop
+	// RejectCallerRuns runs the task on the submitting goroutine.
+	RejectCallerRuns
+)
+
+type Task[T any] func(ctx context.Context) (T, error)
+
+// Future is the pending result of a task submitted to a Pool.
+type Future[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// Get waits for the task to complete or ctx to be done.
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Done is closed once the task completed.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+func (f *Future[T]) complete(value T, err error) {
+	f.value, f.err = value, err
+	close(f.done)
+}
+
+type task[T any] struct {
+	ctx    context.Context
+	fn     Task[T]
+	future *Future[T]
+}
+
+type poolOptions struct {
+	queueSize int
+	policy    RejectPolicy
+}
+
+type PoolOption func(o *poolOptions)
+
+// WithQueueSize bounds the number of tasks waiting for a worker, the number
+// of workers by default.
+func WithQueueSize(size int) PoolOption {
+	return func(o *poolOptions) {
+		o.queueSize = size
+	}
+}
+
+// WithRejectPolicy sets what Submit does when the queue is full,
+// RejectBlock by default.
+func WithRejectPolicy(policy RejectPolicy) PoolOption {
+	return func(o *poolOptions) {
+		o.policy = policy
+	}
+}
+
+// Pool runs typed tasks on a fixed set of long-lived workers fed by a
+// bounded queue.
+type Pool[T any] struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	policy RejectPolicy
+	tasks  chan *task[T]
+
+	lock       sync.RWMutex
+	closed     bool
+	quit       chan struct{}
+	submitting sync.WaitGroup
+	workers    sync.WaitGroup
+
+	// cancels cancel the contexts of the running tasks once the pool
+	// context is done.
+	cancelLock sync.Mutex
+	cancelID   uint64
+	cancels    map[uint64]context.CancelFunc
+}
+
+// NewPool starts workers goroutines, they exit once ctx is done or the pool
+// is shut down.
+func NewPool[T any](ctx context.Context, workers int, opts ...PoolOption) *Pool[T] {
+	if workers <= 0 {
+		workers = 1
+	}
+	o := &poolOptions{queueSize: workers}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.queueSize < 0 {
+		o.queueSize = 0
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	p := &Pool[T]{
+		ctx:     ctx,
+		cancel:  cancel,
+		policy:  o.policy,
+		tasks:   make(chan *task[T], o.queueSize),
+		quit:    make(chan struct{}),
+		cancels: make(map[uint64]context.CancelFunc),
+	}
+	p.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	go p.cancelTasks()
+	return p
+}
+
+// Submit queues fn, applying the reject policy when the queue is full.
+func (p *Pool[T]) Submit(ctx context.Context, fn Task[T]) (*Future[T], error) {
+	return p.submit(ctx, fn, p.policy)
+}
+
+// TrySubmit queues fn without blocking, returning ErrPoolRejected when the
+// queue is full whatever the reject policy.
+func (p *Pool[T]) TrySubmit(ctx context.Context, fn Task[T]) (*Future[T], error) {
+	return p.submit(ctx, fn, RejectDrop)
+}
+
+// Monitor returns the number of queued tasks and the queue capacity.
+func (p *Pool[T]) Monitor() (int, int) {
+	return len(p.tasks), cap(p.tasks)
+}
+
+// Shutdown stops accepting tasks and waits for the queued ones to drain.
+// If ctx is done first, the running tasks, including those run by their
+// submitter, see their context done, the tasks still queued fail with
+// ErrQueueContextDone and ctx.Err() is returned.
+func (p *Pool[T]) Shutdown(ctx context.Context) error {
+	p.lock.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.quit)
+		go func() {
+			p.submitting.Wait()
+			close(p.tasks)
+		}()
+	}
+	p.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		return ctx.Err()
+	}
+}
+
+func (p *Pool[T]) submit(ctx context.Context, fn Task[T], policy RejectPolicy) (*Future[T], error) {
+	p.lock.RLock()
+	if p.closed {
+		p.lock.RUnlock()
+		return nil, ErrPoolShutdown
+	}
+	p.submitting.Add(1)
+	p.lock.RUnlock()
+	defer p.submitting.Done()
+	if p.ctx.Err() != nil {
+		return nil, ErrQueueContextDone
+	}
+
+	t := &task[T]{ctx: ctx, fn: fn, future: newFuture[T]()}
+	select {
+	case p.tasks <- t:
+		return p.queued(t)
+	default:
+	}
+
+	switch policy {
+	case RejectDrop:
+		return nil, ErrPoolRejected
+	case RejectCallerRuns:
+		p.run(t)
+		return t.future, nil
+	}
+	select {
+	case p.tasks <- t:
+		return p.queued(t)
+	case <-ctx.Done():
+		return nil, ErrQueueContextDone
+	case <-p.ctx.Done():
+		return nil, ErrQueueContextDone
+	case <-p.quit:
+		return nil, ErrPoolShutdown
+	}
+}
+
+// queued returns the future of t, failed if the workers may have drained
+// the queue before t was queued.
+func (p *Pool[T]) queued(t *task[T]) (*Future[T], error) {
+	if p.ctx.Err() != nil {
+		p.drain()
+	}
+	return t.future, nil
+}
+
+func (p *Pool[T]) work() {
+	defer p.workers.Done()
+	for {
+		select {
+		case t, ok := <-p.tasks:
+			if !ok {
+				return
+			}
+			p.run(t)
+		case <-p.ctx.Done():
+			p.drain()
+			return
+		}
+	}
+}
+
+// drain fails the tasks left in the queue once the pool context is done.
+func (p *Pool[T]) drain() {
+	var zero T
+	for {
+		select {
+		case t, ok := <-p.tasks:
+			if !ok {
+				return
+			}
+			t.future.complete(zero, ErrQueueContextDone)
+		default:
+			return
+		}
+	}
+}
+
+// taskContext returns the context of a task submitted with parent, also
+// canceled when the pool context is done, see cancelTasks.
+func (p *Pool[T]) taskContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	p.cancelLock.Lock()
+	defer p.cancelLock.Unlock()
+	if p.ctx.Err() != nil {
+		cancel()
+		return ctx, cancel
+	}
+	id := p.cancelID
+	p.cancelID++
+	p.cancels[id] = cancel
+	return ctx, func() {
+		p.cancelLock.Lock()
+		delete(p.cancels, id)
+		p.cancelLock.Unlock()
+		cancel()
+	}
+}
+
+// cancelTasks cancels the contexts of the running tasks once the pool
+// context is done, the tasks started later being canceled by taskContext.
+func (p *Pool[T]) cancelTasks() {
+	<-p.ctx.Done()
+	p.cancelLock.Lock()
+	defer p.cancelLock.Unlock()
+	for id, cancel := range p.cancels {
+		cancel()
+		delete(p.cancels, id)
+	}
+}
+
+func (p *Pool[T]) run(t *task[T]) {
+	var (
+		value T
+		err   error
+	)
+	defer func() {
+		if r := recover(); nil != r {
+			threading.ReportPanic("pool.Pool", r)
+			err = fmt.Errorf("%v", r)
+		}
+		t.future.complete(value, err)
+	}()
+
+	select {
+	case <-p.ctx.Done():
+		err = ErrQueueContextDone
+		return
+	case <-t.ctx.Done():
+		err = ErrQueueContextDone
+		return
+	default:
+	}
+	ctx, cancel := p.taskContext(t.ctx)
+	defer cancel()
+	value, err = t.fn(ctx)
+}
@@ -0,0 +1,246 @@
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/pool"
+)
+
+func TestPool_Submit(t *testing.T) {
+	ctx := context.Background()
+	p := pool.NewPool[int](ctx, 4, pool.WithQueueSize(16))
+
+	futures := make([]*pool.Future[int], 0, 10)
+	for i := 0; i < 10; i++ {
+		i := i
+		f, err := p.Submit(ctx, func(ctx context.Context) (int, error) {
+			return i * i, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		futures = append(futures, f)
+	}
+	for i, f := range futures {
+		v, err := f.Get(ctx)
+		if err != nil || v != i*i {
+			t.Fatalf("future %d: %v %v", i, v, err)
+		}
+	}
+
+	f, _ := p.Submit(ctx, func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+	if _, err := f.Get(ctx); err == nil || err.Error() != "boom" {
+		t.Fatalf("panic should fail the future: %v", err)
+	}
+
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Submit(ctx, func(ctx context.Context) (int, error) { return 0, nil }); !errors.Is(err, pool.ErrPoolShutdown) {
+		t.Fatalf("expected ErrPoolShutdown, got %v", err)
+	}
+}
+
+// fill occupies the single worker and the queue slot of p.
+func fill(t *testing.T, p *pool.Pool[string]) chan struct{} {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	block := func(ctx context.Context) (string, error) {
+		<-release
+		return "blocked", nil
+	}
+	if _, err := p.Submit(context.Background(), func(ctx context.Context) (string, error) {
+		close(started)
+		return block(ctx)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if _, err := p.TrySubmit(context.Background(), block); err != nil {
+		t.Fatal(err)
+	}
+	return release
+}
+
+func TestPool_RejectPolicy(t *testing.T) {
+	ctx := context.Background()
+	caller := func(ctx context.Context) (string, error) { return "caller", nil }
+
+	drop := pool.NewPool[string](ctx, 1, pool.WithQueueSize(1), pool.WithRejectPolicy(pool.RejectDrop))
+	release := fill(t, drop)
+	if _, err := drop.Submit(ctx, caller); !errors.Is(err, pool.ErrPoolRejected) {
+		t.Fatalf("expected ErrPoolRejected, got %v", err)
+	}
+	close(release)
+	_ = drop.Shutdown(ctx)
+
+	runs := pool.NewPool[string](ctx, 1, pool.WithQueueSize(1), pool.WithRejectPolicy(pool.RejectCallerRuns))
+	release = fill(t, runs)
+	f, err := runs.Submit(ctx, caller)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-f.Done():
+	default:
+		t.Fatal("caller-runs should complete the task before returning")
+	}
+	if _, err := runs.TrySubmit(ctx, caller); !errors.Is(err, pool.ErrPoolRejected) {
+		t.Fatalf("TrySubmit should not block nor run inline: %v", err)
+	}
+	close(release)
+	_ = runs.Shutdown(ctx)
+
+	block := pool.NewPool[string](ctx, 1, pool.WithQueueSize(1))
+	release = fill(t, block)
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := block.Submit(timeout, caller); !errors.Is(err, pool.ErrQueueContextDone) {
+		t.Fatalf("expected ErrQueueContextDone, got %v", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	if f, err = block.Submit(ctx, caller); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := f.Get(ctx); v != "caller" {
+		t.Fatalf("got %q", v)
+	}
+	_ = block.Shutdown(ctx)
+}
+
+func TestPool_Shutdown(t *testing.T) {
+	ctx := context.Background()
+	p := pool.NewPool[int](ctx, 2, pool.WithQueueSize(10))
+	var done int32
+	futures := make([]*pool.Future[int], 0, 10)
+	for i := 0; i < 10; i++ {
+		f, err := p.Submit(ctx, func(ctx context.Context) (int, error) {
+			time.Sleep(10 * time.Millisecond)
+			return int(atomic.AddInt32(&done, 1)), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		futures = append(futures, f)
+	}
+	// queued tasks are drained before Shutdown returns
+	if err := p.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&done); n != 10 {
+		t.Fatalf("drained %d tasks", n)
+	}
+
+	p = pool.NewPool[int](ctx, 1, pool.WithQueueSize(10))
+	futures = futures[:0]
+	for i := 0; i < 5; i++ {
+		f, _ := p.Submit(ctx, func(ctx context.Context) (int, error) {
+			time.Sleep(50 * time.Millisecond)
+			return 1, nil
+		})
+		futures = append(futures, f)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if _, err := futures[4].Get(ctx); !errors.Is(err, pool.ErrQueueContextDone) {
+		t.Fatalf("abandoned task should fail, got %v", err)
+	}
+
+	// a task run by its submitter does not hold Shutdown past its deadline
+	p = pool.NewPool[int](ctx, 1, pool.WithQueueSize(0), pool.WithRejectPolicy(pool.RejectCallerRuns))
+	release := make(chan struct{})
+	defer close(release)
+	for {
+		// the worker is busy once it takes the task
+		_, err := p.TrySubmit(ctx, func(context.Context) (int, error) {
+			<-release
+			return 1, nil
+		})
+		if err == nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	inline := make(chan error, 1)
+	started := make(chan struct{})
+	go func() {
+		_, err := p.Submit(ctx, func(ctx context.Context) (int, error) {
+			close(started)
+			<-ctx.Done()
+			return 0, ctx.Err()
+		})
+		inline <- err
+	}()
+	<-started
+	timeout, cancel = context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := p.Shutdown(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	select {
+	case <-inline:
+	case <-time.After(time.Second):
+		t.Fatal("inline task not canceled by the shutdown deadline")
+	}
+}
+
+func TestPool_Cancel(t *testing.T) {
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	p := pool.NewPool[int](ctx, 4, pool.WithQueueSize(10))
+	started := make(chan struct{})
+	running, _ := p.Submit(context.Background(), func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	<-started
+	cancel()
+	if _, err := running.Get(context.Background()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("task context not canceled with the pool, got %v", err)
+	}
+	if _, err := p.Submit(context.Background(), func(context.Context) (int, error) { return 1, nil }); !errors.Is(err, pool.ErrQueueContextDone) {
+		t.Fatalf("submitted to a canceled pool, got %v", err)
+	}
+	// the tasks queued while the pool is canceled complete
+	p = pool.NewPool[int](context.Background(), 1, pool.WithQueueSize(100))
+	var futures []*pool.Future[int]
+	go p.Shutdown(canceled)
+	for i := 0; i < 100; i++ {
+		f, err := p.Submit(context.Background(), func(context.Context) (int, error) { return 1, nil })
+		if err == nil {
+			futures = append(futures, f)
+		}
+	}
+	for _, f := range futures {
+		wait, stop := context.WithTimeout(context.Background(), time.Second)
+		_, err := f.Get(wait)
+		stop()
+		if errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("future of a task queued after the drain never completes")
+		}
+	}
+	// workers exit without Shutdown
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left, %d before", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
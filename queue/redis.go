@@ -10,13 +10,14 @@ import (
 	"math/rand"
 	"net"
 	"net/url"
-	"runtime/debug"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"github.com/uaxe/infra/threading"
 )
 
 type RedisNode struct {
@@ -456,7 +457,7 @@ func (q *RedisQueue) startTopics(onTopic func(channelid string, raw []byte) erro
 						func() {
 							defer func() {
 								if err := recover(); err != nil {
-									fmt.Println("redisQueue|subscribe|listener", topicChannel, string(debug.Stack()))
+									threading.ReportPanic("queue.RedisQueue.topic", err)
 								}
 							}()
 							_ = onTopic(topicChannel, raw)
@@ -476,7 +477,7 @@ func (q *RedisQueue) handle0(item *notifyItem) error {
 
 	defer func() {
 		if err := recover(); err != nil {
-			fmt.Println("RedisQueue|handle0|Panic", item.key, string(debug.Stack()))
+			threading.ReportPanic("queue.RedisQueue.handler", err)
 		}
 	}()
 	for {
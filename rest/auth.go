@@ -0,0 +1,175 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var ErrMissingToken = errors.New("rest: missing or malformed jwt")
+
+type jwtTokenKey struct{}
+
+// revocationError is an error of JWTConfig.Revocation, e.g. its backend
+// being down, rather than of the token.
+type revocationError struct {
+	err error
+}
+
+func (e *revocationError) Error() string {
+	return "rest: revocation list: " + e.err.Error()
+}
+
+func (e *revocationError) Unwrap() error {
+	return e.err
+}
+
+// JWTAuth answers 401 to the requests without a valid token, looked up as
+// config.TokenLookup tells and parsed by config.ParseTokenFunc. Refresh
+// tokens and the tokens of config.Revocation are rejected, and the requests
+// are answered 500 when config.Revocation fails. The token of the
+// other requests is set in their context, see GetJWTToken and GetJWTClaims.
+//
+// JWTAuth panics when TokenLookup is invalid.
+func JWTAuth(config *JWTConfig) Middleware {
+	extractors, err := tokenExtractors(config.TokenLookup, config.AuthScheme)
+	if err != nil {
+		panic(err)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := config.parseRequest(r, extractors)
+			var backend *revocationError
+			switch {
+			case errors.As(err, &backend):
+				_ = WriteJSON(w, http.StatusInternalServerError,
+					FailWithMessage(http.StatusText(http.StatusInternalServerError)))
+				return
+			case err != nil:
+				_ = WriteJSON(w, http.StatusUnauthorized, FailWithMessage(err.Error()))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), jwtTokenKey{}, token)))
+		})
+	}
+}
+
+// GetJWTToken returns the token set by JWTAuth.
+func GetJWTToken(ctx context.Context) (*jwt.Token, bool) {
+	token, ok := ctx.Value(jwtTokenKey{}).(*jwt.Token)
+	return token, ok
+}
+
+// GetJWTClaims returns the claims of the token set by JWTAuth, of the type
+// of JWTConfig.Claims: jwt.MapClaims by default.
+func GetJWTClaims[T jwt.Claims](ctx context.Context) (T, bool) {
+	token, ok := GetJWTToken(ctx)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	claims, ok := token.Claims.(T)
+	return claims, ok
+}
+
+func (config *JWTConfig) parseRequest(r *http.Request, extractors []tokenExtractor) (*jwt.Token, error) {
+	var auth string
+	for _, extract := range extractors {
+		if auth = extract(r); auth != "" {
+			break
+		}
+	}
+	if auth == "" {
+		return nil, ErrMissingToken
+	}
+	if err := config.fetchRemoteKey(r.Context(), auth); err != nil {
+		return nil, err
+	}
+	parse := config.ParseTokenFunc
+	if parse == nil {
+		parse = config.DefaultParseToken
+	}
+	token, err := parse(auth)
+	if err != nil {
+		return nil, err
+	}
+	if tokenMetaOf(token).Type == TokenTypeRefresh {
+		return nil, errors.New("rest: refresh token used as access token")
+	}
+	if err = config.checkRevoked(r.Context(), token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// fetchRemoteKey looks up the remote key of auth within ctx, for the key
+// function, which has no context, to find it cached. A failed lookup is
+// left to the key function to report, unless ctx is done.
+func (config *JWTConfig) fetchRemoteKey(ctx context.Context, auth string) error {
+	if config.RemoteKeys == nil {
+		return nil
+	}
+	token, _, err := jwt.NewParser().ParseUnverified(auth, jwt.MapClaims{})
+	if err != nil || !config.remote(token) {
+		return nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	if _, err = config.RemoteKeys.Lookup(ctx, kid); err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return nil
+}
+
+type tokenExtractor func(r *http.Request) string
+
+// tokenExtractors parses a TokenLookup such as "header:Authorization,
+// cookie:jwt", header:Authorization by default.
+func tokenExtractors(lookup, scheme string) ([]tokenExtractor, error) {
+	if lookup == "" {
+		lookup = DefaultJWTConfig.TokenLookup
+	}
+	var extractors []tokenExtractor
+	for _, source := range strings.Split(lookup, ",") {
+		kind, name, ok := strings.Cut(strings.TrimSpace(source), ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("rest: invalid jwt token lookup %q", source)
+		}
+		switch kind {
+		case "header":
+			extractors = append(extractors, func(r *http.Request) string {
+				return trimScheme(r.Header.Get(name), scheme)
+			})
+		case "query":
+			extractors = append(extractors, func(r *http.Request) string {
+				return r.URL.Query().Get(name)
+			})
+		case "cookie":
+			extractors = append(extractors, func(r *http.Request) string {
+				if c, err := r.Cookie(name); err == nil {
+					return c.Value
+				}
+				return ""
+			})
+		default:
+			return nil, fmt.Errorf("rest: invalid jwt token lookup %q", source)
+		}
+	}
+	return extractors, nil
+}
+
+// trimScheme returns the credentials of auth of the scheme, empty if auth
+// is of another scheme.
+func trimScheme(auth, scheme string) string {
+	if scheme == "" {
+		return strings.TrimSpace(auth)
+	}
+	if len(auth) <= len(scheme)+1 || !strings.EqualFold(auth[:len(scheme)], scheme) || auth[len(scheme)] != ' ' {
+		return ""
+	}
+	return strings.TrimSpace(auth[len(scheme)+1:])
+}
@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/uaxe/infra/binder"
+)
+
+// MaxMultipartMemory bounds the memory of a multipart form, the rest of its
+// files being stored on disk.
+var MaxMultipartMemory int64 = 32 << 20
+
+// Bind fills dst, a pointer to a struct, from req: the body per its
+// Content-Type, JSON into the fields tagged `json` and forms into `form`,
+// then the path parameters of its route into the fields tagged `path`, the
+// query into `query` and the headers into `header`. The body is bound first
+// so that it cannot override the URL, e.g. with an untagged field matched by
+// name:
+//
+//	type updateUser struct {
+//		ID      int64  `path:"id"`
+//		DryRun  bool   `query:"dry_run"`
+//		TraceID string `header:"X-Trace-Id"`
+//		Name    string `json:"name"`
+//	}
+func Bind(req *http.Request, dst any) error {
+	if err := bindBody(req, dst); err != nil {
+		return err
+	}
+	if err := BindPath(req, dst); err != nil {
+		return err
+	}
+	if err := BindQuery(req, dst); err != nil {
+		return err
+	}
+	return BindHeader(req, dst)
+}
+
+func bindBody(req *http.Request, dst any) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		return BindForm(req, dst)
+	case "application/json", "":
+		return BindJSON(req, dst)
+	}
+	return nil
+}
+
+func BindPath(req *http.Request, dst any) error {
+	if err := binder.Path.Binding(Params(req), dst); err != nil {
+		return fmt.Errorf("rest: bind path: %w", err)
+	}
+	return nil
+}
+
+func BindQuery(req *http.Request, dst any) error {
+	if err := binder.Query.Binding(req.URL.Query(), dst); err != nil {
+		return fmt.Errorf("rest: bind query: %w", err)
+	}
+	return nil
+}
+
+func BindHeader(req *http.Request, dst any) error {
+	if err := binder.Header.Binding(req.Header, dst); err != nil {
+		return fmt.Errorf("rest: bind header: %w", err)
+	}
+	return nil
+}
+
+// BindForm binds the url-encoded or multipart body of req, not its query.
+func BindForm(req *http.Request, dst any) error {
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		err = req.ParseMultipartForm(MaxMultipartMemory)
+	} else {
+		err = req.ParseForm()
+	}
+	if err != nil {
+		return fmt.Errorf("rest: bind form: %w", err)
+	}
+	if err = binder.Form.Binding(req.PostForm, dst); err != nil {
+		return fmt.Errorf("rest: bind form: %w", err)
+	}
+	return nil
+}
+
+// BindJSON decodes the body of req, an empty body leaving dst unchanged.
+func BindJSON(req *http.Request, dst any) error {
+	if req.Body == nil {
+		return nil
+	}
+	if err := json.NewDecoder(req.Body).Decode(dst); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("rest: bind json: %w", err)
+	}
+	return nil
+}
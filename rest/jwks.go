@@ -0,0 +1,325 @@
+package rest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/uaxe/infra/zhttp"
+)
+
+var ErrUnknownKeyID = errors.New("rest: unknown jwt key id")
+
+// JWK is a public key of a JSON Web Key Set, RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC and OKP keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK returns the public JWK of key, HS256 secrets are not published.
+func NewJWK(key JWTKey) (JWK, error) {
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+	enc := base64.RawURLEncoding
+	switch pub := key.VerifyKey().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = enc.EncodeToString(pub.N.Bytes())
+		jwk.E = enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty, jwk.Crv = "EC", pub.Curve.Params().Name
+		jwk.X = enc.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = enc.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty, jwk.Crv = "OKP", "Ed25519"
+		jwk.X = enc.EncodeToString(pub)
+	default:
+		return JWK{}, fmt.Errorf("rest: jwt key %s has no public key", key.ID)
+	}
+	return jwk, nil
+}
+
+// JWTKey returns the key verifying the tokens signed by the key of jwk.
+func (jwk JWK) JWTKey() (JWTKey, error) {
+	dec := base64.RawURLEncoding
+	var pub any
+	switch jwk.Kty {
+	case "RSA":
+		n, err := dec.DecodeString(jwk.N)
+		if err != nil {
+			return JWTKey{}, fmt.Errorf("rest: jwk %s: %w", jwk.Kid, err)
+		}
+		e, err := dec.DecodeString(jwk.E)
+		if err != nil {
+			return JWTKey{}, fmt.Errorf("rest: jwk %s: %w", jwk.Kid, err)
+		}
+		pub = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		if jwk.Crv != elliptic.P256().Params().Name {
+			return JWTKey{}, fmt.Errorf("rest: jwk %s: unsupported curve %s", jwk.Kid, jwk.Crv)
+		}
+		x, err := dec.DecodeString(jwk.X)
+		if err != nil {
+			return JWTKey{}, fmt.Errorf("rest: jwk %s: %w", jwk.Kid, err)
+		}
+		y, err := dec.DecodeString(jwk.Y)
+		if err != nil {
+			return JWTKey{}, fmt.Errorf("rest: jwk %s: %w", jwk.Kid, err)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return JWTKey{}, fmt.Errorf("rest: jwk %s: point not on curve", jwk.Kid)
+		}
+		pub = key
+	case "OKP":
+		x, err := dec.DecodeString(jwk.X)
+		if err != nil {
+			return JWTKey{}, fmt.Errorf("rest: jwk %s: %w", jwk.Kid, err)
+		}
+		if jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return JWTKey{}, fmt.Errorf("rest: jwk %s: unsupported curve %s", jwk.Kid, jwk.Crv)
+		}
+		pub = ed25519.PublicKey(x)
+	default:
+		return JWTKey{}, fmt.Errorf("rest: jwk %s: unsupported key type %s", jwk.Kid, jwk.Kty)
+	}
+	key, err := NewJWTKey(jwk.Kid, pub)
+	if err != nil {
+		return JWTKey{}, err
+	}
+	if jwk.Alg != "" {
+		key.Algorithm = jwk.Alg
+	}
+	return key, nil
+}
+
+// NewJWKS returns the public keys of set, skipping its HS256 secrets.
+func NewJWKS(set *KeySet) JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, key := range set.Keys() {
+		if jwk, err := NewJWK(key); err == nil {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// JWKSHandler serves the public keys of set, usually at
+// /.well-known/jwks.json, for other services to verify the tokens signed by
+// set. Keep the keys removed from the signing rotation in set as long as
+// the tokens they signed are valid.
+func JWKSHandler(set *KeySet, maxAge time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if maxAge > 0 {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge/time.Second)))
+		}
+		_ = WriteJSON(w, http.StatusOK, NewJWKS(set))
+	}
+}
+
+// RemoteKeySet verifies tokens with the keys of the JWKS document of an
+// identity provider. The keys are cached, and fetched again at most once per
+// refresh interval: in the background when they are older than the cache
+// TTL, the stale keys being used meanwhile, and before answering when a
+// token has an unknown kid:
+//
+//	config := rest.JWT("", 0)
+//	config.RemoteKeys = rest.NewRemoteKeySet("https://idp.example.com/.well-known/jwks.json")
+type RemoteKeySet struct {
+	url        string
+	request    zhttp.Request
+	ttl        time.Duration
+	minRefresh time.Duration
+
+	refreshing sync.Mutex
+	lock       sync.RWMutex
+	keys       *KeySet
+	fetched    time.Time
+	attempted  time.Time
+}
+
+type RemoteKeySetOption func(s *RemoteKeySet)
+
+// WithJWKSRequest fetches the JWKS document with r, by default a request
+// timing out after 10 seconds.
+func WithJWKSRequest(r zhttp.Request) RemoteKeySetOption {
+	return func(s *RemoteKeySet) {
+		s.request = r
+	}
+}
+
+// WithJWKSCacheTTL refreshes the keys older than ttl, 1 hour by default.
+func WithJWKSCacheTTL(ttl time.Duration) RemoteKeySetOption {
+	return func(s *RemoteKeySet) {
+		s.ttl = ttl
+	}
+}
+
+// WithJWKSRefreshInterval bounds the refreshes triggered by unknown key IDs
+// to one per d, 1 minute by default, so that forged tokens cannot flood the
+// identity provider.
+func WithJWKSRefreshInterval(d time.Duration) RemoteKeySetOption {
+	return func(s *RemoteKeySet) {
+		s.minRefresh = d
+	}
+}
+
+func NewRemoteKeySet(url string, opts ...RemoteKeySetOption) *RemoteKeySet {
+	s := &RemoteKeySet{
+		url:        url,
+		request:    zhttp.NewRequest(zhttp.WithTimeout(10 * time.Second)),
+		ttl:        time.Hour,
+		minRefresh: time.Minute,
+		keys:       NewKeySet(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// jwksRefreshTimeout bounds the refreshes not bound by the context of a
+// caller.
+const jwksRefreshTimeout = 30 * time.Second
+
+// Lookup returns the key kid. The keys are refreshed in the background when
+// the cache expired, and with ctx when kid is unknown.
+func (s *RemoteKeySet) Lookup(ctx context.Context, kid string) (JWTKey, error) {
+	key, ok, expired := s.lookup(kid)
+	if ok {
+		if expired {
+			s.refreshStale()
+		}
+		return key, nil
+	}
+
+	s.refreshing.Lock()
+	defer s.refreshing.Unlock()
+	// refreshed meanwhile by another caller
+	if key, ok, _ = s.lookup(kid); ok {
+		return key, nil
+	}
+	if !s.canRefresh() {
+		return JWTKey{}, fmt.Errorf("%w %s", ErrUnknownKeyID, kid)
+	}
+	if err := s.refresh(ctx); err != nil {
+		return JWTKey{}, err
+	}
+	if key, ok, _ = s.lookup(kid); !ok {
+		return JWTKey{}, fmt.Errorf("%w %s", ErrUnknownKeyID, kid)
+	}
+	return key, nil
+}
+
+// Refresh fetches the keys, regardless of the refresh interval.
+func (s *RemoteKeySet) Refresh(ctx context.Context) error {
+	s.refreshing.Lock()
+	defer s.refreshing.Unlock()
+	return s.refresh(ctx)
+}
+
+// KeyFunc is a jwt.Keyfunc verifying tokens with the remote keys, fetched
+// within jwksRefreshTimeout. Prefer KeyFuncContext when serving a request.
+func (s *RemoteKeySet) KeyFunc(t *jwt.Token) (any, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksRefreshTimeout)
+	defer cancel()
+	return s.KeyFuncContext(ctx)(t)
+}
+
+// KeyFuncContext returns a jwt.Keyfunc verifying tokens with the remote
+// keys, fetched within ctx.
+func (s *RemoteKeySet) KeyFuncContext(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, err := s.Lookup(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected jwt signing method=%v", t.Header["alg"])
+		}
+		return key.VerifyKey(), nil
+	}
+}
+
+// lookup returns the cached key kid, and whether the cache expired.
+func (s *RemoteKeySet) lookup(kid string) (JWTKey, bool, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	key, ok := s.keys.Lookup(kid)
+	return key, ok, s.fetched.IsZero() || (s.ttl > 0 && time.Since(s.fetched) > s.ttl)
+}
+
+// refreshStale refreshes the expired keys in the background, unless they
+// are being refreshed or were attempted within the refresh interval.
+func (s *RemoteKeySet) refreshStale() {
+	if !s.refreshing.TryLock() {
+		return
+	}
+	if !s.canRefresh() {
+		s.refreshing.Unlock()
+		return
+	}
+	go func() {
+		defer s.refreshing.Unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), jwksRefreshTimeout)
+		defer cancel()
+		_ = s.refresh(ctx)
+	}()
+}
+
+func (s *RemoteKeySet) canRefresh() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return time.Since(s.attempted) >= s.minRefresh
+}
+
+// refresh replaces the keys by those of the JWKS document, skipping the
+// keys not used for signatures or of unsupported types.
+func (s *RemoteKeySet) refresh(ctx context.Context) error {
+	s.lock.Lock()
+	s.attempted = time.Now()
+	s.lock.Unlock()
+
+	jwks, err := zhttp.Get[JWKS](ctx, s.url, zhttp.WithRequest(s.request))
+	if err != nil {
+		return fmt.Errorf("rest: fetch jwks: %w", err)
+	}
+	keys := make([]JWTKey, 0, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.JWTKey(); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	s.lock.Lock()
+	s.keys, s.fetched = NewKeySet(keys...), time.Now()
+	s.lock.Unlock()
+	return nil
+}
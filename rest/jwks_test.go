@@ -0,0 +1,157 @@
+package rest_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+
+	"github.com/uaxe/infra/rest"
+)
+
+func TestRemoteKeySet(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	k1, _ := rest.NewJWTKey("k1", rsaKey)
+	k2, _ := rest.NewJWTKey("k2", ecKey)
+	k3, _ := rest.NewJWTKey("k3", edKey)
+
+	// the identity provider, serving its JWKS
+	idp := rest.JWT("", 60)
+	idp.KeySet = rest.NewKeySet(k1, rest.JWTKey{ID: "hmac", Algorithm: rest.AlgorithmHS256, Key: []byte("secret")})
+	var fetches atomic.Int32
+	jwks := rest.JWKSHandler(idp.KeySet, time.Minute)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		jwks(w, r)
+	}))
+	defer srv.Close()
+	sign := func() string {
+		token, _, err := idp.DefaultTokenGenerator(func() (jwt.MapClaims, error) {
+			return jwt.MapClaims{"sub": "uaxe"}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	remote := rest.NewRemoteKeySet(srv.URL, rest.WithJWKSRefreshInterval(time.Hour))
+	config := rest.JWT("", 60)
+	config.RemoteKeys = remote
+	if err := idp.KeySet.Rotate(k1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := config.ParseTokenFunc(sign()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("%d fetches of cached keys", n)
+	}
+	if _, err := remote.Lookup(context.Background(), "hmac"); !errors.Is(err, rest.ErrUnknownKeyID) {
+		t.Fatalf("secret published: %v", err)
+	}
+
+	// an unknown kid refreshes the keys at most once per refresh interval
+	_ = idp.KeySet.Rotate(k2)
+	if _, err := config.ParseTokenFunc(sign()); err == nil {
+		t.Fatal("key fetched again within the refresh interval")
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("%d fetches within the refresh interval", n)
+	}
+
+	// the refresh tokens of the identity provider are not ours to renew
+	pair, err := idp.GenerateTokenPair(jwt.MapClaims{"sub": "uaxe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.KeySet = rest.NewKeySet(k3)
+	if _, err = config.Refresh(context.Background(), pair.RefreshToken); !errors.Is(err, rest.ErrInvalidRefreshToken) {
+		t.Fatalf("remote refresh token renewed: %v", err)
+	}
+	config.KeySet = nil
+
+	// JWTAuth fetches the keys within the context of the request
+	remote = rest.NewRemoteKeySet(srv.URL, rest.WithJWKSRefreshInterval(0))
+	config.RemoteKeys = remote
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+sign())
+	rest.JWTAuth(config)(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("keys fetched for a canceled request: %d fetches", n)
+	}
+
+	for _, key := range []rest.JWTKey{k2, k3} {
+		_ = idp.KeySet.Rotate(key)
+		token, err := config.ParseTokenFunc(sign())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.Header["kid"] != key.ID {
+			t.Fatalf("token signed by %v", token.Header["kid"])
+		}
+	}
+}
+
+func TestRemoteKeySet_StaleKeys(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	k1, _ := rest.NewJWTKey("k1", rsaKey)
+	idp := rest.JWT("", 60)
+	idp.KeySet = rest.NewKeySet(k1)
+	token, _, err := idp.DefaultTokenGenerator(func() (jwt.MapClaims, error) {
+		return jwt.MapClaims{"sub": "uaxe"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	jwks := rest.JWKSHandler(idp.KeySet, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		jwks(w, r)
+	}))
+	defer srv.Close()
+
+	config := rest.JWT("", 60)
+	config.RemoteKeys = rest.NewRemoteKeySet(srv.URL,
+		rest.WithJWKSCacheTTL(time.Millisecond), rest.WithJWKSRefreshInterval(0))
+	if _, err = config.ParseTokenFunc(token); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// the expired keys are used while refreshed, once
+	for i := 0; i < 3; i++ {
+		if _, err = config.ParseTokenFunc(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for fetches.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Fatalf("%d fetches of expired keys", n)
+	}
+}
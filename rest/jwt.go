@@ -10,21 +10,41 @@ import (
 )
 
 type JWTConfig struct {
-	SigningMethod  string
-	SigningKey     any
-	AuthScheme     string
+	SigningMethod string
+	SigningKey    any
+	AuthScheme    string
+	// TokenLookup tells where JWTAuth looks for the token, a comma
+	// separated list of "header:<name>", "query:<name>" and "cookie:<name>"
+	// tried in order. AuthScheme only applies to headers.
 	TokenLookup    string
 	Expires        time.Duration
 	SigningKeys    map[string]any
 	Claims         jwt.Claims
 	KeyFunc        jwt.Keyfunc
 	ParseTokenFunc func(auth string) (*jwt.Token, error)
+
+	// KeySet, when set, signs the tokens with its current key and verifies
+	// them with the key of their kid, in place of the signing keys above.
+	KeySet *KeySet
+	// RemoteKeys verifies the tokens whose kid is not in KeySet, e.g. those
+	// of an external identity provider.
+	RemoteKeys *RemoteKeySet
+	// RefreshExpires is the lifetime of refresh tokens,
+	// DefaultRefreshExpires when zero.
+	RefreshExpires time.Duration
+	// Revocation rejects the revoked tokens and refresh tokens, see Revoke.
+	Revocation RevocationList
 }
 
 const (
 	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
 )
 
+var DefaultRefreshExpires = 7 * 24 * time.Hour
+
 var (
 	DefaultJWTConfig = JWTConfig{
 		SigningMethod: AlgorithmHS256,
@@ -52,11 +72,11 @@ func JWT(key string, expires int64) *JWTConfig {
 
 func (config *JWTConfig) DefaultParseToken(tokenstr string) (token *jwt.Token, err error) {
 	if _, ok := config.Claims.(jwt.MapClaims); ok {
-		token, err = jwt.Parse(tokenstr, config.KeyFunc)
+		token, err = jwt.Parse(tokenstr, config.keyFunc())
 	} else {
 		t := reflect.ValueOf(config.Claims).Type().Elem()
 		claims := reflect.New(t).Interface().(jwt.Claims)
-		token, err = jwt.ParseWithClaims(tokenstr, claims, config.KeyFunc)
+		token, err = jwt.ParseWithClaims(tokenstr, claims, config.keyFunc())
 	}
 	if err != nil {
 		return nil, err
@@ -68,6 +88,43 @@ func (config *JWTConfig) DefaultParseToken(tokenstr string) (token *jwt.Token, e
 }
 
 func (config *JWTConfig) DefaultKeyFunc(t *jwt.Token) (any, error) {
+	if config.remote(t) {
+		return config.RemoteKeys.KeyFunc(t)
+	}
+	return config.localKeyFunc(t)
+}
+
+// remote reports whether t is verified by RemoteKeys, its kid not being in
+// KeySet.
+func (config *JWTConfig) remote(t *jwt.Token) bool {
+	if config.RemoteKeys == nil {
+		return false
+	}
+	if config.KeySet == nil {
+		return true
+	}
+	kid, _ := t.Header["kid"].(string)
+	_, ok := config.KeySet.Lookup(kid)
+	return !ok
+}
+
+// localKeyFunc verifies the tokens signed by this service only, never with
+// RemoteKeys.
+func (config *JWTConfig) localKeyFunc(t *jwt.Token) (any, error) {
+	if config.KeySet != nil {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := config.KeySet.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unexpected jwt key id=%v", t.Header["kid"])
+		}
+		if t.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected jwt signing method=%v", t.Header["alg"])
+		}
+		return key.VerifyKey(), nil
+	}
+	if config.RemoteKeys != nil {
+		return nil, fmt.Errorf("unexpected jwt key id=%v", t.Header["kid"])
+	}
 	if t.Method.Alg() != config.SigningMethod {
 		return nil, fmt.Errorf("unexpected jwt signing method=%v", t.Header["alg"])
 	}
@@ -87,17 +144,48 @@ func (config *JWTConfig) DefaultTokenGenerator(fn func() (jwt.MapClaims, error))
 	if err != nil {
 		return "", time.Time{}, err
 	}
+	return config.sign(claims, config.Expires)
+}
+
+// sign signs claims expiring in expires, with a random jti unless set.
+func (config *JWTConfig) sign(claims jwt.MapClaims, expires time.Duration) (string, time.Time, error) {
 	if claims == nil {
 		claims = jwt.MapClaims{}
 	}
 	now := time.Now().UTC()
-	expire := now.Add(config.Expires)
+	expire := now.Add(expires)
 	claims["exp"] = expire.Unix()
 	claims["orig_iat"] = now.Unix()
-	token := jwt.NewWithClaims(jwt.GetSigningMethod(config.SigningMethod), claims)
-	tokenString, err := token.SignedString(config.SigningKey)
+	if _, ok := claims["jti"]; !ok {
+		claims["jti"] = newRequestID()
+	}
+
+	method, key, kid := config.SigningMethod, config.SigningKey, ""
+	if config.KeySet != nil {
+		current, ok := config.KeySet.Current()
+		if !ok {
+			return "", time.Time{}, errors.New("no jwt signing key in the key set")
+		}
+		method, key, kid = current.Algorithm, current.Key, current.ID
+	}
+	signingMethod := jwt.GetSigningMethod(method)
+	if signingMethod == nil {
+		return "", time.Time{}, fmt.Errorf("unknown jwt signing method=%s", method)
+	}
+	token := jwt.NewWithClaims(signingMethod, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	tokenString, err := token.SignedString(key)
 	if err != nil {
 		return "", time.Time{}, err
 	}
 	return tokenString, expire, nil
 }
+
+func (config *JWTConfig) keyFunc() jwt.Keyfunc {
+	if config.KeyFunc != nil {
+		return config.KeyFunc
+	}
+	return config.DefaultKeyFunc
+}
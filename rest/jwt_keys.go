@@ -0,0 +1,187 @@
+package rest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// JWTKey is a key of a KeySet.
+type JWTKey struct {
+	ID        string
+	Algorithm string
+	// Key signs the tokens: a []byte secret for HS256, else a private key.
+	// It is nil for keys only verifying tokens.
+	Key any
+	// Public verifies the tokens, derived from Key when nil.
+	Public any
+}
+
+// NewJWTKey returns the key kid of key, its algorithm following its type:
+// HS256 for a []byte secret, RS256 for RSA keys, ES256 for P-256 keys and
+// EdDSA for Ed25519 keys. Public keys only verify tokens.
+func NewJWTKey(kid string, key any) (JWTKey, error) {
+	k := JWTKey{ID: kid, Key: key}
+	switch x := key.(type) {
+	case []byte:
+		k.Algorithm = AlgorithmHS256
+	case *rsa.PrivateKey:
+		k.Algorithm = AlgorithmRS256
+	case *rsa.PublicKey:
+		k.Algorithm, k.Key, k.Public = AlgorithmRS256, nil, x
+	case *ecdsa.PrivateKey:
+		if x.Curve != elliptic.P256() {
+			return JWTKey{}, fmt.Errorf("rest: unsupported curve %s of jwt key %s", x.Curve.Params().Name, kid)
+		}
+		k.Algorithm = AlgorithmES256
+	case *ecdsa.PublicKey:
+		if x.Curve != elliptic.P256() {
+			return JWTKey{}, fmt.Errorf("rest: unsupported curve %s of jwt key %s", x.Curve.Params().Name, kid)
+		}
+		k.Algorithm, k.Key, k.Public = AlgorithmES256, nil, x
+	case ed25519.PrivateKey:
+		k.Algorithm = AlgorithmEdDSA
+	case ed25519.PublicKey:
+		k.Algorithm, k.Key, k.Public = AlgorithmEdDSA, nil, x
+	default:
+		return JWTKey{}, fmt.Errorf("rest: unsupported jwt key %T", key)
+	}
+	return k, nil
+}
+
+// ParseJWTKeyPEM parses a PEM private key, public key or certificate, see
+// NewJWTKey.
+func ParseJWTKeyPEM(kid string, data []byte) (JWTKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return JWTKey{}, errors.New("rest: no PEM data in jwt key")
+	}
+	var (
+		key any
+		err error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return JWTKey{}, fmt.Errorf("rest: unsupported PEM block %q of jwt key", block.Type)
+	}
+	if err != nil {
+		return JWTKey{}, fmt.Errorf("rest: jwt key %s: %w", kid, err)
+	}
+	return NewJWTKey(kid, key)
+}
+
+// VerifyKey returns the key verifying the signatures of k.
+func (k JWTKey) VerifyKey() any {
+	if k.Public != nil {
+		return k.Public
+	}
+	if signer, ok := k.Key.(crypto.Signer); ok {
+		return signer.Public()
+	}
+	return k.Key
+}
+
+// KeySet holds the keys of a JWTConfig. Tokens are signed with its current
+// key and verified with the key named by their kid header, so that keys
+// rotate without invalidating the tokens in flight:
+//
+//	keys.Rotate(newKey)   // new tokens are signed by newKey
+//	...                   // once the tokens of oldKey expired
+//	keys.Remove(oldKey.ID)
+type KeySet struct {
+	lock    sync.RWMutex
+	keys    map[string]JWTKey
+	current string
+}
+
+// NewKeySet returns a set of keys, the last one able to sign being the
+// current one.
+func NewKeySet(keys ...JWTKey) *KeySet {
+	s := &KeySet{keys: make(map[string]JWTKey, len(keys))}
+	for _, key := range keys {
+		s.keys[key.ID] = key
+		if key.Key != nil {
+			s.current = key.ID
+		}
+	}
+	return s
+}
+
+// Add adds a key verifying tokens, or replaces the key of the same ID.
+func (s *KeySet) Add(key JWTKey) {
+	s.lock.Lock()
+	s.keys[key.ID] = key
+	s.lock.Unlock()
+}
+
+// Rotate adds key and signs the new tokens with it.
+func (s *KeySet) Rotate(key JWTKey) error {
+	if key.Key == nil {
+		return fmt.Errorf("rest: jwt key %s cannot sign", key.ID)
+	}
+	s.lock.Lock()
+	s.keys[key.ID] = key
+	s.current = key.ID
+	s.lock.Unlock()
+	return nil
+}
+
+// Remove removes the key kid, the tokens it signed are no longer valid.
+func (s *KeySet) Remove(kid string) {
+	s.lock.Lock()
+	delete(s.keys, kid)
+	if s.current == kid {
+		s.current = ""
+	}
+	s.lock.Unlock()
+}
+
+func (s *KeySet) Lookup(kid string) (JWTKey, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// Current returns the key signing the tokens.
+func (s *KeySet) Current() (JWTKey, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	key, ok := s.keys[s.current]
+	return key, ok
+}
+
+// Keys returns the keys sorted by ID.
+func (s *KeySet) Keys() []JWTKey {
+	s.lock.RLock()
+	keys := make([]JWTKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	s.lock.RUnlock()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
@@ -0,0 +1,177 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("rest: invalid refresh token")
+	ErrTokenRevoked        = errors.New("rest: token revoked")
+)
+
+// TokenTypeRefresh is the typ claim of refresh tokens, which JWTAuth
+// rejects.
+const TokenTypeRefresh = "refresh"
+
+// TokenPair is an access token and the refresh token renewing it.
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int64     `json:"expires_in"`
+	Expires      time.Time `json:"-"`
+}
+
+// GenerateTokenPair signs an access token expiring in Expires and a
+// refresh token expiring in RefreshExpires, both carrying claims.
+func (config *JWTConfig) GenerateTokenPair(claims jwt.MapClaims) (TokenPair, error) {
+	access := jwt.MapClaims{}
+	refresh := jwt.MapClaims{"typ": TokenTypeRefresh}
+	for k, v := range claims {
+		if !reservedClaims[k] {
+			access[k], refresh[k] = v, v
+		}
+	}
+	accessToken, expire, err := config.sign(access, config.Expires)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	refreshExpires := config.RefreshExpires
+	if refreshExpires <= 0 {
+		refreshExpires = DefaultRefreshExpires
+	}
+	refreshToken, _, err := config.sign(refresh, refreshExpires)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    config.AuthScheme,
+		ExpiresIn:    int64(config.Expires / time.Second),
+		Expires:      expire,
+	}, nil
+}
+
+// reservedClaims are set by the token generators, not copied on refresh.
+var reservedClaims = map[string]bool{
+	"exp": true, "iat": true, "nbf": true, "orig_iat": true, "jti": true, "typ": true,
+}
+
+// Refresh issues a new pair of tokens for the claims of refreshToken, which
+// must be signed by this service: RemoteKeys are not trusted to issue
+// them. With a Revocation list, refreshToken is revoked so that it is used
+// only once.
+func (config *JWTConfig) Refresh(ctx context.Context, refreshToken string) (TokenPair, error) {
+	token, err := jwt.Parse(refreshToken, config.localKeyFunc)
+	if err != nil || !token.Valid {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if typ, _ := claims["typ"].(string); typ != TokenTypeRefresh {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	revoked, err := config.revoke(ctx, token)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if !revoked {
+		return TokenPair{}, ErrTokenRevoked
+	}
+	return config.GenerateTokenPair(claims)
+}
+
+// Revoke revokes token, e.g. on logout, until it expires. It does nothing
+// without a Revocation list or for tokens without jti.
+func (config *JWTConfig) Revoke(ctx context.Context, token *jwt.Token) error {
+	_, err := config.revoke(ctx, token)
+	return err
+}
+
+// revoke reports whether token was not revoked yet.
+func (config *JWTConfig) revoke(ctx context.Context, token *jwt.Token) (bool, error) {
+	if config.Revocation == nil {
+		return true, nil
+	}
+	meta := tokenMetaOf(token)
+	if meta.ID == "" {
+		return true, nil
+	}
+	expires := time.Now().Add(config.Expires)
+	if meta.Expires != nil {
+		expires = meta.Expires.Time
+	}
+	return config.Revocation.Revoke(ctx, meta.ID, expires)
+}
+
+func (config *JWTConfig) checkRevoked(ctx context.Context, token *jwt.Token) error {
+	if config.Revocation == nil {
+		return nil
+	}
+	meta := tokenMetaOf(token)
+	if meta.ID == "" {
+		return nil
+	}
+	revoked, err := config.Revocation.IsRevoked(ctx, meta.ID)
+	if err != nil {
+		return &revocationError{err: err}
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// RefreshHandler answers a JSON or form body holding a refresh_token with
+// a new TokenPair, 401 when the refresh token is not valid.
+func RefreshHandler(config *JWTConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			RefreshToken string `json:"refresh_token" form:"refresh_token"`
+		}
+		if err := Bind(r, &in); err != nil {
+			_ = WriteJSON(w, http.StatusBadRequest, FailWithMessage(err.Error()))
+			return
+		}
+		pair, err := config.Refresh(r.Context(), in.RefreshToken)
+		switch {
+		case errors.Is(err, ErrInvalidRefreshToken), errors.Is(err, ErrTokenRevoked):
+			_ = WriteJSON(w, http.StatusUnauthorized, FailWithMessage(err.Error()))
+		case err != nil:
+			_ = WriteJSON(w, http.StatusInternalServerError,
+				FailWithMessage(http.StatusText(http.StatusInternalServerError)))
+		default:
+			_ = WriteJSON(w, http.StatusOK, OkWithData(pair))
+		}
+	}
+}
+
+// tokenMeta are the claims the middlewares read, whatever the type of the
+// claims of a token.
+type tokenMeta struct {
+	ID      string           `json:"jti"`
+	Type    string           `json:"typ"`
+	Expires *jwt.NumericDate `json:"exp"`
+}
+
+// tokenMetaOf decodes the payload of a parsed token rather than its claims,
+// which drop typ, or even jti and exp, when they are structs.
+func tokenMetaOf(token *jwt.Token) tokenMeta {
+	var meta tokenMeta
+	var raw []byte
+	if parts := strings.Split(token.Raw, "."); len(parts) == 3 {
+		raw, _ = jwt.DecodeSegment(parts[1])
+	}
+	if raw == nil {
+		raw, _ = json.Marshal(token.Claims)
+	}
+	_ = json.Unmarshal(raw, &meta)
+	return meta
+}
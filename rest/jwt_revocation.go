@@ -0,0 +1,89 @@
+package rest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/uaxe/infra/cache"
+)
+
+// RevocationList holds the IDs, jti claims, of the revoked tokens until
+// they expire. Revoke reports whether id was not revoked yet, atomically so
+// that a refresh token is used once whatever the concurrent refreshes.
+type RevocationList interface {
+	Revoke(ctx context.Context, id string, expires time.Time) (bool, error)
+	IsRevoked(ctx context.Context, id string) (bool, error)
+}
+
+var (
+	_ RevocationList = (*lruRevocationList)(nil)
+	_ RevocationList = (*redisRevocationList)(nil)
+)
+
+type lruRevocationList struct {
+	lock sync.Mutex
+	lru  *cache.LRUCache
+}
+
+// NewLRURevocationList keeps the revoked IDs in lru, local to the process.
+// The IDs evicted before their expiry are no longer revoked, lru must hold
+// as many entries as tokens revoked within their lifetime.
+func NewLRURevocationList(lru *cache.LRUCache) RevocationList {
+	return &lruRevocationList{lru: lru}
+}
+
+func (l *lruRevocationList) Revoke(_ context.Context, id string, expires time.Time) (bool, error) {
+	ttl := time.Until(expires)
+	if ttl <= 0 {
+		return true, nil
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if revoked, _ := l.isRevoked(id); revoked {
+		return false, nil
+	}
+	l.lru.Put(id, expires, ttl)
+	return true, nil
+}
+
+func (l *lruRevocationList) IsRevoked(_ context.Context, id string) (bool, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.isRevoked(id)
+}
+
+func (l *lruRevocationList) isRevoked(id string) (bool, error) {
+	v, ok := l.lru.Get(id)
+	if !ok {
+		return false, nil
+	}
+	expires, _ := v.(time.Time)
+	return time.Now().Before(expires), nil
+}
+
+type redisRevocationList struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisRevocationList keeps the revoked IDs in keys of prefix, shared
+// by the instances of a service and expiring with the tokens.
+func NewRedisRevocationList(client redis.UniversalClient, prefix string) RevocationList {
+	return &redisRevocationList{client: client, prefix: prefix}
+}
+
+func (l *redisRevocationList) Revoke(ctx context.Context, id string, expires time.Time) (bool, error) {
+	ttl := time.Until(expires)
+	if ttl <= 0 {
+		return true, nil
+	}
+	return l.client.SetNX(ctx, l.prefix+id, 1, ttl).Result()
+}
+
+func (l *redisRevocationList) IsRevoked(ctx context.Context, id string) (bool, error) {
+	n, err := l.client.Exists(ctx, l.prefix+id).Result()
+	return n > 0, err
+}
@@ -0,0 +1,282 @@
+package rest_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/uaxe/infra/cache"
+	"github.com/uaxe/infra/rest"
+	"github.com/uaxe/infra/schedule"
+)
+
+func TestJWTKeySetRotation(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+
+	k1, err := rest.NewJWTKey("k1", ecKey)
+	if err != nil || k1.Algorithm != rest.AlgorithmES256 {
+		t.Fatalf("%+v, %v", k1, err)
+	}
+	k2, err := rest.NewJWTKey("k2", edKey)
+	if err != nil || k2.Algorithm != rest.AlgorithmEdDSA {
+		t.Fatalf("%+v, %v", k2, err)
+	}
+	k3, err := rest.ParseJWTKeyPEM("k3", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil || k3.Algorithm != rest.AlgorithmRS256 {
+		t.Fatalf("%+v, %v", k3, err)
+	}
+
+	config := rest.JWT("", 60)
+	config.KeySet = rest.NewKeySet(k1)
+	sign := func() string {
+		token, _, err := config.DefaultTokenGenerator(func() (jwt.MapClaims, error) {
+			return jwt.MapClaims{"sub": "uaxe"}, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	tokens := []string{sign()}
+	for _, key := range []rest.JWTKey{k2, k3} {
+		if err = config.KeySet.Rotate(key); err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, sign())
+	}
+	for i, token := range tokens {
+		parsed, err := config.ParseTokenFunc(token)
+		if err != nil {
+			t.Fatalf("token %d: %v", i, err)
+		}
+		if kid := parsed.Header["kid"]; kid != []string{"k1", "k2", "k3"}[i] {
+			t.Fatalf("token %d signed by %v", i, kid)
+		}
+	}
+	config.KeySet.Remove("k1")
+	if _, err = config.ParseTokenFunc(tokens[0]); err == nil {
+		t.Fatal("token of a removed key still valid")
+	}
+}
+
+func TestJWTAuth_LookupRefreshRevocation(t *testing.T) {
+	tw := schedule.NewTimerWheel(100*time.Millisecond, 10)
+	defer tw.Stop()
+	config := rest.JWT("secret", 60)
+	config.TokenLookup = "query:token, cookie:jwt"
+	config.Revocation = rest.NewLRURevocationList(cache.NewLRUCache(context.Background(), 100, tw, nil))
+
+	r := rest.NewRouter()
+	r.GET("/me", func(w http.ResponseWriter, req *http.Request) {
+		claims, _ := rest.GetJWTClaims[jwt.MapClaims](req.Context())
+		_ = rest.WriteJSON(w, http.StatusOK, rest.OkWithData(claims["sub"]))
+	}, rest.JWTAuth(config))
+	r.POST("/logout", func(w http.ResponseWriter, req *http.Request) {
+		token, _ := rest.GetJWTToken(req.Context())
+		_ = config.Revoke(req.Context(), token)
+	}, rest.JWTAuth(config))
+	r.POST("/refresh", rest.RefreshHandler(config))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	status := func(method, path string, cookie *http.Cookie) int {
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	pair, err := config.GenerateTokenPair(jwt.MapClaims{"sub": "uaxe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := status(http.MethodGet, "/me?token="+pair.AccessToken, nil); code != http.StatusOK {
+		t.Fatalf("query token: %d", code)
+	}
+	if code := status(http.MethodGet, "/me", &http.Cookie{Name: "jwt", Value: pair.AccessToken}); code != http.StatusOK {
+		t.Fatalf("cookie token: %d", code)
+	}
+	if code := status(http.MethodGet, "/me?token="+pair.RefreshToken, nil); code != http.StatusUnauthorized {
+		t.Fatalf("refresh token accepted as access token: %d", code)
+	}
+
+	ctx := context.Background()
+	renewed, err := config.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = config.Refresh(ctx, pair.RefreshToken); !errors.Is(err, rest.ErrTokenRevoked) {
+		t.Fatalf("refresh token reused: %v", err)
+	}
+	if code := status(http.MethodGet, "/me?token="+renewed.AccessToken, nil); code != http.StatusOK {
+		t.Fatalf("renewed token: %d", code)
+	}
+	resp, err := http.Post(srv.URL+"/refresh", "application/json",
+		strings.NewReader(`{"refresh_token":"`+renewed.RefreshToken+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Data rest.TokenPair `json:"data"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || out.Data.AccessToken == "" || out.Data.TokenType != "Bearer" {
+		t.Fatalf("refresh handler: %d %+v", resp.StatusCode, out.Data)
+	}
+
+	if code := status(http.MethodPost, "/logout?token="+renewed.AccessToken, nil); code != http.StatusOK {
+		t.Fatalf("logout: %d", code)
+	}
+	if code := status(http.MethodGet, "/me?token="+renewed.AccessToken, nil); code != http.StatusUnauthorized {
+		t.Fatalf("revoked token accepted: %d", code)
+	}
+}
+
+func TestJWTAuth_StructClaims(t *testing.T) {
+	tw := schedule.NewTimerWheel(100*time.Millisecond, 10)
+	defer tw.Stop()
+	config := rest.JWT("secret", 60)
+	config.Claims = &jwt.RegisteredClaims{}
+	config.Revocation = rest.NewLRURevocationList(cache.NewLRUCache(context.Background(), 100, tw, nil))
+	h := rest.JWTAuth(config)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		claims, _ := rest.GetJWTClaims[*jwt.RegisteredClaims](req.Context())
+		_ = rest.WriteJSON(w, http.StatusOK, rest.OkWithData(claims.Subject))
+	}))
+	status := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	pair, err := config.GenerateTokenPair(jwt.MapClaims{"sub": "uaxe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := status(pair.AccessToken); code != http.StatusOK {
+		t.Fatalf("access token: %d", code)
+	}
+	if code := status(pair.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("refresh token accepted as access token: %d", code)
+	}
+	token, _ := config.ParseTokenFunc(pair.AccessToken)
+	if err = config.Revoke(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+	if code := status(pair.AccessToken); code != http.StatusUnauthorized {
+		t.Fatalf("revoked token accepted: %d", code)
+	}
+}
+
+func TestJWTConfig_RefreshOnce(t *testing.T) {
+	tw := schedule.NewTimerWheel(100*time.Millisecond, 10)
+	defer tw.Stop()
+	config := rest.JWT("secret", 60)
+	config.Revocation = rest.NewLRURevocationList(cache.NewLRUCache(context.Background(), 100, tw, nil))
+	pair, err := config.GenerateTokenPair(jwt.MapClaims{"sub": "uaxe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var refreshed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := config.Refresh(context.Background(), pair.RefreshToken)
+			switch {
+			case err == nil:
+				refreshed.Add(1)
+			case !errors.Is(err, rest.ErrTokenRevoked):
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := refreshed.Load(); n != 1 {
+		t.Fatalf("refresh token used %d times", n)
+	}
+}
+
+func TestRedisRevocationList(t *testing.T) {
+	mr := miniredis.RunT(t)
+	list := rest.NewRedisRevocationList(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "revoked:")
+	ctx := context.Background()
+	if revoked, err := list.Revoke(ctx, "jti", time.Now().Add(time.Minute)); err != nil || !revoked {
+		t.Fatalf("%v, %v", revoked, err)
+	}
+	if revoked, err := list.Revoke(ctx, "jti", time.Now().Add(time.Minute)); err != nil || revoked {
+		t.Fatalf("revoked twice: %v, %v", revoked, err)
+	}
+	if revoked, err := list.IsRevoked(ctx, "jti"); err != nil || !revoked {
+		t.Fatalf("%v, %v", revoked, err)
+	}
+	mr.FastForward(2 * time.Minute)
+	if revoked, _ := list.IsRevoked(ctx, "jti"); revoked {
+		t.Fatal("revocation outlived the token")
+	}
+}
+
+func TestJWTAuth_RevocationDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	config := rest.JWT("secret", 60)
+	config.Revocation = rest.NewRedisRevocationList(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "revoked:")
+	r := rest.NewRouter()
+	r.GET("/me", func(w http.ResponseWriter, req *http.Request) {}, rest.JWTAuth(config))
+	r.POST("/refresh", rest.RefreshHandler(config))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	pair, err := config.GenerateTokenPair(jwt.MapClaims{"sub": "uaxe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.Close()
+	check := func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var body rest.Response
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		if resp.StatusCode != http.StatusInternalServerError || body.Msg != http.StatusText(http.StatusInternalServerError) {
+			t.Fatalf("%s: expected a generic 500, got %d %q", req.URL.Path, resp.StatusCode, body.Msg)
+		}
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/me", nil)
+	check(req)
+	req, _ = http.NewRequest(http.MethodPost, srv.URL+"/refresh", strings.NewReader(`{"refresh_token":"`+pair.RefreshToken+`"}`))
+	check(req)
+}
@@ -0,0 +1,459 @@
+package rest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const HeaderRequestID = "X-Request-Id"
+
+// Recovery answers 500 to the requests whose handler panics, logging the
+// panic and its stack.
+func Recovery(logger *zap.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if err, ok := v.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(v)
+				}
+				logger.Error("panic recovered",
+					zap.String("method", r.Method),
+					zap.String("path", r.URL.Path),
+					zap.String("request_id", GetRequestID(r.Context())),
+					zap.Any("panic", v),
+					zap.ByteString("stack", debug.Stack()))
+				_ = WriteJSON(w, http.StatusInternalServerError,
+					FailWithMessage(http.StatusText(http.StatusInternalServerError)))
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type requestIDKey struct{}
+
+// RequestID keeps the X-Request-Id header of the request, or generates one,
+// and sets it in the response and the context of the request.
+func RequestID() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(HeaderRequestID)
+			if id == "" {
+				id = newRequestID()
+			}
+			w.Header().Set(HeaderRequestID, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+		})
+	}
+}
+
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// AccessLog logs every request once answered, at the error level for 5xx
+// statuses and the warn level for 4xx ones. The client IP is the address of
+// the peer, or the one it forwarded in X-Forwarded-For or X-Real-Ip when the
+// peer is one of trustedProxies, IPs or CIDRs such as "10.0.0.0/8".
+//
+// AccessLog panics when a trusted proxy is invalid.
+func AccessLog(logger *zap.Logger, trustedProxies ...string) Middleware {
+	proxies, err := parseProxies(trustedProxies)
+	if err != nil {
+		panic(err)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			// filled by the router once routed
+			info := &routeInfo{}
+			r = r.WithContext(context.WithValue(r.Context(), routeKey{}, info))
+			next.ServeHTTP(sw, r)
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			fields := []zap.Field{
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("route", info.pattern),
+				zap.Int("status", status),
+				zap.Int64("size", sw.size),
+				zap.Duration("latency", time.Since(start)),
+				zap.String("ip", proxies.clientIP(r)),
+				zap.String("user_agent", r.UserAgent()),
+			}
+			if id := w.Header().Get(HeaderRequestID); id != "" {
+				fields = append(fields, zap.String("request_id", id))
+			}
+			switch {
+			case status >= 500:
+				logger.Error("request", fields...)
+			case status >= 400:
+				logger.Warn("request", fields...)
+			default:
+				logger.Info("request", fields...)
+			}
+		})
+	}
+}
+
+type proxies []*net.IPNet
+
+func parseProxies(list []string) (proxies, error) {
+	var nets proxies
+	for _, proxy := range list {
+		cidr := proxy
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("rest: invalid trusted proxy %q", proxy)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func (p proxies) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range p {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the peer of r, unless it is a trusted
+// proxy: then the rightmost address of X-Forwarded-For not of a trusted
+// proxy, since the leftmost ones are set by the client.
+func (p proxies) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !p.trusted(ip) {
+		return ip
+	}
+	if fwd := r.Header.Values("X-Forwarded-For"); len(fwd) > 0 {
+		hops := strings.Split(strings.Join(fwd, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+			if ip = hop; !p.trusted(ip) {
+				break
+			}
+		}
+		return ip
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-Ip")); realIP != "" {
+		return realIP
+	}
+	return ip
+}
+
+// statusWriter records the status and the size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type CORSConfig struct {
+	// AllowOrigins are origins such as "https://example.com", with a
+	// wildcard subdomain as in "https://*.example.com" or "*" for any.
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+var DefaultCORSConfig = CORSConfig{
+	AllowOrigins: []string{"*"},
+	AllowMethods: []string{
+		http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete,
+	},
+}
+
+// CORS sets the CORS headers of the requests from the allowed origins and
+// answers their preflight requests. The headers of a preflight request are
+// allowed when AllowHeaders is empty.
+func CORS(config CORSConfig) Middleware {
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = DefaultCORSConfig.AllowMethods
+	}
+	methods := strings.Join(config.AllowMethods, ", ")
+	headers := strings.Join(config.AllowHeaders, ", ")
+	expose := strings.Join(config.ExposeHeaders, ", ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			allowed, wildcard := config.allowOrigin(origin)
+			if !allowed {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if wildcard && !config.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if config.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight {
+				if expose != "" {
+					h.Set("Access-Control-Expose-Headers", expose)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", methods)
+			if headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			} else if req := r.Header.Get("Access-Control-Request-Headers"); req != "" {
+				h.Set("Access-Control-Allow-Headers", req)
+			}
+			if config.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge/time.Second)))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// allowOrigin reports whether origin is allowed, and whether by "*".
+func (config CORSConfig) allowOrigin(origin string) (allowed, wildcard bool) {
+	for _, o := range config.AllowOrigins {
+		if o == "*" {
+			return true, true
+		}
+		if strings.EqualFold(o, origin) {
+			return true, false
+		}
+		if prefix, suffix, ok := strings.Cut(o, "*"); ok &&
+			len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// Gzip compresses the responses of the requests accepting gzip, at level,
+// gzip.DefaultCompression when invalid.
+func Gzip(level int) Middleware {
+	if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+		level = gzip.DefaultCompression
+	}
+	pool := &sync.Pool{New: func() any {
+		gz, _ := gzip.NewWriterLevel(io.Discard, level)
+		return gz
+	}}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Accept-Encoding")
+			gw := &gzipWriter{ResponseWriter: w, pool: pool}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.TrimSpace(name) == "gzip" && strings.ReplaceAll(params, " ", "") != "q=0" {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipWriter compresses the body unless the response is already encoded or
+// has no body.
+type gzipWriter struct {
+	http.ResponseWriter
+	pool        *sync.Pool
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if code != http.StatusNoContent && code != http.StatusNotModified && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = w.pool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			// sniffed on the compressed body otherwise
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.gz.Write(p)
+}
+
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *gzipWriter) close() {
+	if w.gz == nil {
+		return
+	}
+	_ = w.gz.Close()
+	w.gz.Reset(io.Discard)
+	w.pool.Put(w.gz)
+	w.gz = nil
+}
+
+// Timeout bounds the handling of requests to d, their context being
+// canceled then and the client answered 503. The response is buffered, see
+// http.TimeoutHandler.
+func Timeout(d time.Duration) Middleware {
+	msg := fmt.Sprintf(`{"status":%d,"data":{},"msg":%q}`, StatusError, "timeout")
+	return func(next http.Handler) http.Handler {
+		h := http.TimeoutHandler(next, d, msg)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(&timeoutWriter{ResponseWriter: w}, r)
+		})
+	}
+}
+
+// timeoutWriter types the 503 of http.TimeoutHandler as JSON. The headers of
+// the handler are copied before its own statuses are written, so that its
+// 503 responses keep their Content-Type.
+type timeoutWriter struct {
+	http.ResponseWriter
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
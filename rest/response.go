@@ -1,5 +1,10 @@
 package rest
 
+import (
+	"encoding/json"
+	"net/http"
+)
+
 type Response struct {
 	Status int    `json:"status"`
 	Data   any    `json:"data"`
@@ -45,3 +50,10 @@ func Msg(msg string, msgs ...string) string {
 	}
 	return msg
 }
+
+// WriteJSON writes v encoded in JSON with the status code.
+func WriteJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
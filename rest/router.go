@@ -0,0 +1,322 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Middleware wraps a handler, e.g. to log its requests.
+type Middleware func(next http.Handler) http.Handler
+
+// Chain wraps h with mws, the first middleware being the outermost.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// Router is an http.Handler dispatching requests by method and path.
+// Patterns are made of static segments, ":name" segments matching one
+// segment and a last "*name" segment matching the rest of the path:
+//
+//	r := rest.NewRouter()
+//	r.Use(rest.Recovery(logger), rest.RequestID(), rest.AccessLog(logger))
+//	api := r.Group("/api/v1", rest.JWTAuth(config))
+//	api.GET("/users/:id", getUser)
+//	r.GET("/static/*path", serveStatic)
+//
+// Static segments take precedence over parameters, which take precedence
+// over wildcards.
+type Router struct {
+	*RouteGroup
+	root             *node
+	middlewares      []Middleware
+	handler          http.Handler
+	notFound         http.Handler
+	methodNotAllowed http.Handler
+}
+
+type RouterOption func(r *Router)
+
+func WithNotFound(h http.Handler) RouterOption {
+	return func(r *Router) {
+		r.notFound = h
+	}
+}
+
+// WithMethodNotAllowed answers the requests of a path registered for other
+// methods, the Allow header being set beforehand.
+func WithMethodNotAllowed(h http.Handler) RouterOption {
+	return func(r *Router) {
+		r.methodNotAllowed = h
+	}
+}
+
+func NewRouter(opts ...RouterOption) *Router {
+	r := &Router{
+		root:     &node{},
+		notFound: http.HandlerFunc(http.NotFound),
+		methodNotAllowed: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}),
+	}
+	r.RouteGroup = &RouteGroup{router: r}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.handler = http.HandlerFunc(r.route)
+	return r
+}
+
+// Use appends middlewares run for every request, before routing: they also
+// see the requests matching no route, such as CORS preflight requests.
+func (r *Router) Use(mws ...Middleware) {
+	r.middlewares = append(r.middlewares, mws...)
+	r.handler = Chain(http.HandlerFunc(r.route), r.middlewares...)
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.handler.ServeHTTP(w, req)
+}
+
+func (r *Router) route(w http.ResponseWriter, req *http.Request) {
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	params := make(map[string]string)
+	n := r.root.match(splitPath(path), params)
+	if n == nil {
+		r.notFound.ServeHTTP(w, req)
+		return
+	}
+	h, ok := n.handlers[req.Method]
+	if !ok && req.Method == http.MethodHead {
+		h, ok = n.handlers[http.MethodGet]
+	}
+	if !ok {
+		w.Header().Set("Allow", n.allow())
+		r.methodNotAllowed.ServeHTTP(w, req)
+		return
+	}
+	info, ok := req.Context().Value(routeKey{}).(*routeInfo)
+	if !ok {
+		info = &routeInfo{}
+		req = req.WithContext(context.WithValue(req.Context(), routeKey{}, info))
+	}
+	info.pattern, info.params = n.pattern, params
+	h.ServeHTTP(w, req)
+}
+
+// RouteGroup registers routes under a path prefix, wrapped by its middlewares.
+type RouteGroup struct {
+	router      *Router
+	prefix      string
+	middlewares []Middleware
+}
+
+// Group returns a group of the routes under prefix, the middlewares of g
+// wrapping those of the new group.
+func (g *RouteGroup) Group(prefix string, mws ...Middleware) *RouteGroup {
+	return &RouteGroup{
+		router:      g.router,
+		prefix:      joinPath(g.prefix, prefix),
+		middlewares: append(append([]Middleware(nil), g.middlewares...), mws...),
+	}
+}
+
+// With appends middlewares to the routes registered afterwards in g.
+func (g *RouteGroup) With(mws ...Middleware) {
+	g.middlewares = append(g.middlewares, mws...)
+}
+
+// Handle registers h for method and pattern, it panics when the route is
+// already registered or the pattern is invalid.
+func (g *RouteGroup) Handle(method, pattern string, h http.Handler, mws ...Middleware) {
+	pattern = joinPath(g.prefix, pattern)
+	mws = append(append([]Middleware(nil), g.middlewares...), mws...)
+	if err := g.router.root.insert(method, pattern, Chain(h, mws...)); err != nil {
+		panic(err)
+	}
+}
+
+func (g *RouteGroup) HandleFunc(method, pattern string, h http.HandlerFunc, mws ...Middleware) {
+	g.Handle(method, pattern, h, mws...)
+}
+
+func (g *RouteGroup) GET(pattern string, h http.HandlerFunc, mws ...Middleware) {
+	g.Handle(http.MethodGet, pattern, h, mws...)
+}
+
+func (g *RouteGroup) POST(pattern string, h http.HandlerFunc, mws ...Middleware) {
+	g.Handle(http.MethodPost, pattern, h, mws...)
+}
+
+func (g *RouteGroup) PUT(pattern string, h http.HandlerFunc, mws ...Middleware) {
+	g.Handle(http.MethodPut, pattern, h, mws...)
+}
+
+func (g *RouteGroup) PATCH(pattern string, h http.HandlerFunc, mws ...Middleware) {
+	g.Handle(http.MethodPatch, pattern, h, mws...)
+}
+
+func (g *RouteGroup) DELETE(pattern string, h http.HandlerFunc, mws ...Middleware) {
+	g.Handle(http.MethodDelete, pattern, h, mws...)
+}
+
+func (g *RouteGroup) OPTIONS(pattern string, h http.HandlerFunc, mws ...Middleware) {
+	g.Handle(http.MethodOptions, pattern, h, mws...)
+}
+
+type routeKey struct{}
+
+type routeInfo struct {
+	pattern string
+	params  map[string]string
+}
+
+// Param returns the value of the path parameter name of the route of req.
+func Param(req *http.Request, name string) string {
+	if info, ok := req.Context().Value(routeKey{}).(*routeInfo); ok {
+		return info.params[name]
+	}
+	return ""
+}
+
+// Params returns the path parameters of the route of req.
+func Params(req *http.Request) map[string]string {
+	if info, ok := req.Context().Value(routeKey{}).(*routeInfo); ok {
+		return info.params
+	}
+	return nil
+}
+
+// RoutePattern returns the pattern of the route of req, e.g. to label
+// metrics without the cardinality of the paths.
+func RoutePattern(req *http.Request) string {
+	if info, ok := req.Context().Value(routeKey{}).(*routeInfo); ok {
+		return info.pattern
+	}
+	return ""
+}
+
+type node struct {
+	static    map[string]*node
+	param     *node
+	paramName string
+	wildcard  *node
+	wildName  string
+
+	pattern  string
+	handlers map[string]http.Handler
+}
+
+func (n *node) insert(method, pattern string, h http.Handler) error {
+	segments := splitPath(pattern)
+	for i, seg := range segments {
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			name := seg[1:]
+			if name == "" {
+				return fmt.Errorf("rest: empty parameter name in %q", pattern)
+			}
+			if n.param == nil {
+				n.param, n.paramName = &node{}, name
+			} else if n.paramName != name {
+				return fmt.Errorf("rest: parameter %q of %q conflicts with %q", name, pattern, n.paramName)
+			}
+			n = n.param
+		case strings.HasPrefix(seg, "*"):
+			name := seg[1:]
+			if name == "" || i != len(segments)-1 {
+				return fmt.Errorf("rest: wildcard of %q must be named and last", pattern)
+			}
+			if n.wildcard == nil {
+				n.wildcard, n.wildName = &node{}, name
+			} else if n.wildName != name {
+				return fmt.Errorf("rest: wildcard %q of %q conflicts with %q", name, pattern, n.wildName)
+			}
+			n = n.wildcard
+		default:
+			if n.static == nil {
+				n.static = make(map[string]*node)
+			}
+			child, ok := n.static[seg]
+			if !ok {
+				child = &node{}
+				n.static[seg] = child
+			}
+			n = child
+		}
+	}
+	if n.handlers == nil {
+		n.handlers = make(map[string]http.Handler)
+	}
+	if _, ok := n.handlers[method]; ok {
+		return fmt.Errorf("rest: route %s %s already registered", method, pattern)
+	}
+	n.pattern = "/" + strings.Join(segments, "/")
+	n.handlers[method] = h
+	return nil
+}
+
+// match returns the node of the route matching segments, filling params.
+func (n *node) match(segments []string, params map[string]string) *node {
+	if len(segments) == 0 {
+		if n.handlers != nil {
+			return n
+		}
+		if n.wildcard != nil && n.wildcard.handlers != nil {
+			params[n.wildName] = ""
+			return n.wildcard
+		}
+		return nil
+	}
+	seg, rest := segments[0], segments[1:]
+	if child, ok := n.static[seg]; ok {
+		if found := child.match(rest, params); found != nil {
+			return found
+		}
+	}
+	if n.param != nil {
+		if found := n.param.match(rest, params); found != nil {
+			params[n.paramName] = seg
+			return found
+		}
+	}
+	if n.wildcard != nil && n.wildcard.handlers != nil {
+		params[n.wildName] = strings.Join(segments, "/")
+		return n.wildcard
+	}
+	return nil
+}
+
+func (n *node) allow() string {
+	methods := make([]string, 0, len(n.handlers))
+	for m := range n.handlers {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// splitPath returns the segments of path, ignoring empty ones so that
+// "/users/" matches "/users".
+func splitPath(path string) []string {
+	parts := strings.Split(path, "/")
+	segments := parts[:0]
+	for _, p := range parts {
+		if p != "" {
+			segments = append(segments, p)
+		}
+	}
+	return segments
+}
+
+func joinPath(prefix, path string) string {
+	return "/" + strings.Join(splitPath(prefix+"/"+path), "/")
+}
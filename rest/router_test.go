@@ -0,0 +1,221 @@
+package rest_test
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/uaxe/infra/rest"
+)
+
+type updateUser struct {
+	ID      int64         `path:"id"`
+	DryRun  bool          `query:"dry_run"`
+	Tags    []string      `query:"tag"`
+	TraceID string        `header:"X-Trace-Id"`
+	TTL     time.Duration `header:"X-TTL"`
+	Name    string        `json:"name" form:"name"`
+}
+
+func TestRouter(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logger := zap.New(core)
+
+	r := rest.NewRouter()
+	r.Use(rest.Recovery(logger), rest.RequestID(), rest.AccessLog(logger), rest.CORS(rest.DefaultCORSConfig))
+	api := r.Group("/api/v1")
+	api.PUT("/users/:id", func(w http.ResponseWriter, req *http.Request) {
+		var in updateUser
+		if err := rest.Bind(req, &in); err != nil {
+			_ = rest.WriteJSON(w, http.StatusBadRequest, rest.FailWithMessage(err.Error()))
+			return
+		}
+		_ = rest.WriteJSON(w, http.StatusOK, rest.OkWithData(in))
+	})
+	api.GET("/users/me", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, "me")
+	})
+	api.GET("/files/*path", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, rest.Param(req, "path"))
+	})
+	r.GET("/panic", func(http.ResponseWriter, *http.Request) { panic("boom") })
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL+"/api/v1/users/42?dry_run=true&tag=a&tag=b", strings.NewReader(`{"id":999,"name":"uaxe"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Trace-Id", "trace")
+	req.Header.Set("X-TTL", "1m")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Data updateUser `json:"data"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	_ = resp.Body.Close()
+	want := updateUser{ID: 42, DryRun: true, Tags: []string{"a", "b"}, TraceID: "trace", TTL: time.Minute, Name: "uaxe"}
+	if resp.StatusCode != http.StatusOK || out.Data.ID != want.ID || !out.Data.DryRun || len(out.Data.Tags) != 2 ||
+		out.Data.TraceID != want.TraceID || out.Data.TTL != want.TTL || out.Data.Name != want.Name {
+		t.Fatalf("%d %+v", resp.StatusCode, out.Data)
+	}
+	if resp.Header.Get(rest.HeaderRequestID) == "" {
+		t.Fatal("request id not set")
+	}
+
+	form := url.Values{"name": {"form"}}
+	req, _ = http.NewRequest(http.MethodPut, srv.URL+"/api/v1/users/7", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	_ = resp.Body.Close()
+	if out.Data.ID != 7 || out.Data.Name != "form" {
+		t.Fatalf("%+v", out.Data)
+	}
+
+	resp, err = http.Post(srv.URL+"/api/v1/users/7", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != http.MethodPut {
+		t.Fatalf("%d, Allow %q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+
+	for path, body := range map[string]string{
+		"/api/v1/users/me":      "me",
+		"/api/v1/files/a/b.txt": "a/b.txt",
+	} {
+		resp, err = http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if string(raw) != body {
+			t.Fatalf("%s: %q", path, raw)
+		}
+	}
+
+	resp, err = http.Get(srv.URL + "/panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError || logs.FilterMessage("panic recovered").Len() != 1 {
+		t.Fatalf("panic not recovered: %d", resp.StatusCode)
+	}
+	entries := logs.FilterMessage("request").FilterField(zap.String("route", "/api/v1/users/:id")).All()
+	if len(entries) != 2 {
+		t.Fatalf("%d access logs of the route", len(entries))
+	}
+
+	req, _ = http.NewRequest(http.MethodOptions, srv.URL+"/api/v1/users/1", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("preflight %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestMiddlewares(t *testing.T) {
+	config := rest.JWT("secret", 60)
+	token, _, err := config.DefaultTokenGenerator(func() (jwt.MapClaims, error) {
+		return jwt.MapClaims{"sub": "uaxe"}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := rest.NewRouter()
+	r.Use(rest.Gzip(gzip.BestSpeed))
+	r.GET("/me", func(w http.ResponseWriter, req *http.Request) {
+		token, _ := rest.GetJWTToken(req.Context())
+		_, _ = io.WriteString(w, strings.Repeat(token.Claims.(jwt.MapClaims)["sub"].(string), 100))
+	}, rest.JWTAuth(config))
+	r.GET("/slow", func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	}, rest.Timeout(10*time.Millisecond))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status %d without token", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("not compressed: %v", resp.Header)
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := io.ReadAll(gz)
+	_ = resp.Body.Close()
+	if string(raw) != strings.Repeat("uaxe", 100) {
+		t.Fatalf("%q", raw)
+	}
+
+	resp, err = http.Get(srv.URL + "/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("status %d, %q of a timeout", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestAccessLog_ClientIP(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ok := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for _, tt := range []struct {
+		proxies    []string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{nil, "203.0.113.7:1234", "1.2.3.4", "203.0.113.7"},
+		{[]string{"10.0.0.0/8"}, "203.0.113.7:1234", "1.2.3.4", "203.0.113.7"},
+		{[]string{"10.0.0.0/8"}, "10.0.0.1:1234", "1.2.3.4, 198.51.100.9, 10.0.0.2", "198.51.100.9"},
+		{[]string{"10.0.0.1", "10.0.0.2"}, "10.0.0.1:1234", "10.0.0.2", "10.0.0.2"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Forwarded-For", tt.forwarded)
+		rest.AccessLog(zap.New(core), tt.proxies...)(ok).ServeHTTP(httptest.NewRecorder(), req)
+		entries := logs.TakeAll()
+		if ip := entries[0].ContextMap()["ip"]; ip != tt.want {
+			t.Fatalf("%v %s %s: ip %v", tt.proxies, tt.remoteAddr, tt.forwarded, ip)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uaxe/infra/clock"
+)
+
+// CronFunc is the work run on every activation. The context is canceled
+// when the job is stopped or the cron's context is done.
+type CronFunc func(ctx context.Context) error
+
+var ErrCronStopped = errors.New("cron is stopped")
+
+type (
+	CronOption func(c *Cron)
+
+	CronJobOption func(j *CronJob)
+
+	Cron struct {
+		ctx      context.Context
+		cancel   context.CancelFunc
+		location *time.Location
+		onError  func(job *CronJob, err error)
+		elector  *Elector
+		clock    clock.Clock
+		lock     sync.Mutex
+		jobs     map[uint64]*CronJob
+		wg       sync.WaitGroup
+	}
+)
+
+// WithLocation evaluates expressions without an explicit time zone in loc.
+func WithLocation(loc *time.Location) CronOption {
+	return func(c *Cron) {
+		c.location = loc
+	}
+}
+
+// WithErrorHandler receives errors returned by jobs and recovered panics.
+func WithErrorHandler(fn func(job *CronJob, err error)) CronOption {
+	return func(c *Cron) {
+		c.onError = fn
+	}
+}
+
+// WithCronElector runs every activation once among the instances of e, on
+// the leader. Jobs are told apart by their name, see WithJobName.
+func WithCronElector(e *Elector) CronOption {
+	return func(c *Cron) {
+		c.elector = e
+	}
+}
+
+// WithJobName names the job among those of the elector of the cron, its
+// spec by default. Jobs of the same spec, or added by Schedule, must be
+// named for their activations not to be claimed by one another.
+func WithJobName(name string) CronJobOption {
+	return func(j *CronJob) {
+		j.name = name
+	}
+}
+
+// WithCronClock drives the jobs by c instead of the real time.
+func WithCronClock(clk clock.Clock) CronOption {
+	return func(c *Cron) {
+		c.clock = clk
+	}
+}
+
+func NewCron(ctx context.Context, opts ...CronOption) *Cron {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &Cron{
+		ctx:      ctx,
+		cancel:   cancel,
+		location: time.Local,
+		clock:    clock.Real,
+		jobs:     make(map[uint64]*CronJob),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// AddFunc schedules fn by the cron expression spec, see ParseCron.
+func (c *Cron) AddFunc(spec string, fn CronFunc, opts ...CronJobOption) (*CronJob, error) {
+	sched, err := parseCron(spec, c.location)
+	if err != nil {
+		return nil, err
+	}
+	return c.schedule(sched, fn, spec, opts)
+}
+
+// Schedule runs fn on every activation of sched.
+func (c *Cron) Schedule(sched Schedule, fn CronFunc, opts ...CronJobOption) (*CronJob, error) {
+	return c.schedule(sched, fn, "", opts)
+}
+
+func (c *Cron) schedule(sched Schedule, fn CronFunc, spec string, opts []CronJobOption) (*CronJob, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.ctx.Err() != nil {
+		return nil, ErrCronStopped
+	}
+	ctx, cancel := context.WithCancel(c.ctx)
+	job := &CronJob{
+		id:       atomic.AddUint64(&cronJobIds, 1),
+		spec:     spec,
+		name:     spec,
+		schedule: sched,
+		fn:       fn,
+		cron:     c,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		next:     sched.Next(c.clock.Now()),
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	c.jobs[job.id] = job
+	c.wg.Add(1)
+	go job.loop()
+	return job, nil
+}
+
+// Jobs returns the active jobs ordered by their next activation.
+func (c *Cron) Jobs() []*CronJob {
+	c.lock.Lock()
+	jobs := make([]*CronJob, 0, len(c.jobs))
+	for _, job := range c.jobs {
+		jobs = append(jobs, job)
+	}
+	c.lock.Unlock()
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Next().Before(jobs[j].Next())
+	})
+	return jobs
+}
+
+// Job looks up an active job by id.
+func (c *Cron) Job(id uint64) (*CronJob, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	job, ok := c.jobs[id]
+	return job, ok
+}
+
+// Stop cancels every job and waits for running activations to return
+// or ctx to be done.
+func (c *Cron) Stop(ctx context.Context) error {
+	c.cancel()
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Cron) remove(job *CronJob) {
+	c.lock.Lock()
+	delete(c.jobs, job.id)
+	c.lock.Unlock()
+}
+
+var cronJobIds uint64
+
+// CronJob is the handle of a scheduled function.
+type CronJob struct {
+	runs     uint64
+	id       uint64
+	spec     string
+	name     string
+	schedule Schedule
+	fn       CronFunc
+	cron     *Cron
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	lock sync.RWMutex
+	next time.Time
+	prev time.Time
+}
+
+func (j *CronJob) ID() uint64 {
+	return j.id
+}
+
+func (j *CronJob) Spec() string {
+	return j.spec
+}
+
+func (j *CronJob) Name() string {
+	return j.name
+}
+
+// Next returns the upcoming activation, zero once the job is stopped.
+func (j *CronJob) Next() time.Time {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	return j.next
+}
+
+// Prev returns the last activation, zero if the job has not run yet.
+func (j *CronJob) Prev() time.Time {
+	j.lock.RLock()
+	defer j.lock.RUnlock()
+	return j.prev
+}
+
+// Runs returns the number of activations so far.
+func (j *CronJob) Runs() uint64 {
+	return atomic.LoadUint64(&j.runs)
+}
+
+// Stop cancels the job; activations already running see their context done.
+func (j *CronJob) Stop() {
+	j.cancel()
+}
+
+// Done is closed once the job has stopped and its running activations returned.
+func (j *CronJob) Done() <-chan struct{} {
+	return j.done
+}
+
+func (j *CronJob) loop() {
+	var running sync.WaitGroup
+	defer func() {
+		j.cron.remove(j)
+		j.lock.Lock()
+		j.next = time.Time{}
+		j.lock.Unlock()
+		running.Wait()
+		j.cancel()
+		close(j.done)
+		j.cron.wg.Done()
+	}()
+
+	next := j.Next()
+	for !next.IsZero() {
+		at := next
+		timer := j.cron.clock.NewTimer(at.Sub(j.cron.clock.Now()))
+		select {
+		case <-j.ctx.Done():
+			timer.Stop()
+			return
+		case fired := <-timer.C():
+			// keep the cadence unless we fell behind, then skip missed runs
+			base := at
+			if fired.Sub(at) > time.Second {
+				base = fired
+			}
+			j.lock.Lock()
+			j.prev, j.next = at, j.schedule.Next(base)
+			next = j.next
+			j.lock.Unlock()
+		}
+		atomic.AddUint64(&j.runs, 1)
+
+		running.Add(1)
+		go func(keep time.Duration) {
+			defer running.Done()
+			j.run(at, keep)
+		}(next.Sub(at))
+	}
+}
+
+// run runs the activation scheduled at at, the next one being due in keep.
+func (j *CronJob) run(at time.Time, keep time.Duration) {
+	defer func() {
+		if p := recover(); p != nil {
+			j.report(fmt.Errorf("cron job %d panic: %v\n%s", j.id, p, debug.Stack()))
+		}
+	}()
+	ctx, ok, err := claim(j.ctx, j.cron.elector, j.name, at, keep)
+	if err != nil {
+		j.report(err)
+	}
+	if !ok {
+		return
+	}
+	if err := j.fn(ctx); err != nil {
+		j.report(err)
+	}
+}
+
+func (j *CronJob) report(err error) {
+	if j.cron.onError != nil {
+		j.cron.onError(j, err)
+	}
+}
@@ -0,0 +1,361 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule describes a job's duty cycle.
+type Schedule interface {
+	// Next returns the next activation time, later than the given time.
+	// A zero time means the schedule will never fire again.
+	Next(time.Time) time.Time
+}
+
+var ErrCronSpec = errors.New("invalid cron spec")
+
+type cronBounds struct {
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	cronSeconds = cronBounds{min: 0, max: 59}
+	cronMinutes = cronBounds{min: 0, max: 59}
+	cronHours   = cronBounds{min: 0, max: 23}
+	cronDom     = cronBounds{min: 1, max: 31}
+	cronMonths  = cronBounds{min: 1, max: 12, names: map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	cronDow = cronBounds{min: 0, max: 6, names: map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// cronStar marks a field that was given as "*" or "?", which matters for
+// the day-of-month / day-of-week combination rule.
+const cronStar = 1 << 63
+
+// SpecSchedule is a cron expression compiled into bit sets, one per field.
+type SpecSchedule struct {
+	Second, Minute, Hour, Dom, Month, Dow uint64
+
+	// Location overrides the location of the time passed to Next.
+	Location *time.Location
+}
+
+var _ Schedule = (*SpecSchedule)(nil)
+
+// EverySchedule fires at a fixed interval, as produced by "@every <duration>".
+type EverySchedule struct {
+	Every time.Duration
+}
+
+var _ Schedule = (*EverySchedule)(nil)
+
+func (s *EverySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.Every)
+}
+
+// ParseCron parses a standard 5-field (minute hour dom month dow) or 6-field
+// (second minute hour dom month dow) cron expression, or one of the
+// descriptors @yearly, @annually, @monthly, @weekly, @daily, @midnight,
+// @hourly and "@every <duration>". The expression may be prefixed with
+// "CRON_TZ=<zone> " or "TZ=<zone> " to evaluate it in that time zone.
+func ParseCron(spec string) (Schedule, error) {
+	return parseCron(spec, nil)
+}
+
+func parseCron(spec string, loc *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if len(spec) == 0 {
+		return nil, fmt.Errorf("%w: empty spec", ErrCronSpec)
+	}
+
+	if strings.HasPrefix(spec, "TZ=") || strings.HasPrefix(spec, "CRON_TZ=") {
+		i := strings.IndexByte(spec, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("%w: missing fields after %q", ErrCronSpec, spec)
+		}
+		name := spec[strings.IndexByte(spec, '=')+1 : i]
+		var err error
+		if loc, err = time.LoadLocation(name); err != nil {
+			return nil, fmt.Errorf("%w: bad location %q: %v", ErrCronSpec, name, err)
+		}
+		spec = strings.TrimSpace(spec[i:])
+	}
+
+	if strings.HasPrefix(spec, "@") {
+		return parseDescriptor(spec, loc)
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("%w: expected 5 or 6 fields, found %d: %q", ErrCronSpec, len(fields), spec)
+	}
+
+	s := &SpecSchedule{Location: loc}
+	for i, b := range []struct {
+		bits   *uint64
+		bounds cronBounds
+	}{
+		{&s.Second, cronSeconds},
+		{&s.Minute, cronMinutes},
+		{&s.Hour, cronHours},
+		{&s.Dom, cronDom},
+		{&s.Month, cronMonths},
+		{&s.Dow, cronDow},
+	} {
+		bits, err := parseCronField(fields[i], b.bounds)
+		if err != nil {
+			return nil, err
+		}
+		*b.bits = bits
+	}
+	return s, nil
+}
+
+func parseDescriptor(spec string, loc *time.Location) (Schedule, error) {
+	all := func(b cronBounds) uint64 { return cronBits(b.min, b.max, 1) | cronStar }
+	s := &SpecSchedule{Second: 1, Minute: 1, Hour: 1, Dom: 1 << 1, Month: 1 << 1, Dow: all(cronDow), Location: loc}
+	switch spec {
+	case "@yearly", "@annually":
+	case "@monthly":
+		s.Month = all(cronMonths)
+	case "@weekly":
+		s.Dom, s.Month, s.Dow = all(cronDom), all(cronMonths), 1
+	case "@daily", "@midnight":
+		s.Dom, s.Month = all(cronDom), all(cronMonths)
+	case "@hourly":
+		s.Hour, s.Dom, s.Month = all(cronHours), all(cronDom), all(cronMonths)
+	default:
+		const every = "@every "
+		if !strings.HasPrefix(spec, every) {
+			return nil, fmt.Errorf("%w: unrecognized descriptor %q", ErrCronSpec, spec)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len(every):]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCronSpec, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("%w: @every requires a positive duration", ErrCronSpec)
+		}
+		return &EverySchedule{Every: d}, nil
+	}
+	return s, nil
+}
+
+// parseCronField parses a comma separated list of ranges, e.g. "1-5/2,10".
+func parseCronField(field string, b cronBounds) (uint64, error) {
+	var bits uint64
+	for _, expr := range strings.Split(field, ",") {
+		v, err := parseCronRange(expr, b)
+		if err != nil {
+			return 0, err
+		}
+		bits |= v
+	}
+	return bits, nil
+}
+
+// parseCronRange parses "*", "?", "n", "a-b" optionally followed by "/step".
+func parseCronRange(expr string, b cronBounds) (uint64, error) {
+	var (
+		start, end, step uint = 0, 0, 1
+		extra            uint64
+		err              error
+	)
+	rangeAndStep := strings.Split(expr, "/")
+	lowAndHigh := strings.Split(rangeAndStep[0], "-")
+	singleDigit := len(lowAndHigh) == 1
+
+	if lowAndHigh[0] == "*" || lowAndHigh[0] == "?" {
+		if !singleDigit {
+			return 0, fmt.Errorf("%w: invalid range %q", ErrCronSpec, expr)
+		}
+		start, end = b.min, b.max
+		extra = cronStar
+	} else {
+		if start, err = parseCronValue(lowAndHigh[0], b); err != nil {
+			return 0, err
+		}
+		switch len(lowAndHigh) {
+		case 1:
+			end = start
+		case 2:
+			if end, err = parseCronValue(lowAndHigh[1], b); err != nil {
+				return 0, err
+			}
+		default:
+			return 0, fmt.Errorf("%w: too many hyphens in %q", ErrCronSpec, expr)
+		}
+	}
+
+	switch len(rangeAndStep) {
+	case 1:
+	case 2:
+		n, err := strconv.ParseUint(rangeAndStep[1], 10, 8)
+		if err != nil || n == 0 {
+			return 0, fmt.Errorf("%w: invalid step in %q", ErrCronSpec, expr)
+		}
+		step = uint(n)
+		// "n/step" means "n-max/step"
+		if singleDigit && extra == 0 {
+			end = b.max
+		}
+		if step > 1 {
+			extra = 0
+		}
+	default:
+		return 0, fmt.Errorf("%w: too many slashes in %q", ErrCronSpec, expr)
+	}
+
+	// Sunday may be written as 7.
+	if b.max == cronDow.max && end == 7 {
+		if start == 7 {
+			start = 0
+		}
+		end = 6
+		extra |= 1
+		if start == 0 && len(lowAndHigh) == 1 {
+			end = 0
+		}
+	}
+
+	if start < b.min || end > b.max || start > end {
+		return 0, fmt.Errorf("%w: %q out of range [%d, %d]", ErrCronSpec, expr, b.min, b.max)
+	}
+	return cronBits(start, end, step) | extra, nil
+}
+
+func parseCronValue(expr string, b cronBounds) (uint, error) {
+	if b.names != nil {
+		if v, ok := b.names[strings.ToLower(expr)]; ok {
+			return v, nil
+		}
+	}
+	n, err := strconv.ParseUint(expr, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid value %q", ErrCronSpec, expr)
+	}
+	if b.max == cronDow.max && n == 7 {
+		return 7, nil
+	}
+	return uint(n), nil
+}
+
+func cronBits(min, max, step uint) uint64 {
+	var bits uint64
+	for i := min; i <= max; i += step {
+		bits |= 1 << i
+	}
+	return bits
+}
+
+func (s *SpecSchedule) Next(t time.Time) time.Time {
+	origLoc := t.Location()
+	loc := s.Location
+	if loc == nil {
+		loc = origLoc
+	}
+	t = t.In(loc)
+
+	// Start at the earliest possible time (the upcoming second).
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+
+	// Whether a field has been incremented; once it has, the lower
+	// fields are reset to their minimum.
+	added := false
+
+	// Give up if no time matches within five years.
+	yearLimit := t.Year() + 5
+
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for 1<<uint(t.Month())&s.Month == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !s.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		// Days may start at 23:00 or 01:00 around DST transitions.
+		if t.Hour() != 0 {
+			if t.Hour() > 12 {
+				t = t.Add(time.Duration(24-t.Hour()) * time.Hour)
+			} else {
+				t = t.Add(-time.Duration(t.Hour()) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for 1<<uint(t.Hour())&s.Hour == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for 1<<uint(t.Minute())&s.Minute == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	for 1<<uint(t.Second())&s.Second == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+
+	return t.In(origLoc)
+}
+
+// dayMatches follows the Vixie cron rule: when both day-of-month and
+// day-of-week are restricted, either one matching is enough.
+func (s *SpecSchedule) dayMatches(t time.Time) bool {
+	domMatch := 1<<uint(t.Day())&s.Dom > 0
+	dowMatch := 1<<uint(t.Weekday())&s.Dow > 0
+	if s.Dom&cronStar > 0 || s.Dow&cronStar > 0 {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
@@ -0,0 +1,143 @@
+package schedule_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/schedule"
+)
+
+func TestParseCron(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip(err)
+	}
+	base := time.Date(2024, time.March, 9, 10, 15, 30, 0, time.UTC)
+	cases := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", base, time.Date(2024, time.March, 9, 10, 16, 0, 0, time.UTC)},
+		{"*/10 * * * * *", base, time.Date(2024, time.March, 9, 10, 15, 40, 0, time.UTC)},
+		{"0 9-17/2 * * mon-fri", base, time.Date(2024, time.March, 11, 9, 0, 0, 0, time.UTC)},
+		{"30 2 29 feb *", base, time.Date(2028, time.February, 29, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1,15 * 0", base, time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", base, time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)},
+		{"@daily", base, time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", base, time.Date(2024, time.March, 9, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", base, time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", base, time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", base, time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", base, base.Add(90 * time.Second)},
+		{"CRON_TZ=Asia/Shanghai 0 8 * * *", base, time.Date(2024, time.March, 10, 8, 0, 0, 0, shanghai)},
+	}
+	for _, c := range cases {
+		sched, err := schedule.ParseCron(c.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", c.spec, err)
+		}
+		if got := sched.Next(c.from); !got.Equal(c.want) {
+			t.Fatalf("%s: next %v, want %v", c.spec, got, c.want)
+		}
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"", "* * * *", "* * * * * * *", "60 * * * *", "* 24 * * *",
+		"* * 0 * *", "* * * 13 *", "5-1 * * * *", "*/0 * * * *",
+		"@every -1s", "@fortnightly", "TZ=Nowhere/Atlantis * * * * *",
+	} {
+		if _, err := schedule.ParseCron(spec); !errors.Is(err, schedule.ErrCronSpec) {
+			t.Fatalf("%q: expected ErrCronSpec, got %v", spec, err)
+		}
+	}
+}
+
+func TestCron(t *testing.T) {
+	var (
+		runs   int32
+		panics int32
+	)
+	c := schedule.NewCron(context.Background(), schedule.WithErrorHandler(func(_ *schedule.CronJob, err error) {
+		atomic.AddInt32(&panics, 1)
+	}))
+
+	job, err := c.AddFunc("@every 100ms", func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Next().IsZero() {
+		t.Fatal("next activation should be known")
+	}
+
+	_, err = c.AddFunc("@every 100ms", func(ctx context.Context) error {
+		panic("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(350 * time.Millisecond)
+	job.Stop()
+	<-job.Done()
+	n := atomic.LoadInt32(&runs)
+	if n < 2 || uint64(n) != job.Runs() || job.Prev().IsZero() {
+		t.Fatalf("runs %d, job runs %d", n, job.Runs())
+	}
+	if _, ok := c.Job(job.ID()); ok {
+		t.Fatal("stopped job should be removed")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if atomic.LoadInt32(&runs) != n {
+		t.Fatal("stopped job should not run")
+	}
+	if atomic.LoadInt32(&panics) == 0 {
+		t.Fatal("panic should be recovered and reported")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = c.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Jobs()) != 0 {
+		t.Fatal("all jobs should be stopped")
+	}
+	if _, err = c.AddFunc("@hourly", func(context.Context) error { return nil }); !errors.Is(err, schedule.ErrCronStopped) {
+		t.Fatalf("expected ErrCronStopped, got %v", err)
+	}
+}
+
+func TestCronContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := schedule.NewCron(ctx)
+	canceled := make(chan struct{})
+	job, err := c.AddFunc("@every 50ms", func(ctx context.Context) error {
+		<-ctx.Done()
+		select {
+		case canceled <- struct{}{}:
+		default:
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(80 * time.Millisecond)
+	cancel()
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("running job should observe cancellation")
+	}
+	<-job.Done()
+}
@@ -0,0 +1,133 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// KeyRunPrefix is the key of the claim of the activation of a job
+// scheduled at a time, in milliseconds, by the leader of an elector.
+const KeyRunPrefix = "{%s}:run:%s:%d"
+
+// KEYS[1] lock, KEYS[2] run, ARGV[1] lock value, ARGV[2] token, ARGV[3]
+// ttl ms. Returns 1 if the run is claimed, 0 if it already was, -1 if the
+// lock is not held.
+var claimScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) ~= ARGV[1] then
+	return -1
+end
+if redis.call("SET", KEYS[2], ARGV[2], "NX", "PX", ARGV[3]) then
+	return 1
+end
+return 0`)
+
+type fencingKey struct{}
+
+// FencingToken returns the fencing token of the leadership term an
+// activation was started in, when the job is scheduled with an Elector.
+// Jobs writing to shared resources should pass it along, so that the writes
+// of a deposed leader still running are rejected.
+func FencingToken(ctx context.Context) (int64, bool) {
+	token, ok := ctx.Value(fencingKey{}).(int64)
+	return token, ok
+}
+
+// Elector elects one leader among the instances sharing a name, so that
+// jobs scheduled with it run on a single instance. Every activation is
+// claimed by the leader under "{name}:run:{job}:{scheduled time}" before it
+// runs, so that an activation already run by a deposed leader is not run
+// again by its successor.
+type Elector struct {
+	client redis.UniversalClient
+	name   string
+	mutex  *Mutex
+	lock   sync.RWMutex
+	token  int64
+}
+
+// NewElector campaigns for the lock name, see NewMutex. The lease is
+// renewed in the background while this instance leads.
+func NewElector(client redis.UniversalClient, name string, opts ...MutexOption) *Elector {
+	opts = append(opts, WithLockRenew())
+	return &Elector{client: client, name: name, mutex: NewMutex(client, name, opts...)}
+}
+
+// Run campaigns until ctx is done, taking over whenever the current
+// leader's lease expires, and resigns on return.
+func (e *Elector) Run(ctx context.Context) error {
+	for {
+		if err := e.mutex.Lock(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// redis unreachable, retry later
+			timer := time.NewTimer(e.mutex.retry)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			continue
+		}
+
+		lost := e.mutex.Lost()
+		e.setToken(e.mutex.Token())
+		select {
+		case <-ctx.Done():
+			e.setToken(0)
+			resignCtx, cancel := context.WithTimeout(context.Background(), e.mutex.ttl)
+			_ = e.mutex.Unlock(resignCtx)
+			cancel()
+			return ctx.Err()
+		case <-lost:
+			e.setToken(0)
+		}
+	}
+}
+
+func (e *Elector) IsLeader() bool {
+	return e.Token() != 0
+}
+
+// Token returns the fencing token of the current leadership term, 0 when
+// this instance is not the leader.
+func (e *Elector) Token() int64 {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.token
+}
+
+func (e *Elector) setToken(token int64) {
+	e.lock.Lock()
+	e.token = token
+	e.lock.Unlock()
+}
+
+// claim returns ctx carrying the fencing token if the activation of job
+// scheduled at at is claimed by this instance, false if the elector is set
+// and this instance is not the leader or the activation was claimed
+// already. The claim is kept for keep, at least three leases.
+func claim(ctx context.Context, e *Elector, job string, at time.Time, keep time.Duration) (context.Context, bool, error) {
+	if e == nil {
+		return ctx, true, nil
+	}
+	value, token := e.mutex.held()
+	if token == 0 || token != e.Token() {
+		return ctx, false, nil
+	}
+	if keep < 3*e.mutex.ttl {
+		keep = 3 * e.mutex.ttl
+	}
+	run := fmt.Sprintf(KeyRunPrefix, e.name, job, at.UnixMilli())
+	n, err := claimScript.Run(ctx, e.client, []string{e.mutex.key, run},
+		value, token, keep.Milliseconds()).Int64()
+	if err != nil || n != 1 {
+		return ctx, false, err
+	}
+	return context.WithValue(ctx, fencingKey{}, token), true, nil
+}
@@ -0,0 +1,271 @@
+package schedule
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrLockNotObtained = errors.New("lock not obtained")
+	ErrLockNotHeld     = errors.New("lock not held")
+)
+
+const (
+	KeyMutexPrefix = "{%s}:lock"
+	KeyFencePrefix = "{%s}:fence"
+)
+
+var (
+	// KEYS[1] lock, KEYS[2] fence counter, ARGV[1] owner, ARGV[2] ttl ms.
+	// Returns the fencing token, 0 if the lock is held by someone else.
+	lockScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+local token = redis.call("INCR", KEYS[2])
+redis.call("SET", KEYS[1], ARGV[1] .. ":" .. token, "PX", ARGV[2])
+return token`)
+
+	// KEYS[1] lock, ARGV[1] value.
+	unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	// KEYS[1] lock, ARGV[1] value, ARGV[2] ttl ms.
+	extendScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+)
+
+type MutexOption func(m *Mutex)
+
+// WithLockTTL sets the lease of the lock, 10s by default.
+func WithLockTTL(ttl time.Duration) MutexOption {
+	return func(m *Mutex) {
+		m.ttl = ttl
+	}
+}
+
+// WithLockRetry sets how often Lock retries while the lock is held elsewhere.
+func WithLockRetry(d time.Duration) MutexOption {
+	return func(m *Mutex) {
+		m.retry = d
+	}
+}
+
+// WithLockRenew keeps extending the lease every ttl/3 while the lock is held.
+func WithLockRenew() MutexOption {
+	return func(m *Mutex) {
+		m.renew = true
+	}
+}
+
+// Mutex is a distributed lock stored in redis. Every acquisition is given
+// a monotonically increasing fencing token, so that a resource guarded by
+// the lock can reject writes from a holder whose lease already expired.
+type Mutex struct {
+	client   redis.UniversalClient
+	key      string
+	fenceKey string
+	ttl      time.Duration
+	retry    time.Duration
+	renew    bool
+
+	lock  sync.Mutex
+	value string
+	token int64
+	stop  chan struct{}
+	lost  chan struct{}
+}
+
+// NewMutex creates the lock name, stored under the keys "{name}:lock" and
+// "{name}:fence" so that both hash to the same cluster slot.
+func NewMutex(client redis.UniversalClient, name string, opts ...MutexOption) *Mutex {
+	m := &Mutex{
+		client:   client,
+		key:      fmt.Sprintf(KeyMutexPrefix, name),
+		fenceKey: fmt.Sprintf(KeyFencePrefix, name),
+		ttl:      10 * time.Second,
+		retry:    100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Lock blocks until the lock is acquired or ctx is done.
+func (m *Mutex) Lock(ctx context.Context) error {
+	for {
+		err := m.TryLock(ctx)
+		if !errors.Is(err, ErrLockNotObtained) {
+			return err
+		}
+		timer := time.NewTimer(m.retry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// TryLock acquires the lock once, returning ErrLockNotObtained if it is
+// held by another owner.
+func (m *Mutex) TryLock(ctx context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.value != "" {
+		return nil
+	}
+
+	owner, err := randomOwner()
+	if err != nil {
+		return err
+	}
+	token, err := lockScript.Run(ctx, m.client, []string{m.key, m.fenceKey},
+		owner, m.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if token == 0 {
+		return ErrLockNotObtained
+	}
+	m.value = owner + ":" + strconv.FormatInt(token, 10)
+	m.token = token
+	m.lost = make(chan struct{})
+	if m.renew {
+		m.stop = make(chan struct{})
+		go m.keepalive(m.value, m.stop)
+	}
+	return nil
+}
+
+// Unlock releases the lock, returning ErrLockNotHeld if the lease had
+// already expired or the lock was never acquired.
+func (m *Mutex) Unlock(ctx context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.value == "" {
+		return ErrLockNotHeld
+	}
+	value := m.value
+	m.release()
+	n, err := unlockScript.Run(ctx, m.client, []string{m.key}, value).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+// Extend resets the lease to the configured ttl.
+func (m *Mutex) Extend(ctx context.Context) error {
+	m.lock.Lock()
+	value := m.value
+	m.lock.Unlock()
+	if value == "" {
+		return ErrLockNotHeld
+	}
+	return m.extend(ctx, value)
+}
+
+// Token returns the fencing token of the current acquisition, 0 if the
+// lock is not held.
+func (m *Mutex) Token() int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.token
+}
+
+// held returns the value and the token of the current acquisition.
+func (m *Mutex) held() (string, int64) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.value, m.token
+}
+
+// Lost is closed once the lock is no longer held, either after Unlock or
+// when automatic renewal fails. It returns nil when the lock is not held.
+func (m *Mutex) Lost() <-chan struct{} {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.lost
+}
+
+func (m *Mutex) extend(ctx context.Context, value string) error {
+	n, err := extendScript.Run(ctx, m.client, []string{m.key}, value, m.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockNotHeld
+	}
+	return nil
+}
+
+func (m *Mutex) keepalive(value string, stop chan struct{}) {
+	interval := m.ttl / 3
+	deadline := time.Now().Add(m.ttl)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := m.extend(ctx, value)
+		cancel()
+		switch {
+		case err == nil:
+			deadline = time.Now().Add(m.ttl)
+			continue
+		case !errors.Is(err, ErrLockNotHeld) && time.Now().Before(deadline):
+			// transient error, the lease is still ours until the deadline
+			continue
+		}
+		m.lock.Lock()
+		if m.value == value {
+			m.release()
+		}
+		m.lock.Unlock()
+		return
+	}
+}
+
+// release forgets the current acquisition, m.lock must be held.
+func (m *Mutex) release() {
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	if m.lost != nil {
+		close(m.lost)
+	}
+	m.value, m.token, m.lost = "", 0, nil
+}
+
+func randomOwner() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
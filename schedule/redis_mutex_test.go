@@ -0,0 +1,191 @@
+package schedule_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/uaxe/infra/clock"
+	"github.com/uaxe/infra/schedule"
+)
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return mr, client
+}
+
+func TestMutex(t *testing.T) {
+	mr, client := newRedis(t)
+	ctx := context.Background()
+
+	a := schedule.NewMutex(client, "job", schedule.WithLockTTL(time.Second))
+	b := schedule.NewMutex(client, "job", schedule.WithLockTTL(time.Second), schedule.WithLockRetry(10*time.Millisecond))
+
+	if err := a.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	tokenA := a.Token()
+	if tokenA <= 0 {
+		t.Fatalf("token %d", tokenA)
+	}
+	if err := b.TryLock(ctx); !errors.Is(err, schedule.ErrLockNotObtained) {
+		t.Fatalf("expected ErrLockNotObtained, got %v", err)
+	}
+
+	mr.FastForward(800 * time.Millisecond)
+	if err := a.Extend(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(800 * time.Millisecond)
+	if err := b.TryLock(ctx); !errors.Is(err, schedule.ErrLockNotObtained) {
+		t.Fatal("extended lock should still be held")
+	}
+
+	// the lease of a expires and b takes over with a newer token
+	mr.FastForward(time.Second)
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := b.Lock(timeout); err != nil {
+		t.Fatal(err)
+	}
+	if b.Token() <= tokenA {
+		t.Fatalf("fencing token should increase: %d <= %d", b.Token(), tokenA)
+	}
+	if err := a.Unlock(ctx); !errors.Is(err, schedule.ErrLockNotHeld) {
+		t.Fatalf("expected ErrLockNotHeld, got %v", err)
+	}
+	if err := a.Extend(ctx); !errors.Is(err, schedule.ErrLockNotHeld) {
+		t.Fatalf("expected ErrLockNotHeld, got %v", err)
+	}
+
+	lost := b.Lost()
+	if err := b.Unlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-lost
+	if err := a.TryLock(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMutexRenew(t *testing.T) {
+	mr, client := newRedis(t)
+	ctx := context.Background()
+	m := schedule.NewMutex(client, "renew", schedule.WithLockTTL(300*time.Millisecond), schedule.WithLockRenew())
+	if err := m.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if ttl := mr.TTL("{renew}:lock"); ttl <= 0 {
+		t.Fatalf("lease should be renewed, ttl %v", ttl)
+	}
+
+	lost := m.Lost()
+	mr.Del("{renew}:lock")
+	select {
+	case <-lost:
+	case <-time.After(time.Second):
+		t.Fatal("losing the lock should be signaled")
+	}
+	if m.Token() != 0 {
+		t.Fatal("lost lock has no token")
+	}
+}
+
+func TestElector(t *testing.T) {
+	_, client := newRedis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs int32
+	electors := make([]*schedule.Elector, 3)
+	done := make([]chan struct{}, 3)
+	for i := range electors {
+		electors[i] = schedule.NewElector(client, "replicas",
+			schedule.WithLockTTL(300*time.Millisecond), schedule.WithLockRetry(20*time.Millisecond))
+		done[i] = make(chan struct{})
+		go func(i int) {
+			defer close(done[i])
+			_ = electors[i].Run(ctx)
+		}(i)
+		tk := schedule.NewTicker(ctx, 20*time.Millisecond, func(ctx context.Context, now time.Time) error {
+			if _, ok := schedule.FencingToken(ctx); !ok {
+				t.Error("leader runs carry a fencing token")
+			}
+			atomic.AddInt32(&runs, 1)
+			return nil
+		}, schedule.WithElector(electors[i], "sync"))
+		defer tk.Stop()
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	leaders := 0
+	for _, e := range electors {
+		if e.IsLeader() {
+			leaders++
+		}
+	}
+	if leaders != 1 {
+		t.Fatalf("expected one leader, got %d", leaders)
+	}
+	// one run per tick at most, not three
+	if n := atomic.LoadInt32(&runs); n == 0 || n > 11 {
+		t.Fatalf("runs %d", n)
+	}
+
+	cancel()
+	for i := range done {
+		<-done[i]
+		if electors[i].IsLeader() {
+			t.Fatal("elector should resign")
+		}
+	}
+}
+
+func TestElector_Claim(t *testing.T) {
+	_, client := newRedis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := schedule.NewElector(client, "claims", schedule.WithLockTTL(time.Second))
+	go func() { _ = e.Run(ctx) }()
+	for !e.IsLeader() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// two instances believing they lead, started at different times
+	fake := clock.NewFake(epoch)
+	var runs int32
+	fn := func(context.Context, time.Time) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}
+	opts := []schedule.TickerOption{
+		schedule.WithClock(fake), schedule.WithOverlap(schedule.OverlapQueue), schedule.WithElector(e, "job"),
+	}
+	a := schedule.NewTicker(ctx, time.Second, fn, opts...)
+	fake.BlockUntil(1)
+	fake.Add(300 * time.Millisecond)
+	b := schedule.NewTicker(ctx, time.Second, fn, opts...)
+	for i := 0; i < 5; i++ {
+		fake.BlockUntil(2)
+		fake.Add(time.Second)
+	}
+	activations := func(tk *schedule.Ticker) uint64 {
+		stats := tk.Stats()
+		return stats.Runs + stats.Skipped
+	}
+	deadline := time.Now().Add(time.Second)
+	for (activations(a) < 5 || activations(b) < 5) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := atomic.LoadInt32(&runs); n != 5 {
+		t.Fatalf("5 activations run %d times", n)
+	}
+}
@@ -1,6 +1,7 @@
 package schedule
 
 import (
+	"context"
 	"time"
 )
 
@@ -18,29 +19,13 @@ func CalculateDelay(minute int, second int) time.Duration {
 	return time.Duration(int64(delay) * int64(time.Second))
 }
 
-func ScheduleAtFixRate(delay time.Duration, period time.Duration, callback func(now time.Time) error) {
-
-	go func() {
-		time.Sleep(delay)
-		initT := time.Now()
-		for {
-			now := time.Now()
-
-			_ = callback(now)
-
-			timeRange := int64(now.Sub(initT)) / int64(period)
-			if int64(now.Sub(initT))%int64(period) != 0 {
-				timeRange++
-			}
-
-			next := initT.Add(time.Duration(timeRange) * period)
-
-			now = time.Now()
-			if next.Before(now) {
-				time.Sleep(now.Sub(next))
-			} else {
-				time.Sleep(next.Sub(now))
-			}
-		}
-	}()
+// ScheduleAtFixRate runs callback every period after delay, skipping
+// activations that fall due while callback is still running.
+// Stop the returned Ticker to end the schedule.
+func ScheduleAtFixRate(delay time.Duration, period time.Duration, callback func(now time.Time) error,
+	opts ...TickerOption) *Ticker {
+	opts = append([]TickerOption{WithInitialDelay(delay)}, opts...)
+	return NewTicker(context.Background(), period, func(_ context.Context, now time.Time) error {
+		return callback(now)
+	}, opts...)
 }
@@ -0,0 +1,358 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uaxe/infra/clock"
+)
+
+type (
+	// TickerMode selects how the next activation of a Ticker is computed.
+	TickerMode int
+
+	// OverlapPolicy decides what a fixed rate Ticker does when an activation
+	// falls due while the previous one is still running.
+	OverlapPolicy int
+
+	TickerFunc func(ctx context.Context, now time.Time) error
+
+	TickerOption func(t *Ticker)
+)
+
+const (
+	// FixedRate activates at start + n*period regardless of run time.
+	FixedRate TickerMode = iota
+	// FixedDelay waits period after each run returns.
+	FixedDelay
+)
+
+const (
+	// OverlapSkip drops activations that fall due while a run is in progress.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue runs overdue activations one after another, keeping at
+	// most DefaultOverlapQueueSize of them, see WithOverlapQueueSize.
+	OverlapQueue
+	// OverlapConcurrent starts every activation in its own goroutine.
+	OverlapConcurrent
+)
+
+// DefaultOverlapQueueSize bounds the activations an OverlapQueue Ticker keeps
+// waiting, the ones falling due beyond are skipped.
+const DefaultOverlapQueueSize = 16
+
+// TickerStats is a snapshot of a Ticker's activity.
+type TickerStats struct {
+	Runs     uint64
+	Skipped  uint64
+	Failures uint64
+	Prev     time.Time
+	Next     time.Time
+	LastErr  error
+}
+
+// Ticker runs a function periodically until it is stopped or its context
+// is done.
+type Ticker struct {
+	runs     uint64
+	skipped  uint64
+	failures uint64
+	busy     int32
+
+	period   time.Duration
+	delay    time.Duration
+	jitter   time.Duration
+	mode     TickerMode
+	overlap  OverlapPolicy
+	maxQueue int
+	clock    clock.Clock
+	onError  func(now time.Time, err error)
+	elector  *Elector
+	job      string
+	fn       TickerFunc
+	rand     *rand.Rand
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	running sync.WaitGroup
+
+	lock    sync.Mutex
+	next    time.Time
+	prev    time.Time
+	lastErr error
+	queue   []activation
+	working bool
+}
+
+// activation is a time an activation fired at and the time it was
+// scheduled at, before jitter.
+type activation struct {
+	now, at time.Time
+}
+
+// WithFixedDelay switches the Ticker to FixedDelay mode.
+func WithFixedDelay() TickerOption {
+	return func(t *Ticker) {
+		t.mode = FixedDelay
+	}
+}
+
+func WithOverlap(policy OverlapPolicy) TickerOption {
+	return func(t *Ticker) {
+		t.overlap = policy
+	}
+}
+
+// WithOverlapQueueSize bounds the activations an OverlapQueue Ticker keeps
+// waiting to size, DefaultOverlapQueueSize by default.
+func WithOverlapQueueSize(size int) TickerOption {
+	return func(t *Ticker) {
+		t.maxQueue = size
+	}
+}
+
+// WithInitialDelay postpones the first activation by d instead of period.
+func WithInitialDelay(d time.Duration) TickerOption {
+	return func(t *Ticker) {
+		t.delay = d
+	}
+}
+
+// WithJitter delays every activation by a random duration in [0, d).
+func WithJitter(d time.Duration) TickerOption {
+	return func(t *Ticker) {
+		t.jitter = d
+	}
+}
+
+// WithErrorHook receives errors returned by the function and recovered panics.
+func WithErrorHook(fn func(now time.Time, err error)) TickerOption {
+	return func(t *Ticker) {
+		t.onError = fn
+	}
+}
+
+// WithElector runs every activation once among the instances of e, on the
+// leader, counting the others as skipped. The activations of job, which
+// must name the Ticker among those of e, are claimed per period of the
+// wall clock, so that instances started at different times share them.
+func WithElector(e *Elector, job string) TickerOption {
+	return func(t *Ticker) {
+		t.elector, t.job = e, job
+	}
+}
+
+func WithClock(c clock.Clock) TickerOption {
+	return func(t *Ticker) {
+		t.clock = c
+	}
+}
+
+// NewTicker starts running fn every period. It panics if period is not positive.
+func NewTicker(ctx context.Context, period time.Duration, fn TickerFunc, opts ...TickerOption) *Ticker {
+	if period <= 0 {
+		panic("non-positive period for NewTicker")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	t := &Ticker{
+		period:   period,
+		delay:    period,
+		maxQueue: DefaultOverlapQueueSize,
+		clock:    clock.Real,
+		fn:       fn,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.jitter > 0 {
+		t.rand = rand.New(rand.NewSource(t.clock.Now().UnixNano()))
+	}
+	go t.loop()
+	return t
+}
+
+// Stop cancels the Ticker; runs in progress see their context done.
+func (t *Ticker) Stop() {
+	t.cancel()
+}
+
+// Done is closed once the Ticker has stopped and its runs returned.
+func (t *Ticker) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *Ticker) Stats() TickerStats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return TickerStats{
+		Runs:     atomic.LoadUint64(&t.runs),
+		Skipped:  atomic.LoadUint64(&t.skipped),
+		Failures: atomic.LoadUint64(&t.failures),
+		Prev:     t.prev,
+		Next:     t.next,
+		LastErr:  t.lastErr,
+	}
+}
+
+func (t *Ticker) loop() {
+	defer func() {
+		t.lock.Lock()
+		t.next = time.Time{}
+		t.lock.Unlock()
+		t.running.Wait()
+		t.cancel()
+		close(t.done)
+	}()
+
+	if t.mode == FixedDelay {
+		next := t.clock.Now().Add(t.delay)
+		for {
+			now, ok := t.wait(t.jittered(next))
+			if !ok {
+				return
+			}
+			t.running.Add(1)
+			t.run(activation{now: now, at: next})
+			next = t.clock.Now().Add(t.period)
+		}
+	}
+
+	start := t.clock.Now().Add(t.delay)
+	for n := int64(0); ; n++ {
+		at := start.Add(time.Duration(n) * t.period)
+		next := t.jittered(at)
+		now, ok := t.wait(next)
+		if !ok {
+			return
+		}
+		// fell behind by whole periods, don't fire a burst to catch up
+		if lag := now.Sub(next); lag >= t.period {
+			missed := int64(lag / t.period)
+			n += missed
+			atomic.AddUint64(&t.skipped, uint64(missed))
+		}
+		t.dispatch(activation{now: now, at: at})
+	}
+}
+
+func (t *Ticker) jittered(next time.Time) time.Time {
+	if t.jitter > 0 {
+		next = next.Add(time.Duration(t.rand.Int63n(int64(t.jitter))))
+	}
+	return next
+}
+
+// wait blocks until next, reporting false once the Ticker is stopped.
+func (t *Ticker) wait(next time.Time) (time.Time, bool) {
+	t.lock.Lock()
+	t.next = next
+	t.lock.Unlock()
+
+	timer := t.clock.NewTimer(next.Sub(t.clock.Now()))
+	select {
+	case <-t.ctx.Done():
+		timer.Stop()
+		return time.Time{}, false
+	case now := <-timer.C():
+		return now, true
+	}
+}
+
+func (t *Ticker) dispatch(a activation) {
+	switch t.overlap {
+	case OverlapConcurrent:
+		t.running.Add(1)
+		go t.run(a)
+	case OverlapQueue:
+		t.lock.Lock()
+		if len(t.queue) >= t.maxQueue && t.working {
+			t.lock.Unlock()
+			atomic.AddUint64(&t.skipped, 1)
+			return
+		}
+		t.queue = append(t.queue, a)
+		if !t.working {
+			t.working = true
+			t.running.Add(1)
+			go t.drain()
+		}
+		t.lock.Unlock()
+	default:
+		if !atomic.CompareAndSwapInt32(&t.busy, 0, 1) {
+			atomic.AddUint64(&t.skipped, 1)
+			return
+		}
+		t.running.Add(1)
+		go func() {
+			defer atomic.StoreInt32(&t.busy, 0)
+			t.run(a)
+		}()
+	}
+}
+
+func (t *Ticker) drain() {
+	defer t.running.Done()
+	for {
+		t.lock.Lock()
+		if len(t.queue) == 0 || t.ctx.Err() != nil {
+			t.queue = nil
+			t.working = false
+			t.lock.Unlock()
+			return
+		}
+		a := t.queue[0]
+		t.queue = t.queue[1:]
+		t.lock.Unlock()
+
+		t.running.Add(1)
+		t.run(a)
+	}
+}
+
+func (t *Ticker) run(a activation) {
+	defer t.running.Done()
+	now := a.now
+	ctx, ok, err := claim(t.ctx, t.elector, t.job, a.at.Truncate(t.period), t.period)
+	if err != nil {
+		t.fail(now, err)
+	}
+	if !ok {
+		atomic.AddUint64(&t.skipped, 1)
+		return
+	}
+	atomic.AddUint64(&t.runs, 1)
+	t.lock.Lock()
+	t.prev = now
+	t.lock.Unlock()
+
+	err = func() (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("ticker panic: %v\n%s", p, debug.Stack())
+			}
+		}()
+		return t.fn(ctx, now)
+	}()
+	if err != nil {
+		t.fail(now, err)
+	}
+}
+
+func (t *Ticker) fail(now time.Time, err error) {
+	atomic.AddUint64(&t.failures, 1)
+	t.lock.Lock()
+	t.lastErr = err
+	t.lock.Unlock()
+	if t.onError != nil {
+		t.onError(now, err)
+	}
+}
@@ -0,0 +1,210 @@
+package schedule_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/clock"
+	"github.com/uaxe/infra/schedule"
+)
+
+var epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func TestTickerSkip(t *testing.T) {
+	fake := clock.NewFake(epoch)
+	started := make(chan time.Time, 10)
+	release := make(chan struct{})
+	tk := schedule.NewTicker(context.Background(), time.Second, func(ctx context.Context, now time.Time) error {
+		started <- now
+		<-release
+		return nil
+	}, schedule.WithClock(fake))
+
+	fake.BlockUntil(1)
+	fake.Add(time.Second)
+	if now := <-started; !now.Equal(epoch.Add(time.Second)) {
+		t.Fatalf("first run at %v", now)
+	}
+
+	fake.BlockUntil(1)
+	fake.Add(time.Second)
+	fake.BlockUntil(1)
+	stats := tk.Stats()
+	if stats.Runs != 1 || stats.Skipped != 1 {
+		t.Fatalf("%+v", stats)
+	}
+	if !stats.Next.Equal(epoch.Add(3 * time.Second)) {
+		t.Fatalf("next %v", stats.Next)
+	}
+
+	close(release)
+	tk.Stop()
+	<-tk.Done()
+	if !tk.Stats().Next.IsZero() {
+		t.Fatal("stopped ticker has no next activation")
+	}
+}
+
+func TestTickerQueue(t *testing.T) {
+	fake := clock.NewFake(epoch)
+	started := make(chan time.Time, 10)
+	release := make(chan struct{}, 10)
+	tk := schedule.NewTicker(context.Background(), time.Second, func(ctx context.Context, now time.Time) error {
+		started <- now
+		<-release
+		return nil
+	}, schedule.WithClock(fake), schedule.WithOverlap(schedule.OverlapQueue))
+	defer tk.Stop()
+
+	for i := 0; i < 3; i++ {
+		fake.BlockUntil(1)
+		fake.Add(time.Second)
+	}
+	for i := 1; i <= 3; i++ {
+		release <- struct{}{}
+		if now := <-started; !now.Equal(epoch.Add(time.Duration(i) * time.Second)) {
+			t.Fatalf("run %d at %v", i, now)
+		}
+	}
+}
+
+func TestTickerQueueSize(t *testing.T) {
+	fake := clock.NewFake(epoch)
+	started := make(chan time.Time, 10)
+	release := make(chan struct{}, 10)
+	tk := schedule.NewTicker(context.Background(), time.Second, func(ctx context.Context, now time.Time) error {
+		started <- now
+		<-release
+		return nil
+	}, schedule.WithClock(fake), schedule.WithOverlap(schedule.OverlapQueue), schedule.WithOverlapQueueSize(1))
+	defer tk.Stop()
+
+	fake.BlockUntil(1)
+	fake.Add(time.Second)
+	<-started
+	// the run of the first tick blocks, the second waits and the others are skipped
+	for i := 0; i < 3; i++ {
+		fake.BlockUntil(1)
+		fake.Add(time.Second)
+	}
+	fake.BlockUntil(1)
+	release <- struct{}{}
+	if now := <-started; !now.Equal(epoch.Add(2 * time.Second)) {
+		t.Fatalf("queued run at %v", now)
+	}
+	release <- struct{}{}
+	if stats := tk.Stats(); stats.Skipped != 2 {
+		t.Fatalf("%d skipped ticks", stats.Skipped)
+	}
+}
+
+func TestTickerConcurrent(t *testing.T) {
+	fake := clock.NewFake(epoch)
+	var active int32
+	started := make(chan int32, 10)
+	release := make(chan struct{})
+	tk := schedule.NewTicker(context.Background(), time.Second, func(ctx context.Context, now time.Time) error {
+		started <- atomic.AddInt32(&active, 1)
+		<-release
+		return nil
+	}, schedule.WithClock(fake), schedule.WithOverlap(schedule.OverlapConcurrent))
+
+	fake.BlockUntil(1)
+	fake.Add(time.Second)
+	<-started
+	fake.BlockUntil(1)
+	fake.Add(time.Second)
+	if n := <-started; n != 2 {
+		t.Fatalf("expected 2 concurrent runs, got %d", n)
+	}
+	close(release)
+	tk.Stop()
+	<-tk.Done()
+}
+
+func TestTickerFixedDelay(t *testing.T) {
+	fake := clock.NewFake(epoch)
+	started := make(chan time.Time, 10)
+	tk := schedule.NewTicker(context.Background(), time.Second, func(ctx context.Context, now time.Time) error {
+		started <- now
+		fake.Add(500 * time.Millisecond)
+		return nil
+	}, schedule.WithClock(fake), schedule.WithFixedDelay(), schedule.WithInitialDelay(0))
+	defer tk.Stop()
+
+	if now := <-started; !now.Equal(epoch) {
+		t.Fatalf("first run at %v", now)
+	}
+	fake.BlockUntil(1)
+	if next := tk.Stats().Next; !next.Equal(epoch.Add(1500 * time.Millisecond)) {
+		t.Fatalf("next %v", next)
+	}
+}
+
+func TestTickerErrorHook(t *testing.T) {
+	fake := clock.NewFake(epoch)
+	errs := make(chan error, 10)
+	boom := errors.New("boom")
+	var calls int32
+	tk := schedule.NewTicker(context.Background(), time.Second, func(ctx context.Context, now time.Time) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return boom
+		}
+		panic("boom")
+	}, schedule.WithClock(fake), schedule.WithErrorHook(func(now time.Time, err error) {
+		errs <- err
+	}))
+	defer tk.Stop()
+
+	fake.BlockUntil(1)
+	fake.Add(time.Second)
+	if err := <-errs; !errors.Is(err, boom) {
+		t.Fatal(err)
+	}
+	fake.BlockUntil(1)
+	fake.Add(time.Second)
+	if err := <-errs; err == nil {
+		t.Fatal("panic should be reported")
+	}
+	if stats := tk.Stats(); stats.Failures != 2 || stats.LastErr == nil {
+		t.Fatalf("%+v", stats)
+	}
+}
+
+func TestTickerJitter(t *testing.T) {
+	fake := clock.NewFake(epoch)
+	tk := schedule.NewTicker(context.Background(), time.Second, func(ctx context.Context, now time.Time) error {
+		return nil
+	}, schedule.WithClock(fake), schedule.WithJitter(100*time.Millisecond))
+	defer tk.Stop()
+
+	fake.BlockUntil(1)
+	next := tk.Stats().Next
+	if next.Before(epoch.Add(time.Second)) || !next.Before(epoch.Add(1100*time.Millisecond)) {
+		t.Fatalf("next %v", next)
+	}
+}
+
+func TestTickerContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tk := schedule.ScheduleAtFixRate(0, 10*time.Millisecond, func(now time.Time) error {
+		return nil
+	})
+	defer tk.Stop()
+	tk2 := schedule.NewTicker(ctx, 10*time.Millisecond, func(ctx context.Context, now time.Time) error {
+		return nil
+	})
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-tk2.Done():
+	case <-time.After(time.Second):
+		t.Fatal("ticker should stop with its context")
+	}
+	if tk.Stats().Runs == 0 || tk2.Stats().Runs == 0 {
+		t.Fatal("tickers should have run")
+	}
+}
@@ -1,155 +1,193 @@
 package schedule
 
 import (
-	"container/heap"
+	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/uaxe/infra/clock"
 )
 
 type OnEvent func(t time.Time)
 
+const (
+	MinInterval = 100 * time.Millisecond
+)
+
+// The wheel has a root level of 256 slots of one tick each, and four upper
+// levels of 64 slots, each slot spanning a whole turn of the level below.
+// Timers further than 2^32 ticks away are clamped to that horizon.
+const (
+	wheelRootBits  = 8
+	wheelLevelBits = 6
+	wheelLevels    = 4
+	wheelRootSize  = 1 << wheelRootBits
+	wheelLevelSize = 1 << wheelLevelBits
+	wheelRootMask  = wheelRootSize - 1
+	wheelLevelMask = wheelLevelSize - 1
+	wheelMaxTicks  = 1<<(wheelRootBits+wheelLevels*wheelLevelBits) - 1
+)
+
 type Timer struct {
-	InitTid   uint32
-	timerId   uint32
-	Index     int
-	expired   time.Time
+	id        uint64
+	expires   uint64
 	interval  time.Duration
+	repeated  bool
 	onTimeout OnEvent
 	onCancel  OnEvent
-	repeated  bool
-}
 
-type TimerHeap []*Timer
-
-func (h TimerHeap) Len() int { return len(h) }
-
-func (h TimerHeap) Less(i, j int) bool {
-	if h[i].expired.Before(h[j].expired) {
-		return true
-	}
+	list       *timerList
+	prev, next *Timer
+}
 
-	if h[i].expired.After(h[j].expired) {
-		return false
-	}
-	return h[i].timerId < h[j].timerId
+func (t *Timer) ID() uint64 {
+	return t.id
 }
 
-func (h TimerHeap) Swap(i, j int) {
-	h[i], h[j] = h[j], h[i]
-	h[i].Index = i
-	h[j].Index = j
+// timerList is an intrusive doubly linked list, so that a timer can be
+// unlinked from its slot in O(1).
+type timerList struct {
+	root Timer
 }
 
-func (h *TimerHeap) Push(x any) {
-	n := len(*h)
-	item := x.(*Timer)
-	item.Index = n
-	*h = append(*h, item)
+func (l *timerList) push(t *Timer) {
+	if l.root.next == nil {
+		l.root.next, l.root.prev = &l.root, &l.root
+	}
+	t.list = l
+	t.prev = l.root.prev
+	t.next = &l.root
+	l.root.prev.next = t
+	l.root.prev = t
 }
 
-func (h *TimerHeap) Pop() any {
-	old := *h
-	n := len(old)
-	item := old[n-1]
-	item.Index = -1 // for safety
-	*h = old[0 : n-1]
-	return item
+func (l *timerList) remove(t *Timer) {
+	t.prev.next = t.next
+	t.next.prev = t.prev
+	t.list, t.prev, t.next = nil, nil, nil
 }
 
-var timerIds uint32
+// drain unlinks every timer of the list and appends them to dst.
+func (l *timerList) drain(dst []*Timer) []*Timer {
+	if l.root.next == nil {
+		return dst
+	}
+	for t := l.root.next; t != &l.root; {
+		next := t.next
+		t.list, t.prev, t.next = nil, nil, nil
+		dst = append(dst, t)
+		t = next
+	}
+	l.root.next, l.root.prev = &l.root, &l.root
+	return dst
+}
 
-const (
-	MinInterval = 100 * time.Millisecond
-)
+var timerIds uint64
 
-func timerId() uint32 {
-	return atomic.AddUint32(&timerIds, 1)
+func timerId() uint64 {
+	return atomic.AddUint64(&timerIds, 1)
 }
 
+// TimerWheel is a hierarchical timing wheel: adding, updating and
+// canceling a timer are O(1) and never block on the wheel's goroutine.
 type TimerWheel struct {
-	timerHeap   TimerHeap
-	tick        *time.Ticker
-	hashTimer   map[uint32]*Timer
-	interval    time.Duration
-	cancelTimer chan uint32
-	addTimer    chan *Timer
-	updateTimer chan Timer
-	workLimit   chan *any
+	lock     sync.Mutex
+	interval time.Duration
+	start    time.Time
+	current  uint64 // next tick to process
+	root     [wheelRootSize]timerList
+	levels   [wheelLevels][wheelLevelSize]timerList
+	timers   map[uint64]*Timer
+
+	clock     clock.Clock
+	tick      clock.Ticker
+	workLimit chan struct{}
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+type TimerWheelOption func(t *TimerWheel)
+
+// WithWheelClock drives the wheel by c instead of the real time.
+func WithWheelClock(c clock.Clock) TimerWheelOption {
+	return func(t *TimerWheel) {
+		t.clock = c
+	}
 }
 
-func NewTimerWheel(interval time.Duration, workSize int) *TimerWheel {
+func NewTimerWheel(interval time.Duration, workSize int, opts ...TimerWheelOption) *TimerWheel {
 
 	if int64(interval)-int64(MinInterval) < 0 {
 		interval = MinInterval
 	}
+	if workSize <= 0 {
+		workSize = 1
+	}
 
 	tw := &TimerWheel{
-		timerHeap:   make(TimerHeap, 0),
-		tick:        time.NewTicker(interval),
-		hashTimer:   make(map[uint32]*Timer, 10),
-		interval:    interval,
-		updateTimer: make(chan Timer, 2000),
-		cancelTimer: make(chan uint32, 2000),
-		addTimer:    make(chan *Timer, 2000),
-		workLimit:   make(chan *any, workSize*2),
-	}
-	heap.Init(&tw.timerHeap)
-	tw.start()
+		interval:  interval,
+		timers:    make(map[uint64]*Timer, 10),
+		clock:     clock.Real,
+		workLimit: make(chan struct{}, workSize*2),
+		stop:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(tw)
+	}
+	tw.start = tw.clock.Now()
+	tw.tick = tw.clock.NewTicker(interval)
+	tw.run()
 	return tw
 }
 
-func (t *TimerWheel) Monitor() (add, update, cancel, worker int) {
-	return len(t.addTimer), len(t.updateTimer), len(t.cancelTimer), len(t.workLimit)
+// Monitor returns the number of pending timers and running callbacks.
+func (t *TimerWheel) Monitor() (timers, worker int) {
+	return t.Len(), len(t.workLimit)
+}
+
+func (t *TimerWheel) Len() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.timers)
 }
 
-func (t *TimerWheel) After(timeout time.Duration) (uint32, chan time.Time) {
+func (t *TimerWheel) After(timeout time.Duration) (uint64, chan time.Time) {
 	if timeout < t.interval {
 		timeout = t.interval
 	}
 	ch := make(chan time.Time, 1)
-	tid := timerId()
 	timer := &Timer{
-		timerId:   tid,
-		InitTid:   tid,
-		expired:   time.Now().Add(timeout),
+		id:        timerId(),
 		onTimeout: func(t time.Time) { ch <- t },
-		onCancel:  nil}
-
-	t.addTimer <- timer
-	return timer.timerId, ch
+	}
+	t.add(timer, t.clock.Now().Add(timeout))
+	return timer.id, ch
 }
 
 func (t *TimerWheel) RepeatedTimer(interval time.Duration,
-	onTimout OnEvent, onCancel OnEvent) uint32 {
+	onTimout OnEvent, onCancel OnEvent) uint64 {
 	if interval < t.interval {
 		interval = t.interval
 	}
-	tid := timerId()
 	timer := &Timer{
+		id:       timerId(),
 		repeated: true,
 		interval: interval,
-		timerId:  tid,
-		InitTid:  tid,
-		expired:  time.Now().Add(interval),
 		onTimeout: func(t time.Time) {
 			if nil != onTimout {
 				onTimout(t)
 			}
 		},
 		onCancel: onCancel}
-
-	t.addTimer <- timer
-	return tid
+	t.add(timer, t.clock.Now().Add(interval))
+	return timer.id
 }
 
-func (t *TimerWheel) AddTimer(timeout time.Duration, onTimout OnEvent, onCancel OnEvent) (uint32, chan time.Time) {
+func (t *TimerWheel) AddTimer(timeout time.Duration, onTimout OnEvent, onCancel OnEvent) (uint64, chan time.Time) {
 	ch := make(chan time.Time, 1)
-	tid := timerId()
 	timer := &Timer{
-		timerId:  tid,
-		InitTid:  tid,
+		id:       timerId(),
 		interval: timeout,
-		expired:  time.Now().Add(timeout),
 		onTimeout: func(t time.Time) {
 			defer func() {
 				ch <- t
@@ -159,97 +197,173 @@ func (t *TimerWheel) AddTimer(timeout time.Duration, onTimout OnEvent, onCancel
 			}
 		},
 		onCancel: onCancel}
+	t.add(timer, t.clock.Now().Add(timeout))
+	return timer.id, ch
+}
 
-	t.addTimer <- timer
-	return timer.timerId, ch
+// UpdateTimer moves the deadline of a pending timer, reporting whether the
+// timer was found.
+func (t *TimerWheel) UpdateTimer(timerid uint64, expired time.Time) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	timer, ok := t.timers[timerid]
+	if !ok {
+		return false
+	}
+	if timer.list != nil {
+		timer.list.remove(timer)
+	}
+	timer.expires = t.ticksAt(expired)
+	t.place(timer)
+	return true
 }
 
-func (t *TimerWheel) UpdateTimer(timerid uint32, expired time.Time) {
-	timer := Timer{
-		timerId: timerid,
-		expired: expired}
-	t.updateTimer <- timer
+// CancelTimer removes a pending timer and runs its onCancel callback,
+// reporting whether the timer was found.
+func (t *TimerWheel) CancelTimer(timerid uint64) bool {
+	t.lock.Lock()
+	timer, ok := t.timers[timerid]
+	if ok {
+		delete(t.timers, timerid)
+		if timer.list != nil {
+			timer.list.remove(timer)
+		}
+	}
+	t.lock.Unlock()
+
+	if ok && nil != timer.onCancel {
+		go t.work(timer.onCancel, t.clock.Now())
+	}
+	return ok
 }
 
-func (t *TimerWheel) CancelTimer(timerid uint32) {
-	t.cancelTimer <- timerid
+// Stop halts the wheel, pending timers never fire.
+func (t *TimerWheel) Stop() {
+	t.stopOnce.Do(func() {
+		t.tick.Stop()
+		close(t.stop)
+	})
 }
 
-func (t *TimerWheel) checkExpired(now time.Time) {
-	for {
-		if t.timerHeap.Len() <= 0 {
-			break
-		}
+func (t *TimerWheel) add(timer *Timer, expired time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	timer.expires = t.ticksAt(expired)
+	t.timers[timer.id] = timer
+	t.place(timer)
+}
 
-		expired := t.timerHeap[0].expired
-		if expired.After(now) {
-			break
-		}
-		timer := heap.Pop(&t.timerHeap).(*Timer)
-		if nil != timer.onTimeout {
-			t.workLimit <- nil
-			go func() {
-				defer func() {
-					<-t.workLimit
-				}()
-				timer.onTimeout(now)
-			}()
+// ticksAt converts a deadline to the first tick at or after it, t.lock must be held.
+func (t *TimerWheel) ticksAt(expired time.Time) uint64 {
+	d := expired.Sub(t.start)
+	if d <= 0 {
+		return t.current
+	}
+	ticks := uint64((d + t.interval - 1) / t.interval)
+	if ticks < t.current {
+		return t.current
+	}
+	return ticks
+}
 
-			if timer.repeated {
-				timer.expired = timer.expired.Add(t.interval)
-				if !timer.expired.After(now) {
-					timer.expired = now.Add(t.interval)
-				}
-				timer.timerId = timerId()
-				t.onAddTimer(timer)
-			} else {
-				delete(t.hashTimer, timer.timerId)
-				delete(t.hashTimer, timer.InitTid)
-			}
-		} else {
-			delete(t.hashTimer, timer.timerId)
-			delete(t.hashTimer, timer.InitTid)
+// place links timer into the slot of its level, t.lock must be held.
+func (t *TimerWheel) place(timer *Timer) {
+	if timer.expires < t.current {
+		timer.expires = t.current
+	}
+	delta := timer.expires - t.current
+	if delta < wheelRootSize {
+		t.root[timer.expires&wheelRootMask].push(timer)
+		return
+	}
+	if delta > wheelMaxTicks {
+		timer.expires = t.current + wheelMaxTicks
+	}
+	for level := 0; level < wheelLevels; level++ {
+		shift := wheelRootBits + level*wheelLevelBits
+		if delta < 1<<(shift+wheelLevelBits) || level == wheelLevels-1 {
+			t.levels[level][(timer.expires>>shift)&wheelLevelMask].push(timer)
+			return
 		}
 	}
 }
 
-func (t *TimerWheel) start() {
-
+func (t *TimerWheel) run() {
 	go func() {
 		for {
 			select {
-			case now := <-t.tick.C:
-				t.checkExpired(now)
-			case updateT := <-t.updateTimer:
-				if timer, ok := t.hashTimer[updateT.timerId]; ok {
-					timer.expired = updateT.expired
-					heap.Fix(&t.timerHeap, timer.Index)
-				}
-			case timer := <-t.addTimer:
-				t.onAddTimer(timer)
-			case timerid := <-t.cancelTimer:
-				if timer, ok := t.hashTimer[timerid]; ok {
-					delete(t.hashTimer, timer.InitTid)
-					delete(t.hashTimer, timer.timerId)
-					heap.Remove(&t.timerHeap, timer.Index)
-					if nil != timer.onCancel {
-						t.workLimit <- nil
-						go func() {
-							<-t.workLimit
-							timer.onCancel(time.Now())
-						}()
-					}
-				}
+			case <-t.stop:
+				return
+			case <-t.tick.C():
+				// ticks may have been dropped, catch up to the clock
+				t.advance(t.clock.Now())
 			}
 		}
 	}()
 }
 
-func (t *TimerWheel) onAddTimer(timer *Timer) {
-	heap.Push(&t.timerHeap, timer)
-	if !timer.repeated {
-		t.hashTimer[timer.timerId] = timer
-	} else {
-		t.hashTimer[timer.InitTid] = timer
+// advance processes every tick up to now and runs the expired timers.
+func (t *TimerWheel) advance(now time.Time) {
+	if now.Before(t.start) {
+		return
 	}
+	target := uint64(now.Sub(t.start) / t.interval)
+	var expired []*Timer
+
+	t.lock.Lock()
+	for ; t.current <= target; t.current++ {
+		idx := t.current & wheelRootMask
+		if idx == 0 {
+			t.cascade()
+		}
+		for _, timer := range t.root[idx].drain(nil) {
+			if timer.repeated {
+				timer.expires += uint64((timer.interval + t.interval - 1) / t.interval)
+				if timer.expires <= t.current {
+					timer.expires = t.current + 1
+				}
+				t.place(timer)
+			} else {
+				delete(t.timers, timer.id)
+			}
+			expired = append(expired, timer)
+		}
+	}
+	t.lock.Unlock()
+
+	for _, timer := range expired {
+		if nil != timer.onTimeout {
+			t.workLimit <- struct{}{}
+			go func(fn OnEvent) {
+				defer func() {
+					<-t.workLimit
+				}()
+				fn(now)
+			}(timer.onTimeout)
+		}
+	}
+}
+
+// cascade redistributes the upper level slots that are due at the start
+// of a new root turn, t.lock must be held.
+func (t *TimerWheel) cascade() {
+	var timers []*Timer
+	for level := 0; level < wheelLevels; level++ {
+		idx := (t.current >> (wheelRootBits + level*wheelLevelBits)) & wheelLevelMask
+		timers = t.levels[level][idx].drain(timers[:0])
+		for _, timer := range timers {
+			t.place(timer)
+		}
+		if idx != 0 {
+			return
+		}
+	}
+}
+
+func (t *TimerWheel) work(fn OnEvent, now time.Time) {
+	t.workLimit <- struct{}{}
+	defer func() {
+		<-t.workLimit
+	}()
+	fn(now)
 }
@@ -0,0 +1,86 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// newManualWheel returns a wheel whose ticks are driven by the test.
+func newManualWheel() *TimerWheel {
+	tw := NewTimerWheel(MinInterval, 10)
+	tw.Stop()
+	return tw
+}
+
+func (t *TimerWheel) advanceTo(tick uint64) {
+	t.advance(t.start.Add(time.Duration(tick) * t.interval))
+}
+
+func TestTimerWheelLevels(t *testing.T) {
+	tw := newManualWheel()
+
+	fired := make(chan uint64, 16)
+	ticks := []uint64{1, 255, 256, 300, 1 << 14, 1<<14 + 7, 1 << 20, 1<<26 + 3}
+	for _, n := range ticks {
+		n := n
+		tw.AddTimer(time.Duration(n)*MinInterval, func(time.Time) { fired <- n }, nil)
+	}
+	if tw.Len() != len(ticks) {
+		t.Fatalf("len %d", tw.Len())
+	}
+
+	for _, n := range ticks {
+		// deadlines fall just after tick n, as the timers were added after start
+		tw.advanceTo(n)
+		select {
+		case got := <-fired:
+			t.Fatalf("timer %d fired at tick %d", got, n)
+		default:
+		}
+		tw.advanceTo(n + 1)
+		select {
+		case got := <-fired:
+			if got != n {
+				t.Fatalf("tick %d fired timer %d", n, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timer %d did not fire", n)
+		}
+	}
+	if tw.Len() != 0 {
+		t.Fatalf("len %d", tw.Len())
+	}
+}
+
+func TestTimerWheelRepeatedAndUpdate(t *testing.T) {
+	tw := newManualWheel()
+
+	fired := make(chan time.Time, 16)
+	canceled := make(chan time.Time, 1)
+	id := tw.RepeatedTimer(3*MinInterval, func(now time.Time) { fired <- now }, func(now time.Time) { canceled <- now })
+	for i := uint64(1); i <= 3; i++ {
+		tw.advanceTo(3*i + 1)
+		<-fired
+	}
+	if !tw.CancelTimer(id) {
+		t.Fatal("repeated timer should be pending")
+	}
+	<-canceled
+	if tw.CancelTimer(id) {
+		t.Fatal("timer already canceled")
+	}
+
+	id, ch := tw.AddTimer(1000*MinInterval, nil, nil)
+	if !tw.UpdateTimer(id, tw.start.Add(20*MinInterval)) {
+		t.Fatal("timer should be pending")
+	}
+	tw.advanceTo(20)
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("updated timer did not fire")
+	}
+	if tw.UpdateTimer(id, time.Now()) {
+		t.Fatal("fired timer is not pending")
+	}
+}
@@ -64,3 +64,51 @@ func TestTimeWheel(t *testing.T) {
 	t.Logf("Wait : %d s", time.Now().Unix()-start)
 
 }
+
+// fillTimerWheel adds n timers spread over the next hour, like the TTLs of
+// a large cache.LRUCache.
+func fillTimerWheel(tw *schedule.TimerWheel, n int) {
+	for i := 0; i < n; i++ {
+		tw.AddTimer(time.Duration(i%3600+1)*time.Second, nil, nil)
+	}
+}
+
+func BenchmarkTimerWheel_AddTimer(b *testing.B) {
+	tw := schedule.NewTimerWheel(100*time.Millisecond, 100)
+	defer tw.Stop()
+	fillTimerWheel(tw, 1000000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tw.AddTimer(time.Duration(i%3600+1)*time.Second, nil, nil)
+	}
+}
+
+func BenchmarkTimerWheel_AddCancel(b *testing.B) {
+	tw := schedule.NewTimerWheel(100*time.Millisecond, 100)
+	defer tw.Stop()
+	fillTimerWheel(tw, 1000000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			i++
+			id, _ := tw.AddTimer(time.Duration(i%3600+1)*time.Second, nil, nil)
+			tw.CancelTimer(id)
+		}
+	})
+}
+
+func BenchmarkTimerWheel_UpdateTimer(b *testing.B) {
+	tw := schedule.NewTimerWheel(100*time.Millisecond, 100)
+	defer tw.Stop()
+	fillTimerWheel(tw, 1000000)
+	id, _ := tw.AddTimer(time.Hour, nil, nil)
+	now := time.Now()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tw.UpdateTimer(id, now.Add(time.Duration(i%3600+1)*time.Second))
+	}
+}
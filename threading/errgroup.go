@@ -0,0 +1,181 @@
+package threading
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is a panic recovered from a goroutine of a Group.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the recovered value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Group runs goroutines bound to a context. The first error, or recovered
+// panic, cancels the context of the group and is returned by Wait.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sem    chan struct{}
+
+	once sync.Once
+	err  error
+}
+
+func NewGroup(ctx context.Context) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{ctx: ctx, cancel: cancel}
+}
+
+// Context is canceled once a goroutine of the group failed or Wait returned.
+func (g *Group) Context() context.Context {
+	return g.ctx
+}
+
+// SetLimit bounds the number of goroutines running at once, it must not be
+// called while goroutines are running.
+func (g *Group) SetLimit(n int) {
+	if n <= 0 {
+		g.sem = nil
+		return
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// Go runs fn in a new goroutine, blocking while the limit is reached. Once
+// the context of the group is done, fn is not started and Wait returns the
+// error of the context, unless a goroutine failed first.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	g.start(fn)
+}
+
+// start runs fn as Go does and reports whether fn was started, for the
+// callers to release what fn would have released.
+func (g *Group) start(fn func(ctx context.Context) error) bool {
+	if err := g.ctx.Err(); err != nil {
+		g.fail(err)
+		return false
+	}
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		case <-g.ctx.Done():
+			g.fail(g.ctx.Err())
+			return false
+		}
+		// done while both cases were ready
+		if err := g.ctx.Err(); err != nil {
+			<-g.sem
+			g.fail(err)
+			return false
+		}
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := g.run(fn); err != nil {
+			g.fail(err)
+		}
+	}()
+	return true
+}
+
+// TryGo runs fn in a new goroutine unless the limit is reached.
+func (g *Group) TryGo(fn func(ctx context.Context) error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := g.run(fn); err != nil {
+			g.fail(err)
+		}
+	}()
+	return true
+}
+
+// Wait blocks until every goroutine returned and returns the first error.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
+func (g *Group) run(fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = &PanicError{Value: p, Stack: debug.Stack()}
+		}
+	}()
+	return fn(g.ctx)
+}
+
+func (g *Group) fail(err error) {
+	g.once.Do(func() {
+		g.err = err
+		g.cancel()
+	})
+}
+
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+// ParallelMap applies fn to every item with at most limit calls at once,
+// limit <= 0 meaning unbounded. Results keep the order of items; the first
+// error cancels the remaining calls and is returned.
+func ParallelMap[T, R any](ctx context.Context, items []T, limit int,
+	fn func(ctx context.Context, item T) (R, error)) ([]R, error) {
+	results := make([]R, len(items))
+	g := NewGroup(ctx)
+	g.SetLimit(limit)
+	for i := range items {
+		if g.ctx.Err() != nil {
+			break
+		}
+		i := i
+		g.Go(func(ctx context.Context) (err error) {
+			results[i], err = fn(ctx, items[i])
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ParallelForEach calls fn for every item with at most limit calls at once,
+// limit <= 0 meaning unbounded. The first error cancels the remaining calls
+// and is returned.
+func ParallelForEach[T any](ctx context.Context, items []T, limit int,
+	fn func(ctx context.Context, item T) error) error {
+	_, err := ParallelMap(ctx, items, limit, func(ctx context.Context, item T) (struct{}, error) {
+		return struct{}{}, fn(ctx, item)
+	})
+	return err
+}
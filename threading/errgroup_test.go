@@ -0,0 +1,167 @@
+package threading_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/threading"
+)
+
+func TestGroup(t *testing.T) {
+	errFirst := errors.New("first")
+	g := threading.NewGroup(context.Background())
+	g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	g.Go(func(ctx context.Context) error {
+		return errFirst
+	})
+	if err := g.Wait(); !errors.Is(err, errFirst) {
+		t.Fatalf("expected first error, got %v", err)
+	}
+
+	g = threading.NewGroup(context.Background())
+	g.Go(func(ctx context.Context) error {
+		panic("boom")
+	})
+	err := g.Wait()
+	var pe *threading.PanicError
+	if !errors.As(err, &pe) || pe.Value != "boom" || !strings.Contains(string(pe.Stack), "errgroup_test.go") {
+		t.Fatalf("expected recovered panic with stack, got %v", err)
+	}
+	if g.Context().Err() == nil {
+		t.Fatal("context should be canceled")
+	}
+
+	// a canceled group does not wait for the limit to start fn
+	ctx, cancel := context.WithCancel(context.Background())
+	g = threading.NewGroup(ctx)
+	g.SetLimit(1)
+	release := make(chan struct{})
+	g.Go(func(context.Context) error {
+		<-release
+		return nil
+	})
+	cancel()
+	var started atomic.Bool
+	g.Go(func(context.Context) error {
+		started.Store(true)
+		return nil
+	})
+	close(release)
+	if err = g.Wait(); !errors.Is(err, context.Canceled) || started.Load() {
+		t.Fatalf("started %v, got %v", started.Load(), err)
+	}
+
+	// nor without a limit
+	g = threading.NewGroup(ctx)
+	g.Go(func(context.Context) error {
+		started.Store(true)
+		return nil
+	})
+	if err = g.Wait(); !errors.Is(err, context.Canceled) || started.Load() {
+		t.Fatalf("started %v, got %v", started.Load(), err)
+	}
+}
+
+func TestParallelMap(t *testing.T) {
+	ctx := context.Background()
+	items := make([]int, 50)
+	for i := range items {
+		items[i] = i
+	}
+
+	var running, peak int32
+	results, err := threading.ParallelMap(ctx, items, 4, func(ctx context.Context, n int) (string, error) {
+		cur := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if cur <= p || atomic.CompareAndSwapInt32(&peak, p, cur) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return strconv.Itoa(n), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if r != strconv.Itoa(i) {
+			t.Fatalf("result %d: %s", i, r)
+		}
+	}
+	if p := atomic.LoadInt32(&peak); p > 4 {
+		t.Fatalf("peak concurrency %d", p)
+	}
+
+	errOdd := errors.New("odd")
+	var calls int32
+	err = threading.ParallelForEach(ctx, items, 1, func(ctx context.Context, n int) error {
+		atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			return errOdd
+		}
+		return nil
+	})
+	if !errors.Is(err, errOdd) {
+		t.Fatalf("expected error, got %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n == int32(len(items)) {
+		t.Fatal("remaining items should be skipped after an error")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	g := threading.NewGroup(context.Background())
+	words := threading.Source(g, "a", "bb", "ccc", "dddd")
+	lengths := threading.FanOut(g, words, 2)
+	var stages []<-chan int
+	for _, in := range lengths {
+		stages = append(stages, threading.Stage(g, in, 2, func(ctx context.Context, w string) (int, error) {
+			return len(w), nil
+		}))
+	}
+	got, err := threading.Collect(g, threading.FanIn(g, stages...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Ints(got)
+	if len(got) != 4 || got[0] != 1 || got[3] != 4 {
+		t.Fatalf("got %v", got)
+	}
+
+	errStage := errors.New("stage")
+	g = threading.NewGroup(context.Background())
+	numbers := make([]int, 100)
+	out := threading.Stage(g, threading.Source(g, numbers...), 3, func(ctx context.Context, n int) (int, error) {
+		return 0, errStage
+	})
+	if _, err := threading.Collect(g, out); !errors.Is(err, errStage) {
+		t.Fatalf("expected stage error, got %v", err)
+	}
+
+	// the stages of a canceled group close their outputs
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g = threading.NewGroup(ctx)
+	g.SetLimit(1)
+	words = threading.Source(g, "a", "bb")
+	stages = nil
+	for _, in := range threading.FanOut(g, words, 2) {
+		stages = append(stages, threading.Stage(g, in, 2, func(ctx context.Context, w string) (int, error) {
+			return len(w), nil
+		}))
+	}
+	if _, err := threading.Collect(g, threading.FanIn(g, stages...)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+}
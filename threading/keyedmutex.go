@@ -0,0 +1,94 @@
+package threading
+
+import (
+	"context"
+	"sync"
+)
+
+type keyedLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// KeyedMutex locks keys independently. The lock of a key is dropped once
+// no goroutine holds or waits for it, so that the set of keys can grow
+// unbounded without leaking memory.
+type KeyedMutex[K comparable] struct {
+	lock  sync.Mutex
+	locks map[K]*keyedLock
+}
+
+func NewKeyedMutex[K comparable]() *KeyedMutex[K] {
+	return &KeyedMutex[K]{locks: make(map[K]*keyedLock)}
+}
+
+func (m *KeyedMutex[K]) Lock(key K) {
+	_ = m.LockContext(context.Background(), key)
+}
+
+// LockContext blocks until key is locked or ctx is done.
+func (m *KeyedMutex[K]) LockContext(ctx context.Context, key K) error {
+	l := m.acquire(key)
+	select {
+	case l.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		m.release(key, l)
+		return ctx.Err()
+	}
+}
+
+// TryLock locks key without blocking, reporting success.
+func (m *KeyedMutex[K]) TryLock(key K) bool {
+	l := m.acquire(key)
+	select {
+	case l.ch <- struct{}{}:
+		return true
+	default:
+		m.release(key, l)
+		return false
+	}
+}
+
+func (m *KeyedMutex[K]) Unlock(key K) {
+	m.lock.Lock()
+	l, ok := m.locks[key]
+	m.lock.Unlock()
+	if !ok {
+		panic("KeyedMutex.Unlock of unlocked key")
+	}
+	select {
+	case <-l.ch:
+	default:
+		panic("KeyedMutex.Unlock of unlocked key")
+	}
+	m.release(key, l)
+}
+
+// Len returns the number of keys currently locked or waited for.
+func (m *KeyedMutex[K]) Len() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.locks)
+}
+
+func (m *KeyedMutex[K]) acquire(key K) *keyedLock {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	l, ok := m.locks[key]
+	if !ok {
+		l = &keyedLock{ch: make(chan struct{}, 1)}
+		m.locks[key] = l
+	}
+	l.refs++
+	return l
+}
+
+func (m *KeyedMutex[K]) release(key K, l *keyedLock) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(m.locks, key)
+	}
+}
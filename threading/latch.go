@@ -0,0 +1,53 @@
+package threading
+
+import (
+	"context"
+	"sync"
+)
+
+// CountDownLatch lets goroutines wait until a count of events happened.
+type CountDownLatch struct {
+	lock  sync.Mutex
+	count int
+	done  chan struct{}
+}
+
+func NewCountDownLatch(count int) *CountDownLatch {
+	if count < 0 {
+		panic("count must not be negative")
+	}
+	l := &CountDownLatch{count: count, done: make(chan struct{})}
+	if count == 0 {
+		close(l.done)
+	}
+	return l
+}
+
+// CountDown decrements the count, releasing the waiters when it reaches zero.
+func (l *CountDownLatch) CountDown() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.count == 0 {
+		return
+	}
+	l.count--
+	if l.count == 0 {
+		close(l.done)
+	}
+}
+
+// Await blocks until the count reaches zero or ctx is done.
+func (l *CountDownLatch) Await(ctx context.Context) error {
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *CountDownLatch) GetCount() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.count
+}
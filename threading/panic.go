@@ -0,0 +1,70 @@
+package threading
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+)
+
+// Panic describes a panic recovered by one of the infra components.
+type Panic struct {
+	Component string
+	Value     any
+	Stack     []byte
+	RoutineId uint64
+}
+
+func (p *Panic) String() string {
+	return fmt.Sprintf("%s|panic|goroutine %d|%v\n%s", p.Component, p.RoutineId, p.Value, p.Stack)
+}
+
+type PanicHandler func(p *Panic)
+
+var panicHandlers = struct {
+	lock     sync.RWMutex
+	id       int
+	handlers map[int]PanicHandler
+}{handlers: make(map[int]PanicHandler)}
+
+// OnPanic registers h to be called with every reported panic and returns a
+// func removing it. Without any handler, panics are written to the log.
+func OnPanic(h PanicHandler) (remove func()) {
+	panicHandlers.lock.Lock()
+	defer panicHandlers.lock.Unlock()
+	panicHandlers.id++
+	id := panicHandlers.id
+	panicHandlers.handlers[id] = h
+	return func() {
+		panicHandlers.lock.Lock()
+		defer panicHandlers.lock.Unlock()
+		delete(panicHandlers.handlers, id)
+	}
+}
+
+// ReportPanic hands the recovered value to the registered handlers. It must
+// be called from the goroutine that panicked, so that the stack and the
+// goroutine id are those of the panic.
+func ReportPanic(component string, value any) {
+	p := &Panic{
+		Component: component,
+		Value:     value,
+		Stack:     debug.Stack(),
+		RoutineId: RoutineId(),
+	}
+
+	panicHandlers.lock.RLock()
+	handlers := make([]PanicHandler, 0, len(panicHandlers.handlers))
+	for _, h := range panicHandlers.handlers {
+		handlers = append(handlers, h)
+	}
+	panicHandlers.lock.RUnlock()
+
+	if len(handlers) == 0 {
+		log.Println(p.String())
+		return
+	}
+	for _, h := range handlers {
+		h(p)
+	}
+}
@@ -0,0 +1,39 @@
+package threading_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/uaxe/infra/threading"
+)
+
+func TestOnPanic(t *testing.T) {
+	panics := make(chan *threading.Panic, 1)
+	remove := threading.OnPanic(func(p *threading.Panic) {
+		panics <- p
+	})
+
+	var id uint64
+	done := make(chan struct{})
+	threading.GoSafe(func() {
+		defer close(done)
+		id = threading.RoutineId()
+		panic("boom")
+	})
+	<-done
+	p := <-panics
+	if p.Component != "threading" || p.Value != "boom" || p.RoutineId != id {
+		t.Fatalf("panic %+v", p)
+	}
+	if !strings.Contains(string(p.Stack), "panic_test.go") {
+		t.Fatalf("stack should point at the panic:\n%s", p.Stack)
+	}
+
+	remove()
+	threading.RunSafe(func() { panic("logged") })
+	select {
+	case p := <-panics:
+		t.Fatalf("removed handler called with %v", p.Value)
+	default:
+	}
+}
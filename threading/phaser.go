@@ -0,0 +1,124 @@
+package threading
+
+import (
+	"context"
+	"sync"
+)
+
+// Phaser is a reusable barrier whose number of parties may change between
+// phases. A phase advances once every registered party arrived.
+type Phaser struct {
+	lock    sync.Mutex
+	phase   int
+	parties int
+	arrived int
+	advance chan struct{}
+}
+
+func NewPhaser(parties int) *Phaser {
+	if parties < 0 {
+		panic("parties must not be negative")
+	}
+	return &Phaser{parties: parties, advance: make(chan struct{})}
+}
+
+// Register adds a party and returns the current phase.
+func (p *Phaser) Register() int {
+	return p.BulkRegister(1)
+}
+
+// BulkRegister adds n parties and returns the current phase.
+func (p *Phaser) BulkRegister(n int) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.parties += n
+	return p.phase
+}
+
+// Arrive records the arrival of a party without waiting and returns the
+// phase it arrived at.
+func (p *Phaser) Arrive() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	phase := p.phase
+	p.arrived++
+	p.tryAdvance()
+	return phase
+}
+
+// ArriveAndDeregister records the arrival of a party and removes it from
+// the next phases.
+func (p *Phaser) ArriveAndDeregister() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.parties == 0 {
+		panic("Phaser.ArriveAndDeregister is called without registered parties")
+	}
+	phase := p.phase
+	p.parties--
+	p.tryAdvance()
+	return phase
+}
+
+// ArriveAndAwaitAdvance records the arrival of a party and waits for the
+// others, returning the new phase.
+func (p *Phaser) ArriveAndAwaitAdvance(ctx context.Context) (int, error) {
+	p.lock.Lock()
+	phase := p.phase
+	advance := p.advance
+	p.arrived++
+	p.tryAdvance()
+	p.lock.Unlock()
+	return p.await(ctx, phase, advance)
+}
+
+// AwaitAdvance waits for phase to advance, returning immediately if it
+// already did.
+func (p *Phaser) AwaitAdvance(ctx context.Context, phase int) (int, error) {
+	p.lock.Lock()
+	advance := p.advance
+	current := p.phase
+	p.lock.Unlock()
+	if current != phase {
+		return current, nil
+	}
+	return p.await(ctx, phase, advance)
+}
+
+func (p *Phaser) GetPhase() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.phase
+}
+
+func (p *Phaser) GetRegisteredParties() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.parties
+}
+
+func (p *Phaser) GetArrivedParties() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.arrived
+}
+
+func (p *Phaser) await(ctx context.Context, phase int, advance chan struct{}) (int, error) {
+	select {
+	case <-advance:
+		return phase + 1, nil
+	case <-ctx.Done():
+		return phase, ctx.Err()
+	}
+}
+
+// tryAdvance starts the next phase if every party arrived, p.lock must be held.
+func (p *Phaser) tryAdvance() {
+	if p.arrived < p.parties || (p.parties == 0 && p.arrived == 0) {
+		return
+	}
+	p.phase++
+	p.arrived = 0
+	close(p.advance)
+	p.advance = make(chan struct{})
+}
@@ -0,0 +1,162 @@
+package threading
+
+import (
+	"context"
+	"sync"
+)
+
+// Pipeline stages run on a Group: a failing stage cancels the group, so
+// that every other stage stops and closes its output, or closes it right
+// away when the group was done before it started. Consumers should
+// drain the last stage and then call Wait on the group.
+
+// Source emits items on the returned channel, which is closed once every
+// item was sent or the group is canceled.
+func Source[T any](g *Group, items ...T) <-chan T {
+	out := make(chan T)
+	started := g.start(func(ctx context.Context) error {
+		defer close(out)
+		for _, item := range items {
+			select {
+			case out <- item:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		return nil
+	})
+	if !started {
+		close(out)
+	}
+	return out
+}
+
+// Stage fans the values read from in out to workers goroutines applying fn,
+// and fans their results back in on the returned channel. Results are not
+// ordered.
+func Stage[In, Out any](g *Group, in <-chan In, workers int,
+	fn func(ctx context.Context, v In) (Out, error)) <-chan Out {
+	if workers <= 0 {
+		workers = 1
+	}
+	out := make(chan Out)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		started := g.start(func(ctx context.Context) error {
+			defer wg.Done()
+			for {
+				var (
+					v  In
+					ok bool
+				)
+				select {
+				case v, ok = <-in:
+					if !ok {
+						return nil
+					}
+				case <-ctx.Done():
+					return nil
+				}
+				r, err := fn(ctx, v)
+				if err != nil {
+					return err
+				}
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		})
+		if !started {
+			wg.Done()
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// FanOut distributes the values read from in across n channels, each
+// value going to whichever channel is ready first.
+func FanOut[T any](g *Group, in <-chan T, n int) []<-chan T {
+	outs := make([]<-chan T, n)
+	for i := range outs {
+		out := make(chan T)
+		outs[i] = out
+		started := g.start(func(ctx context.Context) error {
+			defer close(out)
+			for {
+				select {
+				case v, ok := <-in:
+					if !ok {
+						return nil
+					}
+					select {
+					case out <- v:
+					case <-ctx.Done():
+						return nil
+					}
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		})
+		if !started {
+			close(out)
+		}
+	}
+	return outs
+}
+
+// FanIn merges the values read from ins on the returned channel, which is
+// closed once every input is closed or the group is canceled.
+func FanIn[T any](g *Group, ins ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	wg.Add(len(ins))
+	for _, in := range ins {
+		in := in
+		started := g.start(func(ctx context.Context) error {
+			defer wg.Done()
+			for {
+				select {
+				case v, ok := <-in:
+					if !ok {
+						return nil
+					}
+					select {
+					case out <- v:
+					case <-ctx.Done():
+						return nil
+					}
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		})
+		if !started {
+			wg.Done()
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// Collect reads in until it is closed and waits for the group.
+func Collect[T any](g *Group, in <-chan T) ([]T, error) {
+	var items []T
+	for v := range in {
+		items = append(items, v)
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -2,10 +2,7 @@ package threading
 
 import (
 	"bytes"
-	"fmt"
-	"log"
 	"runtime"
-	"runtime/debug"
 	"strconv"
 )
 
@@ -22,11 +19,11 @@ func RoutineId() uint64 {
 	return n
 }
 
+// RunSafe runs fn, reporting a panic to the handlers registered by OnPanic.
 func RunSafe(fn func()) {
 	defer func() {
 		if p := recover(); p != nil {
-			msg := fmt.Sprintf("%v\n%s", p, string(debug.Stack()))
-			log.Println(msg)
+			ReportPanic("threading", p)
 		}
 	}()
 	fn()
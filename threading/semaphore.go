@@ -0,0 +1,109 @@
+package threading
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+// Semaphore is a weighted semaphore. Waiters are served in FIFO order, so
+// that a large acquisition is not starved by smaller ones.
+type Semaphore struct {
+	size    int64
+	lock    sync.Mutex
+	cur     int64
+	waiters list.List
+}
+
+func NewSemaphore(size int64) *Semaphore {
+	if size <= 0 {
+		panic("semaphore size must be positive number")
+	}
+	return &Semaphore{size: size}
+}
+
+// Acquire blocks until n units are acquired or ctx is done. An acquisition
+// larger than the size of the semaphore fails only once ctx is done.
+func (s *Semaphore) Acquire(ctx context.Context, n int64) error {
+	s.lock.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.lock.Unlock()
+		return nil
+	}
+	if n > s.size {
+		s.lock.Unlock()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	w := semaphoreWaiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.lock.Lock()
+		select {
+		case <-w.ready:
+			// acquired in the meantime, give the units back
+			s.cur -= n
+			s.notifyWaiters()
+		default:
+			front := s.waiters.Front() == elem
+			s.waiters.Remove(elem)
+			if front && s.size > s.cur {
+				s.notifyWaiters()
+			}
+		}
+		s.lock.Unlock()
+		return ctx.Err()
+	}
+}
+
+// TryAcquire acquires n units without blocking, reporting success.
+func (s *Semaphore) TryAcquire(n int64) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		return true
+	}
+	return false
+}
+
+// Release gives back n units.
+func (s *Semaphore) Release(n int64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cur -= n
+	if s.cur < 0 {
+		panic("semaphore released more than held")
+	}
+	s.notifyWaiters()
+}
+
+// notifyWaiters wakes the waiters at the front of the queue that fit,
+// s.lock must be held.
+func (s *Semaphore) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+		w := next.Value.(semaphoreWaiter)
+		if s.size-s.cur < w.n {
+			return
+		}
+		s.cur += w.n
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}
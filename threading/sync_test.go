@@ -0,0 +1,164 @@
+package threading_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/threading"
+)
+
+func TestSemaphore(t *testing.T) {
+	ctx := context.Background()
+	sem := threading.NewSemaphore(4)
+
+	var held, peak int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		n := int64(i%3 + 1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sem.Acquire(ctx, n); err != nil {
+				t.Error(err)
+				return
+			}
+			cur := atomic.AddInt64(&held, n)
+			for {
+				p := atomic.LoadInt64(&peak)
+				if cur <= p || atomic.CompareAndSwapInt64(&peak, p, cur) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&held, -n)
+			sem.Release(n)
+		}()
+	}
+	wg.Wait()
+	if p := atomic.LoadInt64(&peak); p > 4 {
+		t.Fatalf("peak weight %d", p)
+	}
+
+	if !sem.TryAcquire(3) || sem.TryAcquire(2) {
+		t.Fatal("TryAcquire should respect the remaining weight")
+	}
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if err := sem.Acquire(timeout, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	sem.Release(3)
+	if !sem.TryAcquire(4) {
+		t.Fatal("canceled waiter should not hold units")
+	}
+}
+
+func TestCountDownLatch(t *testing.T) {
+	latch := threading.NewCountDownLatch(5)
+	for i := 0; i < 5; i++ {
+		go latch.CountDown()
+	}
+	if err := latch.Await(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if latch.GetCount() != 0 {
+		t.Fatalf("count %d", latch.GetCount())
+	}
+
+	latch = threading.NewCountDownLatch(1)
+	timeout, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := latch.Await(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestPhaser(t *testing.T) {
+	ctx := context.Background()
+	phaser := threading.NewPhaser(1)
+
+	var wg sync.WaitGroup
+	var arrivals int32
+	for i := 0; i < 3; i++ {
+		phaser.Register()
+		wg.Add(1)
+		go func(rounds int) {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				atomic.AddInt32(&arrivals, 1)
+				if _, err := phaser.ArriveAndAwaitAdvance(ctx); err != nil {
+					t.Error(err)
+				}
+			}
+			phaser.ArriveAndDeregister()
+		}(i + 1)
+	}
+
+	// the controlling party follows phases until everybody deregistered
+	for phase := 0; phaser.GetRegisteredParties() > 1; phase++ {
+		next, err := phaser.ArriveAndAwaitAdvance(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next != phase+1 {
+			t.Fatalf("phase %d after %d", next, phase)
+		}
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&arrivals); n != 6 {
+		t.Fatalf("arrivals %d", n)
+	}
+
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	phaser.Register()
+	if _, err := phaser.ArriveAndAwaitAdvance(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestKeyedMutex(t *testing.T) {
+	m := threading.NewKeyedMutex[string]()
+	counters := map[string]*int{"a": new(int), "b": new(int)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		key := "a"
+		if i%2 == 1 {
+			key = "b"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.Lock(key)
+			defer m.Unlock(key)
+			*counters[key]++
+		}()
+	}
+	wg.Wait()
+	if *counters["a"] != 50 || *counters["b"] != 50 {
+		t.Fatalf("counters %d %d", *counters["a"], *counters["b"])
+	}
+	if m.Len() != 0 {
+		t.Fatalf("unused keys should be dropped, %d left", m.Len())
+	}
+
+	m.Lock("a")
+	if m.TryLock("a") || !m.TryLock("b") {
+		t.Fatal("keys should be locked independently")
+	}
+	timeout, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.LockContext(timeout, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	m.Unlock("a")
+	m.Unlock("b")
+	if m.Len() != 0 {
+		t.Fatalf("keys left %d", m.Len())
+	}
+}
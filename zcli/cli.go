@@ -1,6 +1,7 @@
 package zcli
 
 import (
+	"flag"
 	"fmt"
 	"os"
 )
@@ -113,6 +114,10 @@ func (c *Cli) IntFlag(name, description string, variable *int) *Cli {
 	return c
 }
 
+func (c *Cli) Var(value flag.Value, name, description string) {
+	c.rootCommand.Var(value, name, description)
+}
+
 func (c *Cli) AddFlags(flags any) *Cli {
 	c.rootCommand.AddFlags(flags)
 	return c
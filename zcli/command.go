@@ -188,6 +188,13 @@ func (c *Command) IntFlag(name, description string, variable *int) *Command {
 	return c
 }
 
+// Var registers a flag of a custom type, so that commands can be bound by
+// packages defining their own flag values.
+func (c *Command) Var(value flag.Value, name, description string) {
+	c.flags.Var(value, name, description)
+	c.flagCount++
+}
+
 func (c *Command) AddFlags(optionStruct any) *Command {
 
 	t := reflect.TypeOf(optionStruct)
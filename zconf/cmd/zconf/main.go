@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/uaxe/infra/zcli"
+	"github.com/uaxe/infra/zconf/encryptcmd"
+)
+
+func main() {
+	cli := zcli.NewCli("zconf", "Config file tools", "0.1.0")
+	encryptcmd.AddCommand(cli, os.Stdout)
+	if err := cli.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
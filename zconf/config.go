@@ -5,36 +5,87 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 )
 
-var (
-	drivers = map[string]Driver{
-		".json": &JSONDirver,
-		".toml": &TOMLDirver,
-		".yaml": &YAMLDirver,
-		".yml":  &YAMLDirver,
+var drivers = struct {
+	lock sync.RWMutex
+	exts map[string]Driver
+}{
+	exts: map[string]Driver{
+		".json":       &JSONDirver,
+		".toml":       &TOMLDirver,
+		".yaml":       &YAMLDirver,
+		".yml":        &YAMLDirver,
+		".ini":        &INIDirver,
+		".env":        &DotenvDirver,
+		".properties": &PropertiesDirver,
+		".hcl":        &HCLDirver,
+	},
+}
+
+// RegisterDriver makes Load, Save and Watch read and write the files of
+// extension ext, ".conf" or "conf", with d, replacing the previous driver.
+func RegisterDriver(ext string, d Driver) {
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
 	}
-)
+	drivers.lock.Lock()
+	defer drivers.lock.Unlock()
+	drivers.exts[strings.ToLower(ext)] = d
+}
+
+// LookupDriver returns the driver of a file extension, or of a driver name
+// such as "yaml" or "dotenv".
+func LookupDriver(ext string) (Driver, bool) {
+	drivers.lock.RLock()
+	defer drivers.lock.RUnlock()
+	ext = strings.ToLower(ext)
+	if d, ok := drivers.exts[ext]; ok {
+		return d, true
+	}
+	if d, ok := drivers.exts["."+ext]; ok {
+		return d, true
+	}
+	for _, d := range drivers.exts {
+		if d.Name() == ext {
+			return d, true
+		}
+	}
+	return nil, false
+}
 
 func Load(fpath string, val any) error {
+	dirver, raw, err := read(fpath)
+	if err != nil {
+		return err
+	}
+	if err = unmarshal(dirver, raw, val); err != nil {
+		return err
+	}
+	return validateFile(fpath, val)
+}
+
+func read(fpath string) (Driver, []byte, error) {
 	ext := filepath.Ext(fpath)
-	dirver, ok := drivers[ext]
-	if !ok {
-		return fmt.Errorf("%s dirver not support", ext)
+	dirver, ok := LookupDriver(ext)
+	if !ok || ext == "" {
+		return nil, nil, fmt.Errorf("%s dirver not support", ext)
 	}
 	fi, err := os.Open(fpath)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer func() { _ = fi.Close() }()
 	if _, err = fi.Stat(); err != nil {
-		return err
+		return nil, nil, err
 	}
 	raw, err := io.ReadAll(fi)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
-	return dirver.Unmarshal(raw, val)
+	return dirver, raw, nil
 }
 
 func LoadJSONBytes(raw []byte, val any) error {
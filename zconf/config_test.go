@@ -38,6 +38,10 @@ func TestLoad(t *testing.T) {
 		if !strings.EqualFold(cfg.Settings.Database.Driver, "mysql") {
 			t.Fatalf("%v", cfg.Settings.Database)
 		}
+		var m map[string]any
+		if err = zconf.Load(path, &m); err != nil || m["settings"] == nil {
+			t.Fatalf("%s: %v %v", path, m, err)
+		}
 		return nil
 	})
 	if err != nil {
@@ -4,6 +4,11 @@ const (
 	YamlName = "yaml"
 	JsonName = "json"
 	TomlName = "toml"
+
+	IniName        = "ini"
+	DotenvName     = "dotenv"
+	PropertiesName = "properties"
+	HclName        = "hcl"
 )
 
 type Driver interface {
@@ -0,0 +1,107 @@
+package zconf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dotenv reads NAME=value lines, double underscores separating the nested
+// keys: DB__MAX_CONNS sets db.maxConns.
+type Dotenv struct {
+	name        string
+	marshaler   func(any) ([]byte, error)
+	unmarshaler func([]byte, any) error
+}
+
+var DotenvDirver = Dotenv{
+	name: DotenvName,
+	marshaler: func(in any) ([]byte, error) {
+		tree, err := encodeTree(in)
+		if err != nil {
+			return nil, err
+		}
+		return marshalDotenv(tree)
+	},
+	unmarshaler: func(in []byte, out any) error {
+		tree, err := parseDotenv(in)
+		if err != nil {
+			return err
+		}
+		return decodeTree(tree, out)
+	},
+}
+
+var _ Driver = (*Dotenv)(nil)
+
+func (d *Dotenv) Name() string {
+	return d.name
+}
+
+func (d *Dotenv) Marshal(in any) ([]byte, error) {
+	return d.marshaler(in)
+}
+
+func (d *Dotenv) Unmarshal(in []byte, out any) error {
+	return d.unmarshaler(in, out)
+}
+
+const dotenvSep = "__"
+
+func parseDotenv(in []byte) (map[string]any, error) {
+	tree := make(map[string]any)
+	scanner := bufio.NewScanner(bytes.NewReader(in))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("dotenv: line %d: expected NAME=value, got %q", n, line)
+		}
+		value, err := dotenvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("dotenv: line %d: %w", n, err)
+		}
+		path := strings.Split(strings.ToLower(strings.TrimSpace(name)), dotenvSep)
+		if err = setPath(tree, path, value); err != nil {
+			return nil, fmt.Errorf("dotenv: line %d: %w", n, err)
+		}
+	}
+	return tree, scanner.Err()
+}
+
+// dotenvValue unquotes "..." values with their escapes and '...' values as
+// they are, and strips the comment of unquoted ones.
+func dotenvValue(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return s[1 : len(s)-1], nil
+	}
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s, nil
+}
+
+func marshalDotenv(tree map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, leaf := range flatLeaves(tree) {
+		s, err := scalarString(leaf.value)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.ToUpper(strings.Join(leaf.path, dotenvSep))
+		if s == "" || strings.ContainsAny(s, " \t\n\"'#\\$") {
+			s = strconv.Quote(s)
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", name, s)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,251 @@
+package zconf_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/zconf"
+)
+
+type serverConfig struct {
+	Name    string        `yaml:"name"`
+	Debug   bool          `yaml:"debug"`
+	Timeout time.Duration `yaml:"timeout"`
+	Tags    []string      `yaml:"tags"`
+	DB      struct {
+		Host     string  `yaml:"host"`
+		Port     int     `yaml:"port"`
+		MaxConns int     `yaml:"maxConns"`
+		Ratio    float64 `yaml:"ratio"`
+		Password string  `yaml:"password"`
+	} `yaml:"db"`
+}
+
+func TestDrivers_Parse(t *testing.T) {
+	files := map[string]string{
+		"app.ini": `; comment
+name = api
+debug = true
+timeout = 5s
+tags[] = a
+tags[] = b
+
+[db]
+host = "db.local"
+port = 5432
+maxConns = 10 ; inline comment
+ratio = 0.5
+password = 'null'
+`,
+		"app.env": `# comment
+NAME=api
+export DEBUG=true
+TIMEOUT=5s
+TAGS=a,b
+DB__HOST="db.local"
+DB__PORT=5432
+DB__MAX_CONNS=10
+DB__RATIO=0.5
+DB__PASSWORD='null'
+`,
+		"app.properties": `# comment
+name=api
+debug: true
+timeout 5s
+tags=a,\
+  b
+db.host=db.local
+db.port=5432
+db.maxConns=10
+db.ratio=0.5
+db.password=null
+`,
+		"app.hcl": `# comment
+name    = "api"
+debug   = true
+timeout = "5s"
+tags    = ["a", "b"]
+
+/* the database */
+db {
+  host     = "db.local"
+  port     = 5432
+  maxConns = 10 // inline comment
+  ratio    = 0.5
+  password = "null"
+}
+`,
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var cfg serverConfig
+		if err := zconf.Load(path, &cfg); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if cfg.Name != "api" || !cfg.Debug || cfg.Timeout != 5*time.Second ||
+			!reflect.DeepEqual(cfg.Tags, []string{"a", "b"}) || cfg.DB.Host != "db.local" ||
+			cfg.DB.Port != 5432 || cfg.DB.MaxConns != 10 || cfg.DB.Ratio != 0.5 || cfg.DB.Password != "null" {
+			t.Fatalf("%s: %+v", name, cfg)
+		}
+	}
+}
+
+func TestSave_RoundTrip(t *testing.T) {
+	var want serverConfig
+	want.Name, want.Debug, want.Timeout, want.Tags = "api # main", true, time.Minute, []string{"a", "b"}
+	want.DB.Host, want.DB.Port, want.DB.MaxConns, want.DB.Ratio = "db.local", 5432, 1000000, 0.25
+	want.DB.Password = "p=a:ss word\n"
+
+	dir := t.TempDir()
+	for _, ext := range []string{".json", ".yaml", ".toml", ".ini", ".env", ".properties", ".hcl"} {
+		path := filepath.Join(dir, "app"+ext)
+		if err := zconf.Save(path, &want); err != nil {
+			t.Fatalf("%s: %v", ext, err)
+		}
+		var got serverConfig
+		if err := zconf.Load(path, &got); err != nil {
+			raw, _ := os.ReadFile(path)
+			t.Fatalf("%s: %v\n%s", ext, err, raw)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: got %+v, want %+v", ext, got, want)
+		}
+	}
+}
+
+func TestHCL_Blocks(t *testing.T) {
+	raw := []byte(`
+service "web" {
+  port = 80
+}
+service "api" {
+  port = 8080
+  env  = { MODE = "prod" }
+  script = <<-EOF
+    echo hello
+    echo world
+    EOF
+}
+listener { port = 1 }
+listener { port = 2 }
+`)
+	var got struct {
+		Service map[string]struct {
+			Port   int               `yaml:"port"`
+			Env    map[string]string `yaml:"env"`
+			Script string            `yaml:"script"`
+		} `yaml:"service"`
+		Listener []struct {
+			Port int `yaml:"port"`
+		} `yaml:"listener"`
+	}
+	if err := zconf.HCLDirver.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	api := got.Service["api"]
+	if got.Service["web"].Port != 80 || api.Port != 8080 || api.Env["MODE"] != "prod" ||
+		api.Script != "echo hello\necho world\n" || len(got.Listener) != 2 || got.Listener[1].Port != 2 {
+		t.Fatalf("%+v", got)
+	}
+
+	if err := zconf.HCLDirver.Unmarshal([]byte("a = \n"), &got); err == nil ||
+		!strings.Contains(err.Error(), "line") {
+		t.Fatalf("expected a located error, got %v", err)
+	}
+	if err := zconf.HCLDirver.Unmarshal([]byte("a = var.b\n"), &got); err == nil {
+		t.Fatal("unknown variable accepted")
+	}
+
+	var exprs struct {
+		Sum   int      `yaml:"sum"`
+		Name  string   `yaml:"name"`
+		Hosts []string `yaml:"hosts"`
+		Host  string   `yaml:"host"`
+	}
+	raw = []byte(`
+sum   = 1 + 2
+name  = upper("api")
+hosts = [for n in [1, 2] : "db${n}.local"]
+host  = "${ENV:DB_HOST}"
+`)
+	if err := zconf.HCLDirver.Unmarshal(raw, &exprs); err != nil {
+		t.Fatal(err)
+	}
+	if exprs.Sum != 3 || exprs.Name != "API" || !reflect.DeepEqual(exprs.Hosts, []string{"db1.local", "db2.local"}) ||
+		exprs.Host != "${ENV:DB_HOST}" {
+		t.Fatalf("%+v", exprs)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.yaml")
+	if err := os.WriteFile(src, []byte("name: api\ndb:\n  port: 5432\n  host: ${ENV:DB_HOST}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(dir, "app.properties")
+	if err := zconf.Convert(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "db.host=${ENV\\:DB_HOST}\ndb.port=5432\nname=api\n"; string(raw) != want {
+		t.Fatalf("got %q, want %q", raw, want)
+	}
+
+	out, err := zconf.ConvertBytes(raw, "properties", "env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "DB__HOST=\"${ENV:DB_HOST}\"\nDB__PORT=5432\nNAME=api\n"; string(out) != want {
+		t.Fatalf("got %q, want %q", out, want)
+	}
+
+	if out, err = zconf.ConvertBytes(raw, "properties", "hcl"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err = zconf.ConvertBytes(out, "hcl", "properties"); err != nil || string(out) != string(raw) {
+		t.Fatalf("got %q, %v", out, err)
+	}
+}
+
+type upperDriver struct{}
+
+func (upperDriver) Name() string { return "upper" }
+
+func (upperDriver) Marshal(in any) ([]byte, error) {
+	return []byte(strings.ToUpper(in.(*struct{ Name string }).Name)), nil
+}
+
+func (upperDriver) Unmarshal(in []byte, out any) error {
+	out.(*struct{ Name string }).Name = strings.ToUpper(string(in))
+	return nil
+}
+
+func TestRegisterDriver(t *testing.T) {
+	zconf.RegisterDriver("upper", upperDriver{})
+	if d, ok := zconf.LookupDriver(".upper"); !ok || d.Name() != "upper" {
+		t.Fatalf("driver not registered")
+	}
+	path := filepath.Join(t.TempDir(), "app.upper")
+	if err := zconf.Save(path, &struct{ Name string }{Name: "api"}); err != nil {
+		t.Fatal(err)
+	}
+	var got struct{ Name string }
+	if err := zconf.Load(path, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "API" {
+		t.Fatalf("got %q", got.Name)
+	}
+}
@@ -0,0 +1,71 @@
+// Package encryptcmd is the zcli command encrypting the ${enc:...} values
+// of zconf files, kept apart so that zconf does not depend on zcli.
+package encryptcmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/uaxe/infra/crypto"
+	"github.com/uaxe/infra/zcli"
+	"github.com/uaxe/infra/zconf"
+)
+
+type encryptFlags struct {
+	Key   string `name:"key" description:"Path of the PEM public key, $ZCONF_PUBLIC_KEY by default"`
+	Value string `name:"value" description:"Value to encrypt, read from stdin when empty"`
+}
+
+// AddCommand adds to cli the "encrypt" subcommand, printing the
+// ${enc:...} placeholder of a value so that secrets can be committed
+// encrypted:
+//
+//	app encrypt -key public.pem -value s3cret
+//	app encrypt -key public.pem < secret.txt
+func AddCommand(cli *zcli.Cli, out io.Writer) *zcli.Command {
+	flags := &encryptFlags{}
+	cmd := cli.NewSubCommand("encrypt", "Encrypt a config value with the master RSA public key")
+	cmd.LongDescription("Prints the ${enc:...} placeholder to write in config files.")
+	cmd.AddFlags(flags)
+	cmd.Action(func() error {
+		return encrypt(flags, os.Stdin, out)
+	})
+	return cmd
+}
+
+func encrypt(flags *encryptFlags, in io.Reader, out io.Writer) error {
+	path := flags.Key
+	if path == "" {
+		path = os.Getenv("ZCONF_PUBLIC_KEY")
+	}
+	if path == "" {
+		return errors.New("encrypt: -key is required")
+	}
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	value := flags.Value
+	if value == "" {
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		value = strings.TrimRight(line, "\r\n")
+	}
+	c, err := crypto.CreateMasterRsa(nil, string(key), "")
+	if err != nil {
+		return err
+	}
+	placeholder, err := zconf.EncryptValue(c, value)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, placeholder)
+	return err
+}
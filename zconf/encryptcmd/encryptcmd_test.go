@@ -0,0 +1,60 @@
+package encryptcmd_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uaxe/infra/crypto"
+	"github.com/uaxe/infra/zcli"
+	"github.com/uaxe/infra/zconf/encryptcmd"
+)
+
+func TestEncryptCommand(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubPath := filepath.Join(t.TempDir(), "public.pem")
+	if err = os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	cli := zcli.NewCli("app", "test", "0")
+	encryptcmd.AddCommand(cli, &out)
+	if err = cli.Run("encrypt", "-key", pubPath, "-value", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	encrypted := strings.TrimSpace(out.String())
+	if !strings.HasPrefix(encrypted, "${enc:") || !strings.HasSuffix(encrypted, "}") {
+		t.Fatalf("placeholder %q", encrypted)
+	}
+
+	priv, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := crypto.CreateMasterRsa(nil, "", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priv})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encrypted[len("${enc:") : len(encrypted)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := c.Decrypt(raw); err != nil || string(plain) != "s3cret" {
+		t.Fatalf("decrypted %q, %v", plain, err)
+	}
+}
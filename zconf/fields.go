@@ -0,0 +1,167 @@
+package zconf
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// field is a leaf of a config struct, addressed by the dotted path of its
+// tag names, e.g. "db.host".
+type field struct {
+	key   string
+	path  []string
+	value reflect.Value
+	tag   reflect.StructTag
+}
+
+// fields lists the leaves of the struct pointed to by val.
+func fields(val any) ([]field, error) {
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("zconf: %T is not a pointer to a struct", val)
+	}
+	return appendFields(nil, nil, v.Elem()), nil
+}
+
+func appendFields(dst []field, parent []string, v reflect.Value) []field {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := fieldName(sf)
+		if name == "-" {
+			continue
+		}
+		path := append(append([]string(nil), parent...), name)
+		fv := v.Field(i)
+		if isLeaf(sf.Type) {
+			dst = append(dst, field{key: strings.Join(path, "."), path: path, value: fv, tag: sf.Tag})
+			continue
+		}
+		if sf.Anonymous {
+			path = parent
+		}
+		dst = appendFields(dst, path, fv)
+	}
+	return dst
+}
+
+// fieldName returns the name of sf in config files, from its yaml, json or
+// toml tag, or its lower-cased name.
+func fieldName(sf reflect.StructField) string {
+	for _, key := range []string{"yaml", "json", "toml"} {
+		if name, _, _ := strings.Cut(sf.Tag.Get(key), ","); name != "" {
+			return name
+		}
+	}
+	return strings.ToLower(sf.Name)
+}
+
+func isLeaf(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return true
+	}
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// setValue parses s into v: strings, booleans, numbers, durations, comma
+// separated slices and encoding.TextUnmarshaler are supported.
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), s)
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var parts []string
+		if s != "" {
+			parts = strings.Split(s, ",")
+		}
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setValue(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// flatten lists the dotted keys of a decoded config document.
+func flatten(dst map[string]struct{}, prefix string, doc any) {
+	switch m := doc.(type) {
+	case map[string]any:
+		for k, v := range m {
+			flatten(dst, join(prefix, k), v)
+		}
+	case map[any]any:
+		for k, v := range m {
+			flatten(dst, join(prefix, fmt.Sprint(k)), v)
+		}
+	default:
+		if prefix != "" {
+			dst[prefix] = struct{}{}
+		}
+	}
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
@@ -0,0 +1,245 @@
+package zconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// HCL reads and writes HashiCorp configuration files with hcl/v2. Blocks
+// nest under their name and labels, `service "web" { ... }` setting
+// service.web, and repeated blocks of the same name make a list.
+//
+// Expressions are evaluated without variables, with the functions of
+// hclFunctions. The zconf placeholders, such as ${ENV:DB_HOST}, are not HCL
+// templates: they are kept as written and resolved after decoding, see
+// Interpolate.
+type HCL struct {
+	name        string
+	marshaler   func(any) ([]byte, error)
+	unmarshaler func([]byte, any) error
+}
+
+var HCLDirver = HCL{
+	name: HclName,
+	marshaler: func(in any) ([]byte, error) {
+		tree, err := encodeTree(in)
+		if err != nil {
+			return nil, err
+		}
+		f := hclwrite.NewEmptyFile()
+		if err = writeHCLBody(f.Body(), tree); err != nil {
+			return nil, err
+		}
+		return f.Bytes(), nil
+	},
+	unmarshaler: func(in []byte, out any) error {
+		file, diags := hclsyntax.ParseConfig(escapePlaceholders(in), HclName, hcl.InitialPos)
+		if diags.HasErrors() {
+			return hclError(diags)
+		}
+		tree, err := readHCLBody(file.Body.(*hclsyntax.Body))
+		if err != nil {
+			return err
+		}
+		return decodeTree(tree, out)
+	},
+}
+
+var _ Driver = (*HCL)(nil)
+
+func (h *HCL) Name() string {
+	return h.name
+}
+
+func (h *HCL) Marshal(in any) ([]byte, error) {
+	return h.marshaler(in)
+}
+
+func (h *HCL) Unmarshal(in []byte, out any) error {
+	return h.unmarshaler(in, out)
+}
+
+// hclFunctions are the functions HCL expressions may call.
+var hclFunctions = map[string]function.Function{
+	"abs":        stdlib.AbsoluteFunc,
+	"coalesce":   stdlib.CoalesceFunc,
+	"concat":     stdlib.ConcatFunc,
+	"format":     stdlib.FormatFunc,
+	"join":       stdlib.JoinFunc,
+	"jsonencode": stdlib.JSONEncodeFunc,
+	"length":     stdlib.LengthFunc,
+	"lower":      stdlib.LowerFunc,
+	"max":        stdlib.MaxFunc,
+	"min":        stdlib.MinFunc,
+	"replace":    stdlib.ReplaceFunc,
+	"split":      stdlib.SplitFunc,
+	"trimspace":  stdlib.TrimSpaceFunc,
+	"upper":      stdlib.UpperFunc,
+}
+
+// placeholderRe matches the zconf placeholders not escaped as $${.
+var placeholderRe = regexp.MustCompile(`(^|[^$])\$\{([A-Za-z]+:)`)
+
+// escapePlaceholders escapes the zconf placeholders for HCL not to parse
+// them as templates.
+func escapePlaceholders(in []byte) []byte {
+	return placeholderRe.ReplaceAll(in, []byte("$1$$$${$2"))
+}
+
+func hclError(diags hcl.Diagnostics) error {
+	for _, diag := range diags {
+		if diag.Severity != hcl.DiagError {
+			continue
+		}
+		if diag.Subject != nil {
+			return fmt.Errorf("zconf: hcl line %d: %s; %s", diag.Subject.Start.Line, diag.Summary, diag.Detail)
+		}
+		return fmt.Errorf("zconf: hcl: %s; %s", diag.Summary, diag.Detail)
+	}
+	return diags
+}
+
+func readHCLBody(body *hclsyntax.Body) (map[string]any, error) {
+	ctx := &hcl.EvalContext{Functions: hclFunctions}
+	tree := make(map[string]any, len(body.Attributes)+len(body.Blocks))
+	for name, attr := range body.Attributes {
+		val, diags := attr.Expr.Value(ctx)
+		if diags.HasErrors() {
+			return nil, hclError(diags)
+		}
+		v, err := hclValue(val)
+		if err != nil {
+			return nil, fmt.Errorf("zconf: hcl line %d: %s: %w", attr.SrcRange.Start.Line, name, err)
+		}
+		tree[name] = v
+	}
+	for _, block := range body.Blocks {
+		child, err := readHCLBody(block.Body)
+		if err != nil {
+			return nil, err
+		}
+		path := append([]string{block.Type}, block.Labels...)
+		if err = addHCLBlock(tree, path, child); err != nil {
+			return nil, fmt.Errorf("zconf: hcl line %d: %w", block.TypeRange.Start.Line, err)
+		}
+	}
+	return tree, nil
+}
+
+// hclValue converts val to the map[string]any, []any and scalars of a tree.
+func hclValue(val cty.Value) (any, error) {
+	if !val.IsWhollyKnown() {
+		return nil, fmt.Errorf("unknown value")
+	}
+	raw, err := ctyjson.SimpleJSONValue{Value: val}.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	err = json.Unmarshal(raw, &v)
+	return v, err
+}
+
+// addHCLBlock nests child under its name and labels, turning a block
+// defined twice into a list of blocks.
+func addHCLBlock(body map[string]any, path []string, child map[string]any) error {
+	m := body
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]any)
+		if !ok {
+			if _, exists := m[key]; exists {
+				return fmt.Errorf("%s is both an attribute and a block", key)
+			}
+			next = make(map[string]any)
+			m[key] = next
+		}
+		m = next
+	}
+	last := path[len(path)-1]
+	switch prev := m[last].(type) {
+	case nil:
+		m[last] = child
+	case map[string]any:
+		m[last] = []any{prev, child}
+	case []any:
+		m[last] = append(prev, child)
+	default:
+		return fmt.Errorf("%s is both an attribute and a block", last)
+	}
+	return nil
+}
+
+// writeHCLBody writes the attributes of m, then its objects as blocks when
+// their keys are identifiers.
+func writeHCLBody(body *hclwrite.Body, m map[string]any) error {
+	var blocks []string
+	for _, k := range sortedKeys(m) {
+		if !hclsyntax.ValidIdentifier(k) {
+			return fmt.Errorf("zconf: hcl: key %q is not an identifier", k)
+		}
+		if isHCLBlock(m[k]) {
+			blocks = append(blocks, k)
+			continue
+		}
+		val, err := ctyValue(m[k])
+		if err != nil {
+			return err
+		}
+		body.SetAttributeValue(k, val)
+	}
+	for _, k := range blocks {
+		children, ok := m[k].([]any)
+		if !ok {
+			children = []any{m[k]}
+		}
+		for _, child := range children {
+			if err := writeHCLBody(body.AppendNewBlock(k, nil).Body(), child.(map[string]any)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isHCLBlock reports whether v is written as blocks: an object or a non
+// empty list of objects, whose keys are identifiers.
+func isHCLBlock(v any) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		for k := range v {
+			if !hclsyntax.ValidIdentifier(k) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		for _, item := range v {
+			if _, ok := item.(map[string]any); !ok || !isHCLBlock(item) {
+				return false
+			}
+		}
+		return len(v) > 0
+	}
+	return false
+}
+
+func ctyValue(v any) (cty.Value, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	ty, err := ctyjson.ImpliedType(raw)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(raw, ty)
+}
@@ -0,0 +1,179 @@
+package zconf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// INI reads the sections of an ini file as nested keys, [db.primary] being
+// the section of db.primary.host. `key[] = value` lines append to a list.
+type INI struct {
+	name        string
+	marshaler   func(any) ([]byte, error)
+	unmarshaler func([]byte, any) error
+}
+
+var INIDirver = INI{
+	name: IniName,
+	marshaler: func(in any) ([]byte, error) {
+		tree, err := encodeTree(in)
+		if err != nil {
+			return nil, err
+		}
+		return marshalINI(tree)
+	},
+	unmarshaler: func(in []byte, out any) error {
+		tree, err := parseINI(in)
+		if err != nil {
+			return err
+		}
+		return decodeTree(tree, out)
+	},
+}
+
+var _ Driver = (*INI)(nil)
+
+func (i *INI) Name() string {
+	return i.name
+}
+
+func (i *INI) Marshal(in any) ([]byte, error) {
+	return i.marshaler(in)
+}
+
+func (i *INI) Unmarshal(in []byte, out any) error {
+	return i.unmarshaler(in, out)
+}
+
+func parseINI(in []byte) (map[string]any, error) {
+	tree := make(map[string]any)
+	var section []string
+	scanner := bufio.NewScanner(bytes.NewReader(in))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			name, ok := strings.CutSuffix(line, "]")
+			if !ok {
+				return nil, fmt.Errorf("ini: line %d: unterminated section %q", n, line)
+			}
+			section = nil
+			if name = strings.TrimSpace(name[1:]); name != "" {
+				section = strings.Split(name, ".")
+			}
+			continue
+		}
+
+		i := strings.IndexAny(line, "=:")
+		if i < 0 {
+			return nil, fmt.Errorf("ini: line %d: expected key = value, got %q", n, line)
+		}
+		key, value := strings.TrimSpace(line[:i]), iniValue(strings.TrimSpace(line[i+1:]))
+		key, list := strings.CutSuffix(key, "[]")
+		path := append(append([]string(nil), section...), key)
+		if list {
+			parent, _ := lookupPath(tree, path)
+			items, _ := parent.([]any)
+			if err := setPath(tree, path, append(items, value)); err != nil {
+				return nil, fmt.Errorf("ini: line %d: %w", n, err)
+			}
+			continue
+		}
+		if err := setPath(tree, path, value); err != nil {
+			return nil, fmt.Errorf("ini: line %d: %w", n, err)
+		}
+	}
+	return tree, scanner.Err()
+}
+
+// iniValue unquotes a quoted value, or strips the inline comment of an
+// unquoted one.
+func iniValue(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		if s[0] == '"' {
+			if unquoted, err := strconv.Unquote(s); err == nil {
+				return unquoted
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+	for _, sep := range []string{" ;", " #"} {
+		if i := strings.Index(s, sep); i >= 0 {
+			s = strings.TrimSpace(s[:i])
+		}
+	}
+	return s
+}
+
+func lookupPath(tree map[string]any, path []string) (any, bool) {
+	var v any = tree
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func marshalINI(tree map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	var write func(section []string, m map[string]any) error
+	write = func(section []string, m map[string]any) error {
+		var children []string
+		wroteHeader := len(section) == 0
+		for _, k := range sortedKeys(m) {
+			if _, ok := m[k].(map[string]any); ok {
+				children = append(children, k)
+				continue
+			}
+			if !wroteHeader {
+				if buf.Len() > 0 {
+					buf.WriteByte('\n')
+				}
+				fmt.Fprintf(&buf, "[%s]\n", strings.Join(section, "."))
+				wroteHeader = true
+			}
+			if items, ok := m[k].([]any); ok {
+				for _, item := range items {
+					s, err := scalarString(item)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintf(&buf, "%s[] = %s\n", k, iniQuote(s))
+				}
+				continue
+			}
+			s, err := scalarString(m[k])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&buf, "%s = %s\n", k, iniQuote(s))
+		}
+		for _, k := range children {
+			if err := write(append(append([]string(nil), section...), k), m[k].(map[string]any)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := write(nil, tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func iniQuote(s string) string {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s, ";#\"'\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,175 @@
+package zconf
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/uaxe/infra/crypto"
+)
+
+var ErrNoMasterCipher = errors.New("zconf: no master cipher to decrypt ${enc:...} values")
+
+// placeholder matches ${ENV:NAME}, ${ENV:NAME:-default}, ${file:/path} and
+// ${enc:base64}, a leading $ escaping it.
+var placeholder = regexp.MustCompile(`\$?\$\{(ENV|env|file|enc):([^}]*)\}`)
+
+var masterCipher struct {
+	lock   sync.RWMutex
+	cipher crypto.MasterCipher
+}
+
+// SetMasterCipher sets the cipher decrypting the ${enc:...} values, usually
+// a crypto.MasterRsaCipher holding the private key.
+func SetMasterCipher(c crypto.MasterCipher) {
+	masterCipher.lock.Lock()
+	defer masterCipher.lock.Unlock()
+	masterCipher.cipher = c
+}
+
+// EncryptValue encrypts plain with c and returns the placeholder to write
+// in a config file.
+func EncryptValue(c crypto.MasterCipher, plain string) (string, error) {
+	raw, err := c.Encrypt([]byte(plain))
+	if err != nil {
+		return "", err
+	}
+	return "${enc:" + base64.StdEncoding.EncodeToString(raw) + "}", nil
+}
+
+// Interpolate resolves the placeholders of every string of the struct
+// pointed to by val:
+//
+//	${ENV:NAME}          the environment variable NAME, which must be set
+//	${ENV:NAME:-default} the environment variable NAME, or default
+//	${file:/path}        the content of the file, without trailing newline
+//	${enc:base64}        the value decrypted by the master cipher
+//
+// $${...} is left as the literal ${...}.
+func Interpolate(val any) error {
+	return interpolate(reflect.ValueOf(val), "")
+}
+
+func interpolate(v reflect.Value, key string) error {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Interface && v.Elem().Kind() == reflect.String {
+			s, err := expand(v.Elem().String(), key)
+			if err == nil && v.CanSet() {
+				v.Set(reflect.ValueOf(s))
+			}
+			return err
+		}
+		return interpolate(v.Elem(), key)
+	case reflect.String:
+		if !v.CanSet() {
+			return nil
+		}
+		s, err := expand(v.String(), key)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			if err := interpolate(v.Field(i), join(key, fieldName(t.Field(i)))); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := interpolate(v.Index(i), fmt.Sprintf("%s[%d]", key, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(k))
+			if err := interpolate(elem, join(key, fmt.Sprint(k.Interface()))); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, elem)
+		}
+	}
+	return nil
+}
+
+func expand(s, key string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var err error
+	s = placeholder.ReplaceAllStringFunc(s, func(match string) string {
+		if err != nil {
+			return match
+		}
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		sub := placeholder.FindStringSubmatch(match)
+		var resolved string
+		resolved, err = resolve(strings.ToLower(sub[1]), sub[2])
+		if err != nil {
+			err = fmt.Errorf("zconf: %s: %w", key, err)
+		}
+		return resolved
+	})
+	return s, err
+}
+
+func resolve(kind, arg string) (string, error) {
+	switch kind {
+	case "env":
+		name, def, hasDefault := strings.Cut(arg, ":-")
+		if v, ok := os.LookupEnv(name); ok {
+			return v, nil
+		}
+		if hasDefault {
+			return def, nil
+		}
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	case "file":
+		raw, err := os.ReadFile(arg)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(raw), "\r\n"), nil
+	default:
+		masterCipher.lock.RLock()
+		c := masterCipher.cipher
+		masterCipher.lock.RUnlock()
+		if c == nil {
+			return "", ErrNoMasterCipher
+		}
+		raw, err := base64.StdEncoding.DecodeString(arg)
+		if err != nil {
+			return "", err
+		}
+		plain, err := c.Decrypt(raw)
+		if err != nil {
+			return "", err
+		}
+		return string(plain), nil
+	}
+}
+
+// unmarshal decodes raw into val and resolves its placeholders.
+func unmarshal(dirver Driver, raw []byte, val any) error {
+	if err := dirver.Unmarshal(raw, val); err != nil {
+		return err
+	}
+	return Interpolate(val)
+}
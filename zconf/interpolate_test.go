@@ -0,0 +1,91 @@
+package zconf_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uaxe/infra/crypto"
+	"github.com/uaxe/infra/zconf"
+)
+
+type secrets struct {
+	User     string            `yaml:"user"`
+	Password string            `yaml:"password"`
+	Token    string            `yaml:"token"`
+	Home     string            `yaml:"home"`
+	Literal  string            `yaml:"literal"`
+	Extra    map[string]string `yaml:"extra"`
+}
+
+func TestInterpolate(t *testing.T) {
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := crypto.CreateMasterRsa(nil, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := zconf.EncryptValue(public, "s3cret")
+	if err != nil || !strings.HasPrefix(encrypted, "${enc:") {
+		t.Fatalf("placeholder %q, %v", encrypted, err)
+	}
+
+	tokenPath := filepath.Join(dir, "token")
+	if err = os.WriteFile(tokenPath, []byte("t0ken\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DB_USER", "admin")
+	path := filepath.Join(dir, "config.yaml")
+	content := "user: ${ENV:DB_USER}\n" +
+		"password: \"" + encrypted + "\"\n" +
+		"token: ${file:" + tokenPath + "}\n" +
+		"home: ${ENV:ZCONF_UNSET:-/home/app}\n" +
+		"literal: $${ENV:DB_USER}\n" +
+		"extra:\n  dsn: \"${ENV:DB_USER}:" + encrypted + "@db\"\n"
+	if err = os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg secrets
+	if err = zconf.Load(path, &cfg); !errors.Is(err, zconf.ErrNoMasterCipher) {
+		t.Fatalf("expected ErrNoMasterCipher, got %v", err)
+	}
+
+	priv, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := crypto.CreateMasterRsa(nil, "", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priv})))
+	zconf.SetMasterCipher(c)
+	t.Cleanup(func() { zconf.SetMasterCipher(nil) })
+
+	cfg = secrets{}
+	if err = zconf.Load(path, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.User != "admin" || cfg.Password != "s3cret" || cfg.Token != "t0ken" ||
+		cfg.Home != "/home/app" || cfg.Literal != "${ENV:DB_USER}" || cfg.Extra["dsn"] != "admin:s3cret@db" {
+		t.Fatalf("config %+v", cfg)
+	}
+
+	if err = os.WriteFile(path, []byte("user: ${ENV:ZCONF_UNSET}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = zconf.Load(path, &secrets{}); err == nil || !strings.Contains(err.Error(), "ZCONF_UNSET") {
+		t.Fatalf("expected unset variable error, got %v", err)
+	}
+}
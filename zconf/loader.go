@@ -0,0 +1,223 @@
+package zconf
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+const OriginDefault = "default"
+
+// Origins maps the config keys to the origin of their value: "default",
+// "file:<path>", "env:<NAME>" or "flag:<name>". Keys set by no layer are
+// absent.
+type Origins map[string]string
+
+// FlagBinder registers command line flags, *flag.FlagSet and zcli commands
+// implement it.
+type FlagBinder interface {
+	Var(value flag.Value, name, usage string)
+}
+
+// Loader merges the layers of a config, each one overriding the previous:
+//
+//  1. `default:"..."` struct tags
+//  2. the base file
+//  3. the overlay file of the environment, e.g. config.prod.yaml
+//  4. environment variables, `env:"NAME"` tags or derived from the key
+//  5. command line flags bound by BindFlags
+type Loader struct {
+	path  string
+	opts  Options
+	flags map[string]*flagValue
+}
+
+func NewLoader(path string, opts ...OptionFunc) *Loader {
+	l := &Loader{path: path, flags: make(map[string]*flagValue)}
+	for _, opt := range opts {
+		opt(&l.opts)
+	}
+	return l
+}
+
+// LoadLayered loads path and its layers into val.
+func LoadLayered(path string, val any, opts ...OptionFunc) (Origins, error) {
+	return NewLoader(path, opts...).Load(val)
+}
+
+// BindFlags registers a flag for every key of val, named by its `flag:"..."`
+// tag or after the key, "db-host" for db.host.
+func (l *Loader) BindFlags(binder FlagBinder, val any) error {
+	leaves, err := fields(val)
+	if err != nil {
+		return err
+	}
+	for _, f := range leaves {
+		name := f.tag.Get("flag")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.Join(f.path, "-")
+		}
+		usage := f.tag.Get("description")
+		if usage == "" {
+			usage = "overrides config key " + f.key
+		}
+		fv := &flagValue{name: name, boolean: f.value.Kind() == reflect.Bool}
+		l.flags[f.key] = fv
+		binder.Var(fv, name, usage)
+	}
+	return nil
+}
+
+// OverlayPath returns the path of the environment overlay file, empty
+// without environment.
+func (l *Loader) OverlayPath() string {
+	if l.opts.Env == "" || l.path == "" {
+		return ""
+	}
+	ext := filepath.Ext(l.path)
+	return strings.TrimSuffix(l.path, ext) + "." + l.opts.Env + ext
+}
+
+// EnvName returns the environment variable overriding key.
+func (l *Loader) EnvName(key string) string {
+	name := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+	if l.opts.EnvPrefix != "" {
+		name = strings.ToUpper(l.opts.EnvPrefix) + "_" + name
+	}
+	return name
+}
+
+// Load merges the layers into val, validates it and reports the origin of
+// every key.
+func (l *Loader) Load(val any) (Origins, error) {
+	leaves, err := fields(val)
+	if err != nil {
+		return nil, err
+	}
+	origins := make(Origins, len(leaves))
+
+	for _, f := range leaves {
+		def, ok := f.tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		if err = setValue(f.value, def); err != nil {
+			return nil, fmt.Errorf("zconf: default of %s: %w", f.key, err)
+		}
+		origins[f.key] = OriginDefault
+	}
+
+	if l.path != "" {
+		if err = l.loadFile(l.path, val, leaves, origins); err != nil {
+			return nil, err
+		}
+	}
+	if overlay := l.OverlayPath(); overlay != "" {
+		err = l.loadFile(overlay, val, leaves, origins)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	// resolved once both files are merged, so that escaped placeholders
+	// are not resolved twice
+	if err = Interpolate(val); err != nil {
+		return nil, err
+	}
+
+	for _, f := range leaves {
+		name := f.tag.Get("env")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = l.EnvName(f.key)
+		}
+		env, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err = setValue(f.value, env); err != nil {
+			return nil, fmt.Errorf("zconf: %s from env %s: %w", f.key, name, err)
+		}
+		origins[f.key] = "env:" + name
+	}
+
+	for _, f := range leaves {
+		fv, ok := l.flags[f.key]
+		if !ok || !fv.set {
+			continue
+		}
+		if err = setValue(f.value, fv.value); err != nil {
+			return nil, fmt.Errorf("zconf: %s from flag %s: %w", f.key, fv.name, err)
+		}
+		origins[f.key] = "flag:" + fv.name
+	}
+	return origins, validateOrigins(val, origins)
+}
+
+func (l *Loader) loadFile(path string, val any, leaves []field, origins Origins) error {
+	dirver, raw, err := read(path)
+	if err != nil {
+		return err
+	}
+	if err = dirver.Unmarshal(raw, val); err != nil {
+		return fmt.Errorf("zconf: %s: %w", path, err)
+	}
+
+	var doc map[string]any
+	if err = dirver.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("zconf: %s: %w", path, err)
+	}
+	present := make(map[string]struct{})
+	flatten(present, "", doc)
+	for _, f := range leaves {
+		if hasKey(present, f.key) {
+			origins[f.key] = "file:" + path
+		}
+	}
+	return nil
+}
+
+// hasKey reports whether key, or a key nested in it, is present.
+func hasKey(present map[string]struct{}, key string) bool {
+	for k := range present {
+		if strings.EqualFold(k, key) || (len(k) > len(key) && strings.EqualFold(k[:len(key)+1], key+".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// flagValue records the value of a flag bound to a config key, so that
+// only flags given on the command line override the other layers.
+type flagValue struct {
+	name    string
+	value   string
+	set     bool
+	boolean bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *flagValue) Set(s string) error {
+	f.value, f.set = s, true
+	return nil
+}
+
+// IsBoolFlag lets boolean keys be set by a bare -name.
+func (f *flagValue) IsBoolFlag() bool {
+	return f.boolean
+}
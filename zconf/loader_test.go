@@ -0,0 +1,86 @@
+package zconf_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/zcli"
+	"github.com/uaxe/infra/zconf"
+)
+
+type layered struct {
+	Name string `yaml:"name" default:"app"`
+	DB   struct {
+		Host    string        `yaml:"host" default:"localhost"`
+		Port    int           `yaml:"port" default:"3306"`
+		Timeout time.Duration `yaml:"timeout" default:"1s"`
+		User    string        `yaml:"user" env:"DB_USERNAME"`
+	} `yaml:"db"`
+	Debug bool     `yaml:"debug"`
+	Tags  []string `yaml:"tags"`
+}
+
+func TestLoader(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "config.yaml")
+	files := map[string]string{
+		base:                                   "db:\n  host: db.local\n  user: root\ntags: [a, b]\n",
+		filepath.Join(dir, "config.prod.yaml"): "db:\n  host: db.prod\n  port: 3307\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("APP_DB_PORT", "3308")
+	t.Setenv("DB_USERNAME", "admin")
+
+	var cfg layered
+	loader := zconf.NewLoader(base, zconf.UseEnv("prod"), zconf.UseEnvPrefix("app"))
+	cli := zcli.NewCli("app", "test", "0")
+	if err := loader.BindFlags(cli, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	var origins zconf.Origins
+	cli.Action(func() (err error) {
+		origins, err = loader.Load(&cfg)
+		return err
+	})
+	if err := cli.Run("-db-timeout", "5s", "-debug"); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Name != "app" || cfg.DB.Host != "db.prod" || cfg.DB.Port != 3308 ||
+		cfg.DB.Timeout != 5*time.Second || cfg.DB.User != "admin" || !cfg.Debug || len(cfg.Tags) != 2 {
+		t.Fatalf("config %+v", cfg)
+	}
+	expected := zconf.Origins{
+		"name":       zconf.OriginDefault,
+		"db.host":    "file:" + filepath.Join(dir, "config.prod.yaml"),
+		"db.port":    "env:APP_DB_PORT",
+		"db.timeout": "flag:db-timeout",
+		"db.user":    "env:DB_USERNAME",
+		"debug":      "flag:debug",
+		"tags":       "file:" + base,
+	}
+	for key, origin := range expected {
+		if origins[key] != origin {
+			t.Errorf("origin of %s: %q, expected %q", key, origins[key], origin)
+		}
+	}
+	if len(origins) != len(expected) {
+		t.Errorf("origins %v", origins)
+	}
+
+	// without overlay nor environment
+	cfg = layered{}
+	origins, err := zconf.LoadLayered(base, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DB.Host != "db.local" || cfg.DB.Port != 3306 || origins["db.port"] != zconf.OriginDefault {
+		t.Fatalf("config %+v %v", cfg, origins)
+	}
+}
@@ -0,0 +1,81 @@
+//go:build linux
+
+package zconf
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyNotifier watches the directory of the file rather than the file
+// itself, so that editors replacing the file and kubernetes swapping the
+// symlinks of a mounted config map are noticed.
+type inotifyNotifier struct {
+	file   *os.File
+	name   string
+	events chan struct{}
+}
+
+func newFileNotifier(path string) (notifier, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	dir, name := filepath.Split(filepath.Clean(path))
+	if dir == "" {
+		dir = "."
+	}
+	mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_CREATE |
+		syscall.IN_MOVED_TO | syscall.IN_DELETE)
+	if _, err = syscall.InotifyAddWatch(fd, dir, mask); err != nil {
+		_ = syscall.Close(fd)
+		return nil, err
+	}
+
+	n := &inotifyNotifier{
+		// a non blocking descriptor is handled by the runtime poller, so
+		// that Close interrupts a pending Read
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		name:   name,
+		events: make(chan struct{}, 1),
+	}
+	go n.read()
+	return n, nil
+}
+
+func (n *inotifyNotifier) Events() <-chan struct{} {
+	return n.events
+}
+
+func (n *inotifyNotifier) Close() error {
+	return n.file.Close()
+}
+
+func (n *inotifyNotifier) read() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		size, err := n.file.Read(buf)
+		if err != nil {
+			return
+		}
+		changed := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= size; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			start := offset + syscall.SizeofInotifyEvent
+			offset = start + int(event.Len)
+			name := string(bytes.TrimRight(buf[start:offset], "\x00"))
+			if name == n.name || name == "..data" {
+				changed = true
+			}
+		}
+		if changed {
+			select {
+			case n.events <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
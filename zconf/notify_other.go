@@ -0,0 +1,9 @@
+//go:build !linux
+
+package zconf
+
+import "errors"
+
+func newFileNotifier(path string) (notifier, error) {
+	return nil, errors.New("file notifications not supported")
+}
@@ -2,14 +2,25 @@ package zconf
 
 type (
 	Options struct {
-		Env string
+		Env       string
+		EnvPrefix string
 	}
 
 	OptionFunc func(*Options)
 )
 
+// UseEnv selects the environment whose overlay file, e.g. config.prod.yaml
+// for env "prod", is merged over the base file.
 func UseEnv(env string) OptionFunc {
 	return func(opts *Options) {
 		opts.Env = env
 	}
 }
+
+// UseEnvPrefix sets the prefix of the environment variables overriding
+// config keys, APP_DB_HOST for key db.host with prefix "APP".
+func UseEnvPrefix(prefix string) OptionFunc {
+	return func(opts *Options) {
+		opts.EnvPrefix = prefix
+	}
+}
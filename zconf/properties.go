@@ -0,0 +1,173 @@
+package zconf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Properties reads Java .properties files, dots separating the nested keys.
+type Properties struct {
+	name        string
+	marshaler   func(any) ([]byte, error)
+	unmarshaler func([]byte, any) error
+}
+
+var PropertiesDirver = Properties{
+	name: PropertiesName,
+	marshaler: func(in any) ([]byte, error) {
+		tree, err := encodeTree(in)
+		if err != nil {
+			return nil, err
+		}
+		return marshalProperties(tree)
+	},
+	unmarshaler: func(in []byte, out any) error {
+		tree, err := parseProperties(in)
+		if err != nil {
+			return err
+		}
+		return decodeTree(tree, out)
+	},
+}
+
+var _ Driver = (*Properties)(nil)
+
+func (p *Properties) Name() string {
+	return p.name
+}
+
+func (p *Properties) Marshal(in any) ([]byte, error) {
+	return p.marshaler(in)
+}
+
+func (p *Properties) Unmarshal(in []byte, out any) error {
+	return p.unmarshaler(in, out)
+}
+
+func parseProperties(in []byte) (map[string]any, error) {
+	tree := make(map[string]any)
+	scanner := bufio.NewScanner(bytes.NewReader(in))
+	var logical string
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+		if logical == "" && (line == "" || line[0] == '#' || line[0] == '!') {
+			continue
+		}
+		// an odd number of trailing backslashes continues the line
+		if trailing := len(line) - len(strings.TrimRight(line, `\`)); trailing%2 == 1 {
+			logical += line[:len(line)-1]
+			continue
+		}
+		logical += line
+
+		key, value := splitProperty(logical)
+		logical = ""
+		if key == "" {
+			continue
+		}
+		if err := setPath(tree, strings.Split(key, "."), value); err != nil {
+			return nil, fmt.Errorf("properties: line %d: %w", n, err)
+		}
+	}
+	return tree, scanner.Err()
+}
+
+// splitProperty splits a logical line at the first unescaped '=', ':' or
+// whitespace and unescapes both parts.
+func splitProperty(line string) (string, string) {
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.IndexByte("=: \t\f", line[i]) >= 0 {
+			end = i
+			break
+		}
+	}
+	key, rest := line[:end], strings.TrimLeft(line[end:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	return unescapeProperty(key), unescapeProperty(rest)
+}
+
+func unescapeProperty(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+4 < len(s) {
+				if r, err := strconv.ParseUint(s[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func marshalProperties(tree map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, leaf := range flatLeaves(tree) {
+		s, err := scalarString(leaf.value)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", escapeProperty(strings.Join(leaf.path, "."), true), escapeProperty(s, false))
+	}
+	return buf.Bytes(), nil
+}
+
+func escapeProperty(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '=' || r == ':' || r == '#' || r == '!':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == ' ' && (key || i == 0):
+			b.WriteString(`\ `)
+		case r < 0x20:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
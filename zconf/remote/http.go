@@ -0,0 +1,170 @@
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/uaxe/infra/zconf"
+	"github.com/uaxe/infra/zhttp"
+)
+
+const consulIndexHeader = "X-Consul-Index"
+
+// HTTPSource reads a config served over HTTP(S), such as a file of a config
+// service or a Consul compatible KV entry read with ?raw.
+type HTTPSource struct {
+	url  string
+	opts options
+
+	lock  sync.Mutex
+	etag  string
+	index string
+	ctype string
+	last  []byte
+	sum   [sha256.Size]byte
+}
+
+func NewHTTPSource(rawURL string, opts ...Option) *HTTPSource {
+	s := &HTTPSource{url: rawURL, opts: newOptions(opts)}
+	if s.opts.request == nil {
+		s.opts.request = zhttp.NewRequest()
+	}
+	return s
+}
+
+func (s *HTTPSource) Name() string {
+	return s.url
+}
+
+func (s *HTTPSource) Read(ctx context.Context) (zconf.Driver, []byte, error) {
+	if _, err := s.fetch(ctx, false); err != nil {
+		return nil, nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	dirver, err := s.driver()
+	if err != nil {
+		return nil, nil, err
+	}
+	return dirver, append([]byte(nil), s.last...), nil
+}
+
+// Wait holds a blocking request when long polling is enabled and the
+// server supports it, or checks the content every interval.
+func (s *HTTPSource) Wait(ctx context.Context) error {
+	for {
+		s.lock.Lock()
+		blocking := s.opts.longPoll > 0 && (s.index != "" || s.etag != "")
+		s.lock.Unlock()
+
+		start := time.Now()
+		if !blocking {
+			if err := sleep(ctx, s.opts.Interval); err != nil {
+				return err
+			}
+		}
+		changed, err := s.fetch(ctx, blocking)
+		if err != nil || changed {
+			return err
+		}
+		// a server answering at once does not hold requests
+		if elapsed := time.Since(start); blocking && elapsed < s.opts.Interval {
+			if err = sleep(ctx, s.opts.Interval-elapsed); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// fetch requests the content, conditionally on the last ETag or Consul
+// index, and reports whether it changed.
+func (s *HTTPSource) fetch(ctx context.Context, blocking bool) (bool, error) {
+	s.lock.Lock()
+	etag, index := s.etag, s.index
+	s.lock.Unlock()
+
+	u, err := url.Parse(s.url)
+	if err != nil {
+		return false, err
+	}
+	if blocking && index != "" {
+		q := u.Query()
+		q.Set("index", index)
+		q.Set("wait", strconv.Itoa(int(s.opts.longPoll/time.Second))+"s")
+		u.RawQuery = q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return false, err
+	}
+	for k, vs := range s.opts.header {
+		req.Header[k] = append([]string(nil), vs...)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+		if blocking {
+			req.Header.Set("Prefer", "wait="+strconv.Itoa(int(s.opts.longPoll/time.Second)))
+		}
+	}
+
+	resp, err := s.opts.request.ContextDo(ctx, req)
+	if err != nil {
+		return false, fmt.Errorf("zconf: GET %s: %w", s.url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotModified {
+		return false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false, fmt.Errorf("zconf: GET %s: %s", s.url, resp.Status)
+	}
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("zconf: GET %s: %w", s.url, err)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.etag = resp.Header.Get("ETag")
+	s.index = resp.Header.Get(consulIndexHeader)
+	s.ctype = resp.Header.Get("Content-Type")
+	sum := sha256.Sum256(raw)
+	changed := sum != s.sum || s.last == nil
+	s.last, s.sum = raw, sum
+	return changed, nil
+}
+
+// driver returns the driver set by option, or guessed from the extension
+// of the URL path or the Content-Type of the response.
+func (s *HTTPSource) driver() (zconf.Driver, error) {
+	if s.opts.Driver != nil {
+		return s.opts.Driver, nil
+	}
+	if u, err := url.Parse(s.url); err == nil {
+		if ext := path.Ext(u.Path); ext != "" {
+			if d, ok := zconf.LookupDriver(ext); ok {
+				return d, nil
+			}
+		}
+	}
+	if mediaType, _, err := mime.ParseMediaType(s.ctype); err == nil {
+		switch mediaType {
+		case "application/json":
+			return &zconf.JSONDirver, nil
+		case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+			return &zconf.YAMLDirver, nil
+		case "application/toml":
+			return &zconf.TOMLDirver, nil
+		}
+	}
+	return nil, fmt.Errorf("zconf: %s: unknown format, use WithSourceDriver", s.url)
+}
@@ -0,0 +1,142 @@
+package remote
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/uaxe/infra/zconf"
+)
+
+// RedisSource reads a config from a Redis string key, in the format of its
+// extension or WithSourceDriver, or from a hash whose fields are the dotted
+// config keys:
+//
+//	HSET app:config db.host 10.0.0.1 db.port 5432
+type RedisSource struct {
+	client redis.UniversalClient
+	key    string
+	opts   options
+	owned  bool
+
+	lock sync.Mutex
+	sum  [sha256.Size]byte
+	sub  *redis.PubSub
+}
+
+func NewRedisSource(client redis.UniversalClient, key string, opts ...Option) *RedisSource {
+	return &RedisSource{client: client, key: key, opts: newOptions(opts)}
+}
+
+func (s *RedisSource) Name() string {
+	return "redis:" + s.key
+}
+
+func (s *RedisSource) Read(ctx context.Context) (zconf.Driver, []byte, error) {
+	dirver, raw, err := s.get(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	s.lock.Lock()
+	s.sum = sha256.Sum256(raw)
+	s.lock.Unlock()
+	return dirver, raw, nil
+}
+
+// Wait checks the key every interval, or when a message is published on
+// the channel of WithRedisChannel.
+func (s *RedisSource) Wait(ctx context.Context) error {
+	var messages <-chan *redis.Message
+	if s.opts.channel != "" {
+		s.lock.Lock()
+		if s.sub == nil {
+			s.sub = s.client.Subscribe(context.Background(), s.opts.channel)
+		}
+		messages = s.sub.Channel()
+		s.lock.Unlock()
+	}
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-messages:
+		}
+		_, raw, err := s.get(ctx)
+		if err != nil {
+			return err
+		}
+		s.lock.Lock()
+		changed := sha256.Sum256(raw) != s.sum
+		s.lock.Unlock()
+		if changed {
+			return nil
+		}
+	}
+}
+
+// Close ends the subscription to the notification channel, and closes the
+// client of a source opened from a URL.
+func (s *RedisSource) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var err error
+	if s.sub != nil {
+		err = s.sub.Close()
+		s.sub = nil
+	}
+	if s.owned {
+		if cerr := s.client.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (s *RedisSource) get(ctx context.Context) (zconf.Driver, []byte, error) {
+	typ, err := s.client.Type(ctx, s.key).Result()
+	if err != nil {
+		return nil, nil, fmt.Errorf("zconf: %s: %w", s.Name(), err)
+	}
+	switch typ {
+	case "string":
+		dirver := s.opts.Driver
+		if dirver == nil {
+			var ok bool
+			if dirver, ok = zconf.LookupDriver(filepath.Ext(s.key)); !ok || filepath.Ext(s.key) == "" {
+				return nil, nil, fmt.Errorf("zconf: %s: unknown format, use WithSourceDriver", s.Name())
+			}
+		}
+		raw, err := s.client.Get(ctx, s.key).Bytes()
+		if err != nil {
+			return nil, nil, fmt.Errorf("zconf: %s: %w", s.Name(), err)
+		}
+		return dirver, raw, nil
+	case "hash":
+		values, err := s.client.HGetAll(ctx, s.key).Result()
+		if err != nil {
+			return nil, nil, fmt.Errorf("zconf: %s: %w", s.Name(), err)
+		}
+		// the dotted fields are the keys of a properties file
+		flat := make(map[string]any, len(values))
+		for k, v := range values {
+			flat[k] = v
+		}
+		raw, err := zconf.PropertiesDirver.Marshal(flat)
+		if err != nil {
+			return nil, nil, fmt.Errorf("zconf: %s: %w", s.Name(), err)
+		}
+		return &zconf.PropertiesDirver, raw, nil
+	case "none":
+		return nil, nil, fmt.Errorf("zconf: %s: %w", s.Name(), redis.Nil)
+	}
+	return nil, nil, fmt.Errorf("zconf: %s: unsupported type %s", s.Name(), typ)
+}
@@ -0,0 +1,141 @@
+// Package remote provides the zconf sources of configs served over HTTP or
+// held in Redis. Importing it registers the http, https, redis and rediss
+// schemes of zconf.OpenSource:
+//
+//	import _ "github.com/uaxe/infra/zconf/remote"
+//
+//	src, err := zconf.OpenSource("https://consul:8500/v1/kv/app/config.yaml?raw")
+//	src, err := zconf.OpenSource("redis://localhost:6379/0?key=app:config")
+//
+// Open opens the same URLs with the options of this package as well.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/uaxe/infra/zconf"
+	"github.com/uaxe/infra/zhttp"
+)
+
+func init() {
+	for _, scheme := range []string{"http", "https", "redis", "rediss"} {
+		zconf.RegisterSource(scheme, func(rawURL string, opts ...zconf.SourceOption) (zconf.Source, error) {
+			return Open(rawURL, WithSourceOptions(opts...))
+		})
+	}
+}
+
+// Open opens the source of an http, https, redis or rediss URL.
+func Open(rawURL string, opts ...Option) (zconf.Source, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return NewHTTPSource(rawURL, opts...), nil
+	case "redis", "rediss":
+		return openRedis(u, opts...)
+	}
+	return nil, fmt.Errorf("zconf: unsupported remote source %s", u.Redacted())
+}
+
+// openRedis opens the key of the query of u, the rest of the URL
+// configuring the client as redis.ParseURL does.
+func openRedis(u *url.URL, opts ...Option) (zconf.Source, error) {
+	query := u.Query()
+	key := query.Get("key")
+	if key == "" {
+		return nil, errors.New("zconf: redis source URL without key parameter")
+	}
+	query.Del("key")
+	u.RawQuery = query.Encode()
+	redisOpts, err := redis.ParseURL(u.String())
+	if err != nil {
+		return nil, err
+	}
+	s := NewRedisSource(redis.NewClient(redisOpts), key, opts...)
+	s.owned = true
+	return s, nil
+}
+
+// options are the settings of the remote sources.
+type options struct {
+	zconf.SourceOptions
+	longPoll time.Duration
+	header   http.Header
+	request  zhttp.Request
+	channel  string
+}
+
+type Option func(o *options)
+
+func newOptions(opts []Option) options {
+	o := options{SourceOptions: zconf.NewSourceOptions(), header: make(http.Header)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithSourceOptions applies the options of every zconf source, such as
+// zconf.WithSourceInterval.
+func WithSourceOptions(opts ...zconf.SourceOption) Option {
+	return func(o *options) {
+		for _, opt := range opts {
+			opt(&o.SourceOptions)
+		}
+	}
+}
+
+// WithLongPoll makes HTTP sources hold their requests up to wait: the
+// server answers when the content changes, as Consul blocking queries on
+// X-Consul-Index or conditional requests on ETag do.
+func WithLongPoll(wait time.Duration) Option {
+	return func(o *options) {
+		o.longPoll = wait
+	}
+}
+
+// WithSourceHeader adds a header to the requests of HTTP sources, e.g. an
+// X-Consul-Token.
+func WithSourceHeader(key, value string) Option {
+	return func(o *options) {
+		o.header.Add(key, value)
+	}
+}
+
+// WithSourceRequest sets the client of HTTP sources, zhttp.NewRequest() by
+// default.
+func WithSourceRequest(r zhttp.Request) Option {
+	return func(o *options) {
+		o.request = r
+	}
+}
+
+// WithRedisChannel makes Redis sources wait for a message on channel,
+// published by whoever updates the key, rather than only polling.
+func WithRedisChannel(channel string) Option {
+	return func(o *options) {
+		o.channel = channel
+	}
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
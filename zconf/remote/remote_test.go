@@ -0,0 +1,223 @@
+package remote_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/uaxe/infra/zconf"
+	"github.com/uaxe/infra/zconf/remote"
+)
+
+type watched struct {
+	Name string `yaml:"name"`
+	Port int    `yaml:"port"`
+}
+
+// kvServer serves a value as a Consul KV entry with ?raw, holding blocking
+// queries until the index changes.
+type kvServer struct {
+	lock    sync.Mutex
+	index   int
+	value   string
+	changed chan struct{}
+}
+
+func newKVServer(value string) *kvServer {
+	return &kvServer{index: 1, value: value, changed: make(chan struct{})}
+}
+
+func (s *kvServer) set(value string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.index++
+	s.value = value
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *kvServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	index, changed := s.index, s.changed
+	s.lock.Unlock()
+	if r.URL.Query().Get("index") == strconv.Itoa(index) {
+		wait, _ := time.ParseDuration(r.URL.Query().Get("wait"))
+		select {
+		case <-changed:
+		case <-time.After(wait):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.Itoa(s.index))
+	_, _ = w.Write([]byte(s.value))
+}
+
+func TestHTTPSource_LongPoll(t *testing.T) {
+	kv := newKVServer("name: a\nport: 80\n")
+	srv := httptest.NewServer(kv)
+	defer srv.Close()
+
+	src := remote.NewHTTPSource(srv.URL+"/v1/kv/app/config.yaml?raw",
+		remote.WithLongPoll(5*time.Second), remote.WithSourceOptions(zconf.WithSourceInterval(10*time.Millisecond)))
+	changes := make(chan *watched, 1)
+	var cfg watched
+	w, err := zconf.WatchSource(context.Background(), src, &cfg, func(_, new *watched) {
+		changes <- new
+	}, zconf.WithDebounce(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+	if cfg.Name != "a" {
+		t.Fatalf("initial config %+v", cfg)
+	}
+
+	kv.set("name: b\nport: 81\n")
+	select {
+	case c := <-changes:
+		if c.Name != "b" || c.Port != 81 {
+			t.Fatalf("new config %+v", c)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("change not noticed")
+	}
+}
+
+func TestCachedSource(t *testing.T) {
+	srv := httptest.NewServer(newKVServer(`{"name": "a", "port": 80}`))
+	src := remote.NewHTTPSource(srv.URL + "/config.json")
+	cache := filepath.Join(t.TempDir(), "config.cache.yaml")
+
+	var fallbacks []error
+	cached := zconf.NewCachedSource(src, cache, func(err error) { fallbacks = append(fallbacks, err) })
+	var cfg watched
+	if err := zconf.LoadSource(context.Background(), cached, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.Close()
+	cfg = watched{}
+	if err := zconf.LoadSource(context.Background(), cached, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "a" || cfg.Port != 80 || len(fallbacks) != 1 {
+		t.Fatalf("config %+v, fallbacks %v", cfg, fallbacks)
+	}
+	if err := zconf.LoadSource(context.Background(), src, &cfg); err == nil {
+		t.Fatal("expected an error without cache")
+	}
+
+	srv = httptest.NewServer(newKVServer(`{"name": "a", "port": 80}`))
+	defer srv.Close()
+	fallbacks = nil
+	var cacheErrs []error
+	cached = zconf.NewCachedSource(remote.NewHTTPSource(srv.URL+"/config.json"), cache+".unknown",
+		func(err error) { fallbacks = append(fallbacks, err) },
+		zconf.WithCacheError(func(err error) { cacheErrs = append(cacheErrs, err) }))
+	if err := zconf.LoadSource(context.Background(), cached, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(fallbacks) != 0 || len(cacheErrs) != 1 {
+		t.Fatalf("a cache error is not a fallback: fallbacks %v, cache errors %v", fallbacks, cacheErrs)
+	}
+}
+
+func TestRedisSource(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer func() { _ = client.Close() }()
+	ctx := context.Background()
+
+	mr.HSet("app:config", "name", "a", "port", "80")
+	src := remote.NewRedisSource(client, "app:config",
+		remote.WithRedisChannel("app:config:changed"), remote.WithSourceOptions(zconf.WithSourceInterval(time.Hour)))
+	changes := make(chan *watched, 1)
+	var cfg watched
+	w, err := zconf.WatchSource(ctx, src, &cfg, func(_, new *watched) {
+		changes <- new
+	}, zconf.WithDebounce(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+	if cfg.Name != "a" || cfg.Port != 80 {
+		t.Fatalf("initial config %+v", cfg)
+	}
+
+	mr.HSet("app:config", "port", "81")
+	deadline := time.After(2 * time.Second)
+	for {
+		// the subscription may not be ready for the first message
+		mr.Publish("app:config:changed", "port")
+		select {
+		case c := <-changes:
+			if c.Port != 81 {
+				t.Fatalf("new config %+v", c)
+			}
+		case <-time.After(20 * time.Millisecond):
+			continue
+		case <-deadline:
+			t.Fatal("change not noticed")
+		}
+		break
+	}
+
+	mr.Set("app:config.json", `{"name": "json", "port": 82}`)
+	if err = zconf.LoadSource(ctx, remote.NewRedisSource(client, "app:config.json"), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "json" || cfg.Port != 82 {
+		t.Fatalf("config %+v", cfg)
+	}
+}
+
+func TestOpenSource(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.HSet("app:config", "name", "a", "port", "80")
+	src, err := zconf.OpenSource("redis://" + mr.Addr() + "/0?key=app:config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg watched
+	if err = zconf.LoadSource(context.Background(), src, &cfg); err != nil || cfg.Port != 80 {
+		t.Fatalf("config %+v, %v", cfg, err)
+	}
+	_ = src.(io.Closer).Close()
+
+	if src, err = zconf.OpenSource("https://consul:8500/v1/kv/app/config.yaml?raw"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := src.(*remote.HTTPSource); !ok {
+		t.Fatalf("source %T", src)
+	}
+	if src, err = zconf.OpenSource("testdata/config.yml"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := src.(*zconf.FileSource); !ok {
+		t.Fatalf("source %T", src)
+	}
+	if _, err = zconf.OpenSource("s3://bucket/config.yaml"); err == nil {
+		t.Fatal("expected an error for an unregistered scheme")
+	}
+	if src, err = remote.Open("https://consul:8500/v1/kv/app/config.yaml?raw", remote.WithLongPoll(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := src.(*remote.HTTPSource); !ok {
+		t.Fatalf("source %T", src)
+	}
+	if _, err = remote.Open("file:///etc/app.yaml"); err == nil {
+		t.Fatal("expected an error for a local file")
+	}
+}
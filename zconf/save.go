@@ -0,0 +1,87 @@
+package zconf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Save writes val to path with the driver of its extension. The file is
+// replaced atomically, keeping the mode of an existing one.
+func Save(path string, val any) error {
+	ext := filepath.Ext(path)
+	dirver, ok := LookupDriver(ext)
+	if !ok || ext == "" {
+		return fmt.Errorf("%s dirver not support", ext)
+	}
+	raw, err := dirver.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, raw)
+}
+
+// Convert rewrites the config file src in the format of dst, e.g. a yaml
+// file to HCL. Placeholders are copied as they are.
+func Convert(src, dst string) error {
+	from, raw, err := read(src)
+	if err != nil {
+		return err
+	}
+	ext := filepath.Ext(dst)
+	to, ok := LookupDriver(ext)
+	if !ok || ext == "" {
+		return fmt.Errorf("%s dirver not support", ext)
+	}
+	out, err := convert(from, to, raw)
+	if err != nil {
+		return fmt.Errorf("zconf: convert %s: %w", src, err)
+	}
+	return writeFile(dst, out)
+}
+
+// ConvertBytes converts raw between two formats, named by extension or
+// driver name.
+func ConvertBytes(raw []byte, from, to string) ([]byte, error) {
+	src, ok := LookupDriver(from)
+	if !ok {
+		return nil, fmt.Errorf("%s dirver not support", from)
+	}
+	dst, ok := LookupDriver(to)
+	if !ok {
+		return nil, fmt.Errorf("%s dirver not support", to)
+	}
+	return convert(src, dst, raw)
+}
+
+func convert(from, to Driver, raw []byte) ([]byte, error) {
+	doc := make(map[string]any)
+	if err := from.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return to.Marshal(doc)
+}
+
+func writeFile(path string, raw []byte) error {
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err = tmp.Write(raw); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
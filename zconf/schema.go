@@ -0,0 +1,157 @@
+package zconf
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema describes the config struct pointed to by val as a JSON
+// Schema, from its field names and their `validate`, `default` and
+// `description` tags, so that editors can complete and check config files.
+func JSONSchema(val any) ([]byte, error) {
+	t := reflect.TypeOf(val)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("zconf: %T is not a struct", val)
+	}
+	schema := schemaOf(t)
+	schema["$schema"] = jsonSchemaDraft
+	if t.Name() != "" {
+		schema["title"] = t.Name()
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func schemaOf(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == durationType:
+		return map[string]any{"type": "string", "pattern": `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`}
+	case isLeaf(t) && t.Kind() == reflect.Struct:
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		var required []string
+		addProperties(t, properties, &required)
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]any{}
+}
+
+func addProperties(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := fieldName(sf)
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && !isLeaf(sf.Type) {
+			addProperties(sf.Type, properties, required)
+			continue
+		}
+
+		prop := schemaOf(sf.Type)
+		if desc := sf.Tag.Get("description"); desc != "" {
+			prop["description"] = desc
+		}
+		if def, ok := sf.Tag.Lookup("default"); ok {
+			prop["default"] = schemaValue(prop, def)
+		}
+		for _, r := range parseRules(sf.Tag.Get("validate")) {
+			if r.name == "required" {
+				*required = append(*required, name)
+				continue
+			}
+			applyRule(prop, r)
+		}
+		properties[name] = prop
+	}
+}
+
+// applyRule adds the JSON Schema keywords matching a validation rule.
+func applyRule(prop map[string]any, r rule) {
+	typ, _ := prop["type"].(string)
+	switch r.name {
+	case "min", "max":
+		n, err := strconv.ParseFloat(r.param, 64)
+		if err != nil {
+			return
+		}
+		switch typ {
+		case "string":
+			prop[r.name+"Length"] = int(n)
+		case "array":
+			prop[r.name+"Items"] = int(n)
+		case "object":
+			prop[r.name+"Properties"] = int(n)
+		case "integer", "number":
+			prop[map[string]string{"min": "minimum", "max": "maximum"}[r.name]] = n
+		}
+	case "oneof":
+		options := strings.Fields(r.param)
+		enum := make([]any, len(options))
+		for i, option := range options {
+			enum[i] = schemaValue(prop, option)
+		}
+		prop["enum"] = enum
+	case "regex":
+		prop["pattern"] = r.param
+	case "url":
+		prop["format"] = "uri"
+	case "duration":
+		prop["pattern"] = schemaOf(durationType)["pattern"]
+	}
+}
+
+// schemaValue converts a tag value to the JSON type of prop.
+func schemaValue(prop map[string]any, s string) any {
+	switch prop["type"] {
+	case "integer":
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			return n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case "array":
+		return strings.Split(s, ",")
+	}
+	return s
+}
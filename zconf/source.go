@@ -0,0 +1,282 @@
+package zconf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source provides the content of a config: a file, or a remote one such as
+// the HTTP endpoints and Redis keys of the zconf/remote package.
+type Source interface {
+	// Name identifies the source in errors.
+	Name() string
+	// Read returns the current content and the driver decoding it.
+	Read(ctx context.Context) (Driver, []byte, error)
+	// Wait blocks until the content may have changed. It returns an error
+	// when the source cannot be reached or ctx is done.
+	Wait(ctx context.Context) error
+}
+
+// SourceOptions are the settings of every source, those of the sources of
+// other packages embed them.
+type SourceOptions struct {
+	// Driver is the format of the content, guessed by the source when nil.
+	Driver Driver
+	// Interval is how often a source without notifications is checked.
+	Interval time.Duration
+}
+
+type SourceOption func(o *SourceOptions)
+
+// NewSourceOptions applies opts to the default options.
+func NewSourceOptions(opts ...SourceOption) SourceOptions {
+	o := SourceOptions{Interval: 10 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithSourceDriver sets the format of the content, guessed from the
+// extension of the path, the URL or the Redis key otherwise.
+func WithSourceDriver(d Driver) SourceOption {
+	return func(o *SourceOptions) {
+		o.Driver = d
+	}
+}
+
+// WithSourceInterval sets how often a source without notifications is
+// checked for changes, 10s by default.
+func WithSourceInterval(d time.Duration) SourceOption {
+	return func(o *SourceOptions) {
+		o.Interval = d
+	}
+}
+
+// SourceOpener opens the source of a URL, see RegisterSource.
+type SourceOpener func(rawURL string, opts ...SourceOption) (Source, error)
+
+var sourceOpeners = struct {
+	lock    sync.RWMutex
+	schemes map[string]SourceOpener
+}{schemes: make(map[string]SourceOpener)}
+
+// RegisterSource makes OpenSource open the URLs of scheme with open. The
+// packages of remote sources register their schemes when imported:
+//
+//	import _ "github.com/uaxe/infra/zconf/remote"
+func RegisterSource(scheme string, open SourceOpener) {
+	sourceOpeners.lock.Lock()
+	defer sourceOpeners.lock.Unlock()
+	sourceOpeners.schemes[strings.ToLower(scheme)] = open
+}
+
+// OpenSource opens the source of rawURL, a FileSource for a path or a
+// file:// URL, otherwise the source registered for its scheme.
+func OpenSource(rawURL string, opts ...SourceOption) (Source, error) {
+	u, err := url.Parse(rawURL)
+	// a one letter scheme is a Windows drive
+	if err != nil || len(u.Scheme) <= 1 {
+		return NewFileSource(rawURL, opts...), nil
+	}
+	if u.Scheme == "file" {
+		return NewFileSource(u.Path, opts...), nil
+	}
+	sourceOpeners.lock.RLock()
+	open, ok := sourceOpeners.schemes[strings.ToLower(u.Scheme)]
+	sourceOpeners.lock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("zconf: no source registered for %s://", u.Scheme)
+	}
+	return open(rawURL, opts...)
+}
+
+// LoadSource reads src into val, resolves its placeholders and validates it.
+func LoadSource(ctx context.Context, src Source, val any) error {
+	dirver, raw, err := src.Read(ctx)
+	if err != nil {
+		return err
+	}
+	if err = unmarshal(dirver, raw, val); err != nil {
+		return fmt.Errorf("zconf: %s: %w", src.Name(), err)
+	}
+	return validateSource(src, val)
+}
+
+// validateSource validates val, when it is a struct, locating the failing
+// keys when src is a file.
+func validateSource(src Source, val any) error {
+	if !isStruct(val) {
+		return nil
+	}
+	if file, ok := src.(*FileSource); ok {
+		return validateFile(file.path, val)
+	}
+	return Validate(val)
+}
+
+// FileSource reads a local file, notified of its changes by the system or
+// by polling.
+type FileSource struct {
+	path string
+	opts SourceOptions
+
+	lock   sync.Mutex
+	notify notifier
+}
+
+func NewFileSource(path string, opts ...SourceOption) *FileSource {
+	return &FileSource{path: path, opts: NewSourceOptions(opts...)}
+}
+
+func (s *FileSource) Name() string {
+	return s.path
+}
+
+func (s *FileSource) Read(context.Context) (Driver, []byte, error) {
+	dirver, raw, err := read(s.path)
+	if err == nil && s.opts.Driver != nil {
+		dirver = s.opts.Driver
+	}
+	return dirver, raw, err
+}
+
+func (s *FileSource) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case _, ok := <-s.events():
+		if !ok {
+			return fmt.Errorf("zconf: %s: notifications closed", s.path)
+		}
+		return nil
+	}
+}
+
+// events starts the notifications of the file on first use, so that
+// watchers start them before reading the file and miss no change.
+func (s *FileSource) events() <-chan struct{} {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.notify == nil {
+		var err error
+		if s.notify, err = newFileNotifier(s.path); err != nil {
+			s.notify = newPollNotifier(s.path, s.opts.Interval)
+		}
+	}
+	return s.notify.Events()
+}
+
+// Close stops the notifications of the file.
+func (s *FileSource) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.notify == nil {
+		return nil
+	}
+	return s.notify.Close()
+}
+
+// CachedSource keeps a local copy of the content of a remote source and
+// reads it when the source cannot be reached, so that instances start
+// during an outage of the config service.
+type CachedSource struct {
+	src          Source
+	path         string
+	onFallback   func(err error)
+	onCacheError func(err error)
+}
+
+type CachedSourceOption func(s *CachedSource)
+
+// WithCacheError calls fn with the error of writing the cached copy, the
+// content of the source being read all the same.
+func WithCacheError(fn func(err error)) CachedSourceOption {
+	return func(s *CachedSource) {
+		s.onCacheError = fn
+	}
+}
+
+// NewCachedSource caches the content of src in path, written in the format
+// of its extension. onFallback, if not nil, is called with the error of src
+// whenever the cached copy is used instead. The errors of the cache itself
+// are reported by WithCacheError only.
+func NewCachedSource(src Source, path string, onFallback func(err error), opts ...CachedSourceOption) *CachedSource {
+	s := &CachedSource{src: src, path: path, onFallback: onFallback}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *CachedSource) Name() string {
+	return s.src.Name()
+}
+
+func (s *CachedSource) Read(ctx context.Context) (Driver, []byte, error) {
+	dirver, raw, err := s.src.Read(ctx)
+	if err == nil {
+		if cacheErr := s.store(dirver, raw); cacheErr != nil && s.onCacheError != nil {
+			s.onCacheError(fmt.Errorf("zconf: cache %s: %w", s.path, cacheErr))
+		}
+		return dirver, raw, nil
+	}
+
+	cacheDirver, cached, cacheErr := read(s.path)
+	if cacheErr != nil {
+		return nil, nil, err
+	}
+	if s.onFallback != nil {
+		s.onFallback(err)
+	}
+	return cacheDirver, cached, nil
+}
+
+func (s *CachedSource) Wait(ctx context.Context) error {
+	return s.src.Wait(ctx)
+}
+
+// Close closes the wrapped source if it is an io.Closer.
+func (s *CachedSource) Close() error {
+	if c, ok := s.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (s *CachedSource) store(dirver Driver, raw []byte) error {
+	cacheDirver, ok := LookupDriver(filepath.Ext(s.path))
+	if !ok {
+		return fmt.Errorf("%s dirver not support", filepath.Ext(s.path))
+	}
+	if cacheDirver.Name() != dirver.Name() {
+		var err error
+		if raw, err = convert(dirver, cacheDirver, raw); err != nil {
+			return err
+		}
+	}
+	if prev, err := os.ReadFile(s.path); err == nil && bytes.Equal(prev, raw) {
+		return nil
+	}
+	return writeFile(s.path, raw)
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
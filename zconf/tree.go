@@ -0,0 +1,226 @@
+package zconf
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// The drivers of the text formats, INI, dotenv, properties and HCL, parse
+// files into a tree of map[string]any, []any and scalars, then decode it
+// through yaml so that the yaml tags of the config structs apply.
+
+// decodeTree decodes tree into out. Keys are matched to the fields of out
+// ignoring case, '_' and '-', so that DB_MAX_CONNS sets maxConns, string
+// scalars are resolved to the type of their field and comma separated
+// strings fill slices.
+func decodeTree(tree map[string]any, out any) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("zconf: decode into non-pointer %T", out)
+	}
+	return treeNode(tree, rv.Type().Elem()).Decode(out)
+}
+
+func treeNode(v any, t reflect.Type) *yaml.Node {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t != nil && t.Kind() == reflect.Interface {
+		t = nil
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, k := range sortedKeys(v) {
+			key, elem := k, reflect.Type(nil)
+			switch {
+			case t != nil && t.Kind() == reflect.Struct:
+				if sf, ok := lookupField(t, k); ok {
+					key, elem = fieldName(sf), sf.Type
+				}
+			case t != nil && t.Kind() == reflect.Map:
+				elem = t.Elem()
+			}
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+				treeNode(v[k], elem))
+		}
+		return node
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range v {
+			node.Content = append(node.Content, treeNode(item, elemType(t)))
+		}
+		return node
+	case string:
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8 {
+			items := make([]any, 0)
+			if v != "" {
+				for _, item := range strings.Split(v, ",") {
+					items = append(items, strings.TrimSpace(item))
+				}
+			}
+			return treeNode(items, t)
+		}
+		node := &yaml.Node{Kind: yaml.ScalarNode, Value: v}
+		if t != nil && t.Kind() == reflect.String {
+			// keeps "null", "true" or "08" as they are
+			node.Tag = "!!str"
+		}
+		return node
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: formatFloat(v)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: fmt.Sprint(v)}
+	}
+}
+
+func elemType(t reflect.Type) reflect.Type {
+	if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		return t.Elem()
+	}
+	return nil
+}
+
+// lookupField finds the field of t, or of its inlined structs, named key.
+func lookupField(t reflect.Type, key string) (reflect.StructField, bool) {
+	want := normalizeKey(key)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous && strings.Contains(sf.Tag.Get("yaml"), ",inline") {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if inner, ok := lookupField(ft, key); ok {
+				return inner, true
+			}
+			continue
+		}
+		if name := fieldName(sf); name != "-" && normalizeKey(name) == want {
+			return sf, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func normalizeKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+// encodeTree converts val to a tree through yaml, keyed by the yaml tags
+// of its fields.
+func encodeTree(val any) (map[string]any, error) {
+	raw, err := yaml.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+	tree := make(map[string]any)
+	if err = yaml.Unmarshal(raw, &tree); err != nil {
+		return nil, fmt.Errorf("zconf: %T is not a mapping: %w", val, err)
+	}
+	return tree, nil
+}
+
+// setPath sets the value of the dotted path in tree, creating the
+// intermediate maps.
+func setPath(tree map[string]any, path []string, value any) error {
+	for i, key := range path[:len(path)-1] {
+		child, ok := tree[key]
+		if !ok {
+			m := make(map[string]any)
+			tree[key], tree = m, m
+			continue
+		}
+		if tree, ok = child.(map[string]any); !ok {
+			return fmt.Errorf("zconf: %s is both a value and a section", strings.Join(path[:i+1], "."))
+		}
+	}
+	last := path[len(path)-1]
+	if _, ok := tree[last].(map[string]any); ok {
+		return fmt.Errorf("zconf: %s is both a value and a section", strings.Join(path, "."))
+	}
+	tree[last] = value
+	return nil
+}
+
+// flatLeaf is a scalar or a list of scalars of a tree, with its path.
+type flatLeaf struct {
+	path  []string
+	value any
+}
+
+// flatLeaves lists the leaves of tree sorted by path, lists of scalars
+// being leaves.
+func flatLeaves(tree map[string]any) []flatLeaf {
+	var leaves []flatLeaf
+	var walk func(parent []string, m map[string]any)
+	walk = func(parent []string, m map[string]any) {
+		for _, k := range sortedKeys(m) {
+			path := append(append([]string(nil), parent...), k)
+			if child, ok := m[k].(map[string]any); ok {
+				walk(path, child)
+				continue
+			}
+			leaves = append(leaves, flatLeaf{path: path, value: m[k]})
+		}
+	}
+	walk(nil, tree)
+	return leaves
+}
+
+// scalarString formats a scalar, or a list of scalars separated by commas.
+func scalarString(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := scalarString(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		return "", fmt.Errorf("zconf: nested value %v cannot be flattened", v)
+	case float64:
+		return formatFloat(v), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// formatFloat writes integral floats, as numbers decoded from json are,
+// without exponent.
+func formatFloat(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,336 @@
+package zconf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// FieldError is a config key failing a rule of its `validate:"..."` tag.
+// File and Line are set when the key was read from a file whose format
+// allows locating it.
+type FieldError struct {
+	File    string
+	Line    int
+	Key     string
+	Rule    string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d: %s %s", e.File, e.Line, e.Key, e.Message)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s %s", e.File, e.Key, e.Message)
+	}
+	return e.Key + " " + e.Message
+}
+
+// ValidationErrors lists every failing key of a config.
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e ValidationErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, fe := range e {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// rule is one comma separated entry of a `validate:"..."` tag: required,
+// min=N, max=N, oneof=a b c, duration, url or regex=EXPR. As expressions
+// may contain commas, regex must come last and takes the rest of the tag.
+// Rules other than required, min and max accept zero values, combine them
+// with required for mandatory keys.
+type rule struct {
+	name  string
+	param string
+}
+
+func parseRules(tag string) []rule {
+	var rules []rule
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "regex=") {
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			rules = append(rules, rule{name: name, param: param})
+		}
+	}
+	return rules
+}
+
+var regexps sync.Map
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexps.Store(expr, re)
+	return re, nil
+}
+
+// Validate checks val against the `validate:"..."` tags of its fields and
+// returns ValidationErrors listing every failing key.
+func Validate(val any) error {
+	return validateOrigins(val, nil)
+}
+
+// validateFile validates val read from path, when it is a struct.
+func validateFile(path string, val any) error {
+	if !isStruct(val) {
+		return nil
+	}
+	leaves, err := fields(val)
+	if err != nil {
+		return err
+	}
+	origins := make(Origins, len(leaves))
+	for _, f := range leaves {
+		origins[f.key] = "file:" + path
+	}
+	return validateOrigins(val, origins)
+}
+
+// isStruct reports whether val points to a struct, the only targets holding
+// validate tags, so that the other ones, e.g. maps, are loaded unchecked.
+func isStruct(val any) bool {
+	v := reflect.ValueOf(val)
+	return v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Struct
+}
+
+// validateOrigins validates val, locating the failing keys in the files
+// of origins.
+func validateOrigins(val any, origins Origins) error {
+	leaves, err := fields(val)
+	if err != nil {
+		return err
+	}
+	var errs ValidationErrors
+	lines := make(map[string]map[string]int)
+	for _, f := range leaves {
+		tag, ok := f.tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		for _, r := range parseRules(tag) {
+			msg := checkRule(f.value, r)
+			if msg == "" {
+				continue
+			}
+			fe := &FieldError{Key: f.key, Rule: r.name, Message: msg}
+			if path, ok := strings.CutPrefix(origins[f.key], "file:"); ok {
+				if _, ok = lines[path]; !ok {
+					lines[path] = keyLines(path)
+				}
+				fe.File, fe.Line = path, lines[path][f.key]
+			}
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkRule returns why v fails r, empty if it does not.
+func checkRule(v reflect.Value, r rule) string {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			if r.name == "required" {
+				return "is required"
+			}
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	switch r.name {
+	case "required":
+		if v.IsZero() {
+			return "is required"
+		}
+	case "min", "max":
+		return checkBound(v, r)
+	case "oneof":
+		if v.IsZero() {
+			return ""
+		}
+		s := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(r.param) {
+			if s == option {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of [%s], got %q", r.param, s)
+	case "regex":
+		re, err := compileRegexp(r.param)
+		if err != nil {
+			return fmt.Sprintf("has an invalid regex rule: %v", err)
+		}
+		if v.Kind() == reflect.String && v.Len() > 0 && !re.MatchString(v.String()) {
+			return fmt.Sprintf("must match %s", r.param)
+		}
+	case "duration":
+		if v.Kind() == reflect.String && v.Len() > 0 && v.Type() != durationType {
+			if _, err := time.ParseDuration(v.String()); err != nil {
+				return fmt.Sprintf("must be a duration, got %q", v.String())
+			}
+		}
+	case "url":
+		if v.Kind() == reflect.String && v.Len() > 0 {
+			u, err := url.Parse(v.String())
+			if err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Sprintf("must be an absolute URL, got %q", v.String())
+			}
+		}
+	default:
+		return fmt.Sprintf("has an unknown rule %q", r.name)
+	}
+	return ""
+}
+
+// checkBound compares numbers, durations, or the length of strings, slices
+// and maps, to the bound of a min or max rule.
+func checkBound(v reflect.Value, r rule) string {
+	var (
+		got, bound float64
+		what       = ""
+		err        error
+	)
+	switch {
+	case v.Type() == durationType:
+		var d time.Duration
+		if d, err = time.ParseDuration(r.param); err == nil {
+			got, bound = float64(v.Int()), float64(d)
+		}
+	case v.Kind() == reflect.String || v.Kind() == reflect.Slice || v.Kind() == reflect.Map:
+		got, what = float64(v.Len()), "length "
+		bound, err = strconv.ParseFloat(r.param, 64)
+	case v.CanInt():
+		got = float64(v.Int())
+		bound, err = strconv.ParseFloat(r.param, 64)
+	case v.CanUint():
+		got = float64(v.Uint())
+		bound, err = strconv.ParseFloat(r.param, 64)
+	case v.CanFloat():
+		got = v.Float()
+		bound, err = strconv.ParseFloat(r.param, 64)
+	default:
+		return fmt.Sprintf("cannot be checked by %s", r.name)
+	}
+	if err != nil {
+		return fmt.Sprintf("has an invalid %s rule: %v", r.name, err)
+	}
+	if r.name == "min" && got < bound {
+		return fmt.Sprintf("%smust be >= %s", what, r.param)
+	}
+	if r.name == "max" && got > bound {
+		return fmt.Sprintf("%smust be <= %s", what, r.param)
+	}
+	return ""
+}
+
+// keyLines maps the dotted keys of a yaml or json file to their line.
+func keyLines(path string) map[string]int {
+	_, raw, err := read(path)
+	if err != nil {
+		return nil
+	}
+	lines := make(map[string]int)
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		var doc yaml.Node
+		if yaml.Unmarshal(raw, &doc) == nil && len(doc.Content) > 0 {
+			yamlLines(lines, "", doc.Content[0])
+		}
+	case ".json":
+		jsonLines(lines, raw)
+	}
+	return lines
+}
+
+func yamlLines(lines map[string]int, prefix string, node *yaml.Node) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := join(prefix, node.Content[i].Value)
+		lines[key] = node.Content[i].Line
+		yamlLines(lines, key, node.Content[i+1])
+	}
+}
+
+// jsonLines walks the tokens of raw, keeping the path of the current object
+// keys, and records the line of every key.
+func jsonLines(lines map[string]int, raw []byte) {
+	type frame struct {
+		object  bool
+		key     string
+		wantKey bool
+	}
+	var stack []frame
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			stack = append(stack, frame{object: tok == json.Delim('{'), wantKey: true})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				stack[len(stack)-1].wantKey = true
+			}
+			continue
+		}
+
+		if len(stack) == 0 || !stack[len(stack)-1].object {
+			continue
+		}
+		top := &stack[len(stack)-1]
+		if !top.wantKey {
+			top.wantKey = true
+			continue
+		}
+		top.key, top.wantKey = tok.(string), false
+		keys := make([]string, 0, len(stack))
+		for _, f := range stack {
+			if f.object {
+				keys = append(keys, f.key)
+			}
+		}
+		lines[strings.Join(keys, ".")] = bytes.Count(raw[:dec.InputOffset()], []byte("\n")) + 1
+	}
+}
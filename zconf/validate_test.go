@@ -0,0 +1,106 @@
+package zconf_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/zconf"
+)
+
+type validated struct {
+	Name     string        `yaml:"name" json:"name" validate:"required" description:"service name"`
+	Mode     string        `yaml:"mode" json:"mode" validate:"oneof=dev prod" default:"dev"`
+	Port     int           `yaml:"port" json:"port" validate:"min=1,max=65535"`
+	Endpoint string        `yaml:"endpoint" json:"endpoint" validate:"url"`
+	Interval string        `yaml:"interval" json:"interval" validate:"duration"`
+	Timeout  time.Duration `yaml:"timeout" json:"timeout" validate:"min=1s"`
+	DB       struct {
+		User string `yaml:"user" json:"user" validate:"regex=^[a-z]{2,8}$"`
+	} `yaml:"db" json:"db"`
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml": "name: svc\nmode: test\nport: 0\nendpoint: /relative\ninterval: soon\ntimeout: 10ms\ndb:\n  user: Root\n",
+		"config.json": "{\n  \"name\": \"svc\",\n  \"mode\": \"test\",\n  \"port\": 0,\n  \"endpoint\": \"/relative\",\n  \"interval\": \"soon\",\n  \"timeout\": 10000000,\n  \"db\": {\n    \"user\": \"Root\"\n  }\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// failing keys and their lines, the yaml file has no opening brace
+		expected := map[string]int{"mode": 3, "port": 4, "endpoint": 5, "interval": 6, "timeout": 7, "db.user": 9}
+		if strings.HasSuffix(name, ".yaml") {
+			for key := range expected {
+				expected[key]--
+			}
+		}
+
+		var cfg validated
+		err := zconf.Load(path, &cfg)
+		var errs zconf.ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Fatalf("%s: expected validation errors, got %v", name, err)
+		}
+		got := make(map[string]int)
+		for _, fe := range errs {
+			if fe.File != path {
+				t.Errorf("%s: file of %s is %q", name, fe.Key, fe.File)
+			}
+			got[fe.Key] = fe.Line
+		}
+		for key, line := range expected {
+			if got[key] != line {
+				t.Errorf("%s: %s reported at line %d, expected %d\n%v", name, key, got[key], line, err)
+			}
+		}
+		if len(got) != len(expected) {
+			t.Errorf("%s: unexpected errors\n%v", name, err)
+		}
+	}
+
+	var cfg validated
+	var fe *zconf.FieldError
+	if err := zconf.Validate(&cfg); !errors.As(err, &fe) || fe.Key != "name" || fe.Rule != "required" {
+		t.Fatalf("expected name to be required, got %v", err)
+	}
+	cfg.Name, cfg.Port, cfg.Timeout = "svc", 80, time.Second
+	if err := zconf.Validate(&cfg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	raw, err := zconf.JSONSchema(&validated{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema struct {
+		Type       string                    `json:"type"`
+		Required   []string                  `json:"required"`
+		Properties map[string]map[string]any `json:"properties"`
+	}
+	if err = json.Unmarshal(raw, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Type != "object" || len(schema.Required) != 1 || schema.Required[0] != "name" {
+		t.Fatalf("schema\n%s", raw)
+	}
+	props := schema.Properties
+	if props["name"]["description"] != "service name" || props["mode"]["default"] != "dev" ||
+		len(props["mode"]["enum"].([]any)) != 2 || props["port"]["maximum"] != 65535.0 ||
+		props["endpoint"]["format"] != "uri" || props["db"]["type"] != "object" {
+		t.Fatalf("schema\n%s", raw)
+	}
+	user := props["db"]["properties"].(map[string]any)["user"].(map[string]any)
+	if user["pattern"] != "^[a-z]{2,8}$" {
+		t.Fatalf("schema\n%s", raw)
+	}
+}
@@ -0,0 +1,353 @@
+package zconf
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Validator is implemented by configs checking themselves after loading.
+type Validator interface {
+	Validate() error
+}
+
+// notifier signals that the watched file may have changed.
+type notifier interface {
+	Events() <-chan struct{}
+	Close() error
+}
+
+type watchOptions struct {
+	pollInterval time.Duration
+	debounce     time.Duration
+	validate     func(val any) error
+	onError      func(err error)
+}
+
+type WatchOption func(o *watchOptions)
+
+// WithPollInterval sets how often the file is checked when it cannot be
+// watched by the system, 1s by default.
+func WithPollInterval(d time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.pollInterval = d
+	}
+}
+
+// WithDebounce sets how long to wait for a burst of changes to settle
+// before reloading, 100ms by default.
+func WithDebounce(d time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.debounce = d
+	}
+}
+
+// WithValidate checks every loaded config, in addition to its Validate
+// method; a failing config is not applied.
+func WithValidate(fn func(val any) error) WatchOption {
+	return func(o *watchOptions) {
+		o.validate = fn
+	}
+}
+
+// WithReloadError is called when a reload fails, the previous config is
+// kept.
+func WithReloadError(fn func(err error)) WatchOption {
+	return func(o *watchOptions) {
+		o.onError = fn
+	}
+}
+
+// Watcher holds the current snapshot of a config and reloads it when its
+// source changes.
+type Watcher[T any] struct {
+	src     Source
+	opts    watchOptions
+	current atomic.Pointer[T]
+
+	reload sync.Mutex
+	sum    [sha256.Size]byte
+
+	lock  sync.Mutex
+	subId int
+	subs  map[int]func(old, new *T)
+	order []int
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	events   chan struct{}
+	stopOnce sync.Once
+	done     sync.WaitGroup
+}
+
+// Watch loads path into val and keeps reloading it on changes. Every
+// successful reload stores a new snapshot, returned by Get, and calls
+// onChange with the previous and the new one. val itself is the first
+// snapshot and is not modified afterwards.
+func Watch[T any](path string, val *T, onChange func(old, new *T), opts ...WatchOption) (*Watcher[T], error) {
+	o := newWatchOptions(opts)
+	src := NewFileSource(path, WithSourceInterval(o.pollInterval))
+	w, err := newWatcher(context.Background(), src, val, onChange, o)
+	if err != nil {
+		_ = src.Close()
+	}
+	return w, err
+}
+
+// WatchSource loads src into val and keeps reloading it when src changes,
+// as Watch does for files. Failing to wait for src, e.g. when a remote
+// source is unreachable, is reported to WithReloadError and retried every
+// poll interval. Close closes src if it is an io.Closer.
+func WatchSource[T any](ctx context.Context, src Source, val *T, onChange func(old, new *T), opts ...WatchOption) (*Watcher[T], error) {
+	return newWatcher(ctx, src, val, onChange, newWatchOptions(opts))
+}
+
+func newWatchOptions(opts []WatchOption) watchOptions {
+	o := watchOptions{
+		pollInterval: time.Second,
+		debounce:     100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func newWatcher[T any](ctx context.Context, src Source, val *T, onChange func(old, new *T), opts watchOptions) (*Watcher[T], error) {
+	w := &Watcher[T]{
+		src:    src,
+		opts:   opts,
+		subs:   make(map[int]func(old, new *T)),
+		events: make(chan struct{}, 1),
+	}
+
+	if file, ok := src.(*FileSource); ok {
+		file.events()
+	}
+	dirver, raw, err := src.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = unmarshal(dirver, raw, val); err != nil {
+		return nil, err
+	}
+	if err = w.validate(val); err != nil {
+		return nil, err
+	}
+	w.sum = sha256.Sum256(raw)
+	w.current.Store(val)
+	if onChange != nil {
+		w.Subscribe(onChange)
+	}
+
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	w.done.Add(2)
+	go w.wait()
+	go w.run()
+	return w, nil
+}
+
+// Get returns the current snapshot, it must not be modified.
+func (w *Watcher[T]) Get() *T {
+	return w.current.Load()
+}
+
+// Subscribe adds fn to the functions called after a reload and returns a
+// func removing it.
+func (w *Watcher[T]) Subscribe(fn func(old, new *T)) (cancel func()) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.subId++
+	id := w.subId
+	w.subs[id] = fn
+	w.order = append(w.order, id)
+	return func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		delete(w.subs, id)
+	}
+}
+
+// Reload reads the source now. Nothing happens if its content did not
+// change.
+func (w *Watcher[T]) Reload() error {
+	w.reload.Lock()
+	defer w.reload.Unlock()
+
+	dirver, raw, err := w.src.Read(w.ctx)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(raw)
+	if sum == w.sum {
+		return nil
+	}
+	val := new(T)
+	if err = unmarshal(dirver, raw, val); err != nil {
+		return err
+	}
+	if err = w.validate(val); err != nil {
+		return err
+	}
+	w.sum = sum
+	old := w.current.Swap(val)
+
+	for _, fn := range w.subscribers() {
+		fn(old, val)
+	}
+	return nil
+}
+
+// Close stops watching the source.
+func (w *Watcher[T]) Close() error {
+	var err error
+	w.stopOnce.Do(func() {
+		w.cancel()
+		if c, ok := w.src.(io.Closer); ok {
+			err = c.Close()
+		}
+		w.done.Wait()
+	})
+	return err
+}
+
+// subscribers returns the subscribed functions in subscription order.
+func (w *Watcher[T]) subscribers() []func(old, new *T) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	fns := make([]func(old, new *T), 0, len(w.subs))
+	order := w.order[:0]
+	for _, id := range w.order {
+		if fn, ok := w.subs[id]; ok {
+			order = append(order, id)
+			fns = append(fns, fn)
+		}
+	}
+	w.order = order
+	return fns
+}
+
+func (w *Watcher[T]) validate(val *T) error {
+	if err := validateSource(w.src, val); err != nil {
+		return err
+	}
+	if v, ok := any(val).(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	if w.opts.validate != nil {
+		return w.opts.validate(val)
+	}
+	return nil
+}
+
+// wait turns the returns of the Wait method of the source into events.
+func (w *Watcher[T]) wait() {
+	defer w.done.Done()
+	for {
+		err := w.src.Wait(w.ctx)
+		if w.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			if w.opts.onError != nil {
+				w.opts.onError(err)
+			}
+			if sleep(w.ctx, w.opts.pollInterval) != nil {
+				return
+			}
+			continue
+		}
+		select {
+		case w.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *Watcher[T]) run() {
+	defer w.done.Done()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-w.events:
+		}
+
+		// let a burst of writes settle before reading the source
+		timer := time.NewTimer(w.opts.debounce)
+	settle:
+		for {
+			select {
+			case <-w.ctx.Done():
+				timer.Stop()
+				return
+			case <-w.events:
+				timer.Reset(w.opts.debounce)
+			case <-timer.C:
+				break settle
+			}
+		}
+
+		if err := w.Reload(); err != nil && w.opts.onError != nil {
+			w.opts.onError(err)
+		}
+	}
+}
+
+// pollNotifier signals when the size or modification time of a file
+// changed.
+type pollNotifier struct {
+	events chan struct{}
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func newPollNotifier(path string, interval time.Duration) *pollNotifier {
+	n := &pollNotifier{
+		events: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+	go func() {
+		var size int64
+		var mtime time.Time
+		if fi, err := os.Stat(path); err == nil {
+			size, mtime = fi.Size(), fi.ModTime()
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-n.stop:
+				return
+			case <-ticker.C:
+			}
+			fi, err := os.Stat(path)
+			if err != nil || (fi.Size() == size && fi.ModTime().Equal(mtime)) {
+				continue
+			}
+			size, mtime = fi.Size(), fi.ModTime()
+			select {
+			case n.events <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return n
+}
+
+func (n *pollNotifier) Events() <-chan struct{} {
+	return n.events
+}
+
+func (n *pollNotifier) Close() error {
+	n.once.Do(func() {
+		close(n.stop)
+	})
+	return nil
+}
@@ -0,0 +1,76 @@
+package zconf_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/zconf"
+)
+
+type watched struct {
+	Name string `yaml:"name"`
+	Port int    `yaml:"port"`
+}
+
+func (w *watched) Validate() error {
+	if w.Port <= 0 {
+		return errors.New("port must be positive")
+	}
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("name: a\nport: 80\n")
+
+	type change struct{ old, new *watched }
+	changes := make(chan change, 4)
+	errs := make(chan error, 4)
+	var cfg watched
+	w, err := zconf.Watch(path, &cfg, func(old, new *watched) {
+		changes <- change{old, new}
+	}, zconf.WithDebounce(10*time.Millisecond), zconf.WithPollInterval(10*time.Millisecond),
+		zconf.WithReloadError(func(err error) { errs <- err }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = w.Close() }()
+	if w.Get() != &cfg || cfg.Name != "a" {
+		t.Fatalf("initial config %+v", cfg)
+	}
+
+	write("name: b\nport: 81\n")
+	select {
+	case c := <-changes:
+		if c.old.Name != "a" || c.new.Name != "b" || w.Get().Port != 81 {
+			t.Fatalf("change %+v -> %+v", c.old, c.new)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("change not noticed")
+	}
+
+	for _, broken := range []string{"name: [c\n", "name: c\nport: 0\n"} {
+		write(broken)
+		select {
+		case err := <-errs:
+			if err == nil {
+				t.Fatal("expected a reload error")
+			}
+		case c := <-changes:
+			t.Fatalf("broken config applied: %+v", c.new)
+		case <-time.After(2 * time.Second):
+			t.Fatal("reload error not reported")
+		}
+		if w.Get().Name != "b" {
+			t.Fatalf("previous config should be kept, got %+v", w.Get())
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package zhttp
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec encodes request bodies and decodes response bodies of a media type.
+// Codecs of other formats, protobuf for instance, are added with
+// RegisterCodec.
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type codec struct {
+	contentType string
+	marshal     func(any) ([]byte, error)
+	unmarshal   func([]byte, any) error
+}
+
+func (c *codec) ContentType() string {
+	return c.contentType
+}
+
+func (c *codec) Marshal(v any) ([]byte, error) {
+	return c.marshal(v)
+}
+
+func (c *codec) Unmarshal(data []byte, v any) error {
+	return c.unmarshal(data, v)
+}
+
+var (
+	JSONCodec Codec = &codec{contentType: "application/json", marshal: json.Marshal, unmarshal: json.Unmarshal}
+	XMLCodec  Codec = &codec{contentType: "application/xml", marshal: xml.Marshal, unmarshal: xml.Unmarshal}
+	FormCodec Codec = &codec{contentType: "application/x-www-form-urlencoded", marshal: marshalForm, unmarshal: unmarshalForm}
+)
+
+var codecs = struct {
+	lock  sync.RWMutex
+	types map[string]Codec
+}{
+	types: map[string]Codec{
+		"application/json":                  JSONCodec,
+		"text/json":                         JSONCodec,
+		"application/xml":                   XMLCodec,
+		"text/xml":                          XMLCodec,
+		"application/x-www-form-urlencoded": FormCodec,
+	},
+}
+
+// RegisterCodec makes c decode the responses of mediaType, e.g.
+// "application/x-protobuf".
+func RegisterCodec(mediaType string, c Codec) {
+	codecs.lock.Lock()
+	defer codecs.lock.Unlock()
+	codecs.types[strings.ToLower(mediaType)] = c
+}
+
+// CodecFor returns the codec of a Content-Type header, parameters such as
+// charset being ignored. Structured syntax suffixes are understood, so that
+// application/problem+json is decoded as JSON.
+func CodecFor(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	codecs.lock.RLock()
+	defer codecs.lock.RUnlock()
+	if c, ok := codecs.types[mediaType]; ok {
+		return c, true
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		if c, ok := codecs.types["application/"+mediaType[i+1:]]; ok {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// marshalForm encodes url.Values, maps of strings and structs, whose fields
+// are named by their `form:"..."` or json tag.
+func marshalForm(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case url.Values:
+		return []byte(v.Encode()), nil
+	case map[string][]string:
+		return []byte(url.Values(v).Encode()), nil
+	case map[string]string:
+		values := make(url.Values, len(v))
+		for k, s := range v {
+			values.Set(k, s)
+		}
+		return []byte(values.Encode()), nil
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("zhttp: cannot encode %T as a form", v)
+	}
+	values := make(url.Values)
+	for i := 0; i < rv.NumField(); i++ {
+		name, omitempty, ok := formField(rv.Type().Field(i))
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+		if omitempty && fv.IsZero() {
+			continue
+		}
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			for j := 0; j < fv.Len(); j++ {
+				values.Add(name, formString(fv.Index(j)))
+			}
+			continue
+		}
+		values.Set(name, formString(fv))
+	}
+	return []byte(values.Encode()), nil
+}
+
+func unmarshalForm(data []byte, v any) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case *url.Values:
+		*v = values
+		return nil
+	case *map[string]string:
+		*v = make(map[string]string, len(values))
+		for k := range values {
+			(*v)[k] = values.Get(k)
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("zhttp: cannot decode a form into %T", v)
+	}
+	rv = rv.Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		name, _, ok := formField(rv.Type().Field(i))
+		if !ok || !values.Has(name) {
+			continue
+		}
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			items := reflect.MakeSlice(fv.Type(), len(values[name]), len(values[name]))
+			for j, s := range values[name] {
+				if err = setFormValue(items.Index(j), s); err != nil {
+					return fmt.Errorf("zhttp: form field %s: %w", name, err)
+				}
+			}
+			fv.Set(items)
+			continue
+		}
+		if err = setFormValue(fv, values.Get(name)); err != nil {
+			return fmt.Errorf("zhttp: form field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func formField(sf reflect.StructField) (name string, omitempty, ok bool) {
+	if !sf.IsExported() {
+		return "", false, false
+	}
+	tag, found := sf.Tag.Lookup("form")
+	if !found {
+		tag = sf.Tag.Get("json")
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "-" {
+		return "", false, false
+	}
+	if name == "" {
+		name = sf.Name
+	}
+	return name, strings.Contains(opts, "omitempty"), true
+}
+
+func formString(v reflect.Value) string {
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		if raw, err := m.MarshalText(); err == nil {
+			return string(raw)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+func setFormValue(v reflect.Value, s string) error {
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
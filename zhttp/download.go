@@ -0,0 +1,456 @@
+package zhttp
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/uaxe/infra/storage"
+	"github.com/uaxe/infra/threading"
+	"github.com/uaxe/infra/zhash"
+)
+
+var (
+	ErrChecksumMismatch  = errors.New("zhttp: checksum mismatch")
+	ErrInsufficientSpace = errors.New("zhttp: insufficient disk space")
+	// ErrResourceChanged is returned when the server answers a range request
+	// with the whole resource, its ETag or Last-Modified having changed.
+	ErrResourceChanged = errors.New("zhttp: resource changed during download")
+)
+
+// DefaultChunkSize is the size of the ranges Download fetches in parallel.
+var DefaultChunkSize int64 = 8 << 20
+
+var checksums = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+type downloadOptions struct {
+	request     Request
+	header      http.Header
+	concurrency int
+	chunkSize   int64
+	algo        string
+	checksum    string
+	progress    func(done, total int64)
+}
+
+type DownloadOption func(o *downloadOptions)
+
+// WithDownloadRequest sends the requests of the download with r, e.g. to
+// retry the ranges failing.
+func WithDownloadRequest(r Request) DownloadOption {
+	return func(o *downloadOptions) {
+		o.request = r
+	}
+}
+
+func WithDownloadHeader(key, value string) DownloadOption {
+	return func(o *downloadOptions) {
+		o.header.Add(key, value)
+	}
+}
+
+// WithConcurrency bounds the ranges fetched at once, 4 by default.
+func WithConcurrency(n int) DownloadOption {
+	return func(o *downloadOptions) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+func WithChunkSize(n int64) DownloadOption {
+	return func(o *downloadOptions) {
+		if n > 0 {
+			o.chunkSize = n
+		}
+	}
+}
+
+// WithChecksum verifies the downloaded file against sum, the hex digest of
+// algo: md5, sha1, sha256 or sha512.
+func WithChecksum(algo, sum string) DownloadOption {
+	return func(o *downloadOptions) {
+		o.algo, o.checksum = strings.ToLower(algo), sum
+	}
+}
+
+// WithProgress calls fn as data is written, total being -1 when the server
+// does not tell the size. Calls are serialized.
+func WithProgress(fn func(done, total int64)) DownloadOption {
+	return func(o *downloadOptions) {
+		o.progress = fn
+	}
+}
+
+// Download fetches rawURL into path. When the server supports Range
+// requests the file is fetched in chunks downloaded in parallel, and the
+// chunks of a download interrupted, e.g. by a crash, are kept in path.part
+// so that the next call for the same path only fetches the missing ones.
+//
+// Download fails early with ErrInsufficientSpace when the disk of path
+// cannot hold the file, and with ErrChecksumMismatch, discarding the data,
+// when the file does not match WithChecksum.
+func Download(ctx context.Context, rawURL, path string, opts ...DownloadOption) error {
+	o := downloadOptions{
+		request:     defaultRequest,
+		header:      make(http.Header),
+		concurrency: 4,
+		chunkSize:   DefaultChunkSize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	d := &download{
+		downloadOptions: o,
+		url:             u,
+		path:            path,
+		part:            path + ".part",
+		statePath:       path + ".part.state",
+		total:           -1,
+	}
+	if o.checksum != "" {
+		if d.newHash = checksums[o.algo]; d.newHash == nil {
+			return fmt.Errorf("zhttp: unsupported checksum %q", o.algo)
+		}
+	}
+
+	// a one byte range tells whether ranges are supported, and the size
+	resp, err := d.get(ctx, "bytes=0-0", "")
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		_ = resp.Body.Close()
+		size, ok := contentRangeSize(resp.Header.Get("Content-Range"))
+		if ok {
+			return d.ranged(ctx, size, resp.Header)
+		}
+	case http.StatusOK:
+		return d.stream(resp)
+	case http.StatusRequestedRangeNotSatisfiable:
+		// an empty resource
+		_ = resp.Body.Close()
+	default:
+		defer func() { _ = resp.Body.Close() }()
+		return newStatusError(http.MethodGet, u, resp)
+	}
+
+	if resp, err = d.get(ctx, "", ""); err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer func() { _ = resp.Body.Close() }()
+		return newStatusError(http.MethodGet, u, resp)
+	}
+	return d.stream(resp)
+}
+
+// downloadState is saved next to the partial file to resume a download.
+type downloadState struct {
+	URL          string `json:"url"`
+	Size         int64  `json:"size"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	ChunkSize    int64  `json:"chunkSize"`
+	Done         []bool `json:"done"`
+}
+
+type download struct {
+	downloadOptions
+	url       *url.URL
+	path      string
+	part      string
+	statePath string
+	newHash   func() hash.Hash
+
+	lock  sync.Mutex
+	state *downloadState
+	done  int64
+	total int64
+}
+
+func (d *download) get(ctx context.Context, byteRange, ifRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range d.header {
+		req.Header[k] = vs
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+	if ifRange != "" {
+		req.Header.Set("If-Range", ifRange)
+	}
+	return d.request.ContextDo(ctx, req)
+}
+
+// stream writes the whole body of resp, the server not supporting ranges.
+func (d *download) stream(resp *http.Response) error {
+	defer func() { _ = resp.Body.Close() }()
+	d.total = resp.ContentLength
+	if err := checkDiskSpace(filepath.Dir(d.path), d.total); err != nil {
+		return err
+	}
+	_ = os.Remove(d.statePath)
+	f, err := os.OpenFile(d.part, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(&offsetWriter{file: f, d: d}, resp.Body); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return d.finish(f)
+}
+
+// ranged fetches the missing chunks of a resource of size bytes.
+func (d *download) ranged(ctx context.Context, size int64, header http.Header) error {
+	d.total = size
+	d.state = &downloadState{
+		URL:          d.url.String(),
+		Size:         size,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		ChunkSize:    d.chunkSize,
+		Done:         make([]bool, int((size+d.chunkSize-1)/d.chunkSize)),
+	}
+	if d.resume() {
+		for i, ok := range d.state.Done {
+			if ok {
+				d.done += d.chunkEnd(i) - int64(i)*d.chunkSize
+			}
+		}
+	} else {
+		_ = os.Remove(d.part)
+	}
+	if err := checkDiskSpace(filepath.Dir(d.path), size-d.done); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(d.part, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	if err = f.Truncate(size); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = d.saveState(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	ifRange := d.state.ETag
+	if ifRange == "" {
+		ifRange = d.state.LastModified
+	}
+	g := threading.NewGroup(ctx)
+	g.SetLimit(d.concurrency)
+	for i, ok := range d.state.Done {
+		if ok {
+			continue
+		}
+		i := i
+		g.Go(func(ctx context.Context) error {
+			return d.fetchChunk(ctx, f, i, ifRange)
+		})
+	}
+	if err = g.Wait(); err != nil {
+		_ = f.Close()
+		if errors.Is(err, ErrResourceChanged) {
+			d.discard()
+		}
+		return err
+	}
+	// the chunks not started once ctx is done fail no goroutine
+	if err = ctx.Err(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	for i, ok := range d.state.Done {
+		if !ok {
+			_ = f.Close()
+			return fmt.Errorf("zhttp: range %d-%d of %s not fetched: %w",
+				int64(i)*d.chunkSize, d.chunkEnd(i)-1, d.url.Redacted(), io.ErrUnexpectedEOF)
+		}
+	}
+	return d.finish(f)
+}
+
+func (d *download) chunkEnd(i int) int64 {
+	end := int64(i+1) * d.chunkSize
+	if end > d.total {
+		end = d.total
+	}
+	return end
+}
+
+func (d *download) fetchChunk(ctx context.Context, f *os.File, i int, ifRange string) error {
+	start, end := int64(i)*d.chunkSize, d.chunkEnd(i)
+	resp, err := d.get(ctx, fmt.Sprintf("bytes=%d-%d", start, end-1), ifRange)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return fmt.Errorf("%w: %s", ErrResourceChanged, d.url.Redacted())
+	default:
+		return newStatusError(http.MethodGet, d.url, resp)
+	}
+	n, err := io.Copy(&offsetWriter{file: f, off: start, d: d}, io.LimitReader(resp.Body, end-start))
+	if err != nil {
+		return err
+	}
+	if n != end-start {
+		return fmt.Errorf("zhttp: range %d-%d of %s: %w", start, end-1, d.url.Redacted(), io.ErrUnexpectedEOF)
+	}
+	// the state must not mark done a chunk lost by a crash
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	d.lock.Lock()
+	d.state.Done[i] = true
+	d.lock.Unlock()
+	return d.saveState()
+}
+
+// resume reports whether the saved state is the one of the same resource.
+func (d *download) resume() bool {
+	raw, err := os.ReadFile(d.statePath)
+	if err != nil {
+		return false
+	}
+	var saved downloadState
+	if err = json.Unmarshal(raw, &saved); err != nil {
+		return false
+	}
+	info, err := os.Stat(d.part)
+	if err != nil || info.Size() != d.state.Size {
+		return false
+	}
+	if saved.URL != d.state.URL || saved.Size != d.state.Size || saved.ETag != d.state.ETag ||
+		saved.LastModified != d.state.LastModified || saved.ChunkSize != d.state.ChunkSize ||
+		len(saved.Done) != len(d.state.Done) {
+		return false
+	}
+	d.state.Done = saved.Done
+	return true
+}
+
+func (d *download) saveState() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	raw, err := json.Marshal(d.state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(d.statePath, raw, 0o644)
+}
+
+func (d *download) discard() {
+	_ = os.Remove(d.part)
+	_ = os.Remove(d.statePath)
+}
+
+// finish verifies the checksum of the partial file f and moves it to path.
+func (d *download) finish(f *os.File) error {
+	if d.newHash != nil {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			_ = f.Close()
+			return err
+		}
+		sum, err := zhash.HashFile(d.newHash(), f)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		if !strings.EqualFold(sum, d.checksum) {
+			_ = f.Close()
+			d.discard()
+			return fmt.Errorf("%w: %s of %s is %s, expected %s", ErrChecksumMismatch, d.algo, d.url.Redacted(), sum, d.checksum)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(d.part, d.path); err != nil {
+		return err
+	}
+	_ = os.Remove(d.statePath)
+	return nil
+}
+
+func (d *download) advance(n int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.done += int64(n)
+	if d.progress != nil {
+		d.progress(d.done, d.total)
+	}
+}
+
+// offsetWriter writes to file from off, reporting the progress to d.
+type offsetWriter struct {
+	file *os.File
+	off  int64
+	d    *download
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.file.WriteAt(p, w.off)
+	w.off += int64(n)
+	w.d.advance(n)
+	return n, err
+}
+
+// contentRangeSize returns the complete length of a Content-Range header
+// such as "bytes 0-0/1234".
+func contentRangeSize(header string) (int64, bool) {
+	i := strings.LastIndexByte(header, '/')
+	if !strings.HasPrefix(header, "bytes ") || i < 0 {
+		return 0, false
+	}
+	size, err := strconv.ParseInt(header[i+1:], 10, 64)
+	return size, err == nil && size >= 0
+}
+
+func checkDiskSpace(dir string, need int64) error {
+	all, free := storage.DiskUsage(dir)
+	// an unknown size or usage is not checked
+	if all == 0 || need <= 0 || uint64(need) <= free {
+		return nil
+	}
+	return fmt.Errorf("%w: %d bytes needed, %d free in %s", ErrInsufficientSpace, need, free, dir)
+}
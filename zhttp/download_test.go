@@ -0,0 +1,144 @@
+package zhttp_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/zhttp"
+)
+
+func TestDownload(t *testing.T) {
+	content := make([]byte, 100<<10)
+	rand.New(rand.NewSource(1)).Read(content)
+	digest := sha256.Sum256(content)
+	sum := hex.EncodeToString(digest[:])
+
+	var (
+		ranges  atomic.Int32
+		failing atomic.Bool
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "bytes=0-0" {
+			ranges.Add(1)
+			if failing.Load() && r.Header.Get("Range") == "bytes=40960-51199" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "artifact.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// a crash in the middle keeps the chunks downloaded
+	path := filepath.Join(dir, "artifact.bin")
+	failing.Store(true)
+	err := zhttp.Download(ctx, srv.URL, path, zhttp.WithChunkSize(10<<10), zhttp.WithConcurrency(1))
+	var statusErr *zhttp.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected a StatusError, got %v", err)
+	}
+	if _, err = os.Stat(path + ".part"); err != nil {
+		t.Fatal(err)
+	}
+
+	failing.Store(false)
+	ranges.Store(0)
+	var (
+		lock   sync.Mutex
+		last   int64
+		totals = map[int64]bool{}
+	)
+	err = zhttp.Download(ctx, srv.URL, path,
+		zhttp.WithChunkSize(10<<10), zhttp.WithConcurrency(4),
+		zhttp.WithChecksum("sha256", sum),
+		zhttp.WithProgress(func(done, total int64) {
+			lock.Lock()
+			defer lock.Unlock()
+			if done < last {
+				t.Errorf("progress went back from %d to %d", last, done)
+			}
+			last, totals[total] = done, true
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("content differs: %v", err)
+	}
+	if n := ranges.Load(); n != 6 {
+		t.Fatalf("expected the 6 missing chunks to be fetched, got %d", n)
+	}
+	if last != int64(len(content)) || len(totals) != 1 || !totals[int64(len(content))] {
+		t.Fatalf("progress %d of %v", last, totals)
+	}
+	if _, err = os.Stat(path + ".part.state"); !os.IsNotExist(err) {
+		t.Fatalf("state file left: %v", err)
+	}
+
+	// a download canceled between two chunks is not completed
+	canceled := filepath.Join(dir, "canceled.bin")
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	err = zhttp.Download(cctx, srv.URL, canceled,
+		zhttp.WithChunkSize(50<<10), zhttp.WithConcurrency(1),
+		zhttp.WithProgress(func(done, _ int64) {
+			if done == 50<<10 {
+				cancel()
+			}
+		}))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+	if _, err = os.Stat(canceled); !os.IsNotExist(err) {
+		t.Fatal("canceled download completed")
+	}
+	if _, err = os.Stat(canceled + ".part.state"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a wrong checksum discards the file
+	bad := filepath.Join(dir, "bad.bin")
+	err = zhttp.Download(ctx, srv.URL, bad, zhttp.WithChecksum("md5", "00"))
+	if !errors.Is(err, zhttp.ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err = os.Stat(bad); !os.IsNotExist(err) {
+		t.Fatal("file of a wrong checksum kept")
+	}
+}
+
+func TestDownload_WithoutRanges(t *testing.T) {
+	content := bytes.Repeat([]byte("zhttp"), 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(content)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "plain.txt")
+	var done int64
+	err := zhttp.Download(context.Background(), srv.URL, path,
+		zhttp.WithProgress(func(n, _ int64) { done = n }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(got, content) || done != int64(len(content)) {
+		t.Fatalf("%d bytes, %d done, %v", len(got), done, err)
+	}
+}
@@ -0,0 +1,67 @@
+package zhttp
+
+import (
+	"net/http"
+	"time"
+)
+
+// Middleware wraps a RoundTripper, to add auth headers, log or trace
+// requests.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a func to http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wraps rt with mws, the first middleware being the outermost.
+func Chain(rt http.RoundTripper, mws ...Middleware) http.RoundTripper {
+	for i := len(mws) - 1; i >= 0; i-- {
+		rt = mws[i](rt)
+	}
+	return rt
+}
+
+// HeaderMiddleware sets headers on every request, e.g. a static API key.
+func HeaderMiddleware(h http.Header) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for k, vs := range h {
+				req.Header[k] = append([]string(nil), vs...)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// AuthMiddleware sets the Authorization header returned by token, called
+// for every request so that tokens can be refreshed.
+func AuthMiddleware(token func(req *http.Request) (string, error)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			auth, err := token(req)
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", auth)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// LogMiddleware calls log after every round trip, with the response or the
+// error and the time it took.
+func LogMiddleware(log func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			log(req, resp, err, time.Since(start))
+			return resp, err
+		})
+	}
+}
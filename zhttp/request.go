@@ -1,8 +1,13 @@
 package zhttp
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type Request interface {
@@ -12,7 +17,20 @@ type Request interface {
 
 var _ Request = (*DefaultRequest)(nil)
 
-type DefaultRequest struct{}
+// DefaultRequest sends requests with DefaultClient, or with a client built
+// from its options: per request timeout, retries and a RoundTripper
+// middleware chain.
+type DefaultRequest struct {
+	client      *http.Client
+	transport   http.RoundTripper
+	middlewares []Middleware
+	timeout     time.Duration
+
+	retry         bool
+	retryOpts     []func(option *RetryOption)
+	retryAll      bool
+	shouldRetryFn func(*http.Response, error) bool
+}
 
 type RequestOption func(r *DefaultRequest)
 
@@ -21,18 +39,214 @@ func NewRequest(opts ...RequestOption) Request {
 	for _, opt := range opts {
 		opt(r)
 	}
+	if r.transport != nil || len(r.middlewares) > 0 {
+		base := r.transport
+		if base == nil {
+			base = DefaultClient.Transport
+		}
+		r.client = &http.Client{Transport: Chain(base, r.middlewares...)}
+	}
 	return r
 }
 
-func (*DefaultRequest) Do(r *http.Request) (*http.Response, error) {
-	return DefaultClient.Do(r)
+// WithTransport sets the RoundTripper under the middlewares, the transport
+// of DefaultClient by default.
+func WithTransport(rt http.RoundTripper) RequestOption {
+	return func(r *DefaultRequest) {
+		r.transport = rt
+	}
+}
+
+// WithMiddleware appends middlewares to the chain, the first one being the
+// outermost.
+func WithMiddleware(mws ...Middleware) RequestOption {
+	return func(r *DefaultRequest) {
+		r.middlewares = append(r.middlewares, mws...)
+	}
+}
+
+// WithTimeout bounds every attempt of a request, including reading the
+// response body.
+func WithTimeout(d time.Duration) RequestOption {
+	return func(r *DefaultRequest) {
+		r.timeout = d
+	}
 }
 
-func (*DefaultRequest) ContextDo(ctx context.Context, r *http.Request) (*http.Response, error) {
+// WithRetry retries requests failing with a network error or a status of
+// RetryableHTTPStatusCodes, waiting between attempts as NewRetryTimer does
+// with opts. Only idempotent requests are retried, see
+// WithRetryNonIdempotent.
+func WithRetry(opts ...Retry) RequestOption {
+	return func(r *DefaultRequest) {
+		r.retry = true
+		for _, opt := range opts {
+			r.retryOpts = append(r.retryOpts, opt)
+		}
+	}
+}
+
+// WithRetryNonIdempotent also retries POST and PATCH requests without an
+// Idempotency-Key header.
+func WithRetryNonIdempotent() RequestOption {
+	return func(r *DefaultRequest) {
+		r.retryAll = true
+	}
+}
+
+// WithRetryIf replaces the check deciding whether an attempt is retried.
+func WithRetryIf(fn func(*http.Response, error) bool) RequestOption {
+	return func(r *DefaultRequest) {
+		r.shouldRetryFn = fn
+	}
+}
+
+func (r *DefaultRequest) Do(req *http.Request) (*http.Response, error) {
+	return r.ContextDo(req.Context(), req)
+}
+
+func (r *DefaultRequest) ContextDo(ctx context.Context, req *http.Request) (*http.Response, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	default:
 	}
-	return DefaultClient.Do(r)
+	if !r.retry || (!r.retryAll && !IsIdempotent(req)) {
+		return r.attempt(ctx, req)
+	}
+	if err := ReplayableBody(req); err != nil {
+		return nil, err
+	}
+
+	timerCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	var (
+		resp *http.Response
+		err  error
+	)
+	for range NewRetryTimer(timerCtx, r.retryOpts...) {
+		if resp != nil {
+			// the previous response is discarded, draining it lets the
+			// connection be reused
+			wait := retryAfter(resp)
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			_ = resp.Body.Close()
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return nil, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if req.GetBody != nil && req.Body != nil && req.Body != http.NoBody {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return nil, bodyErr
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err = r.attempt(ctx, req)
+		if ctx.Err() != nil || !r.shouldRetry(resp, err) {
+			return resp, err
+		}
+	}
+	if resp == nil && err == nil {
+		err = ctx.Err()
+	}
+	return resp, err
+}
+
+// attempt sends req once, bounded by the timeout.
+func (r *DefaultRequest) attempt(ctx context.Context, req *http.Request) (*http.Response, error) {
+	client := r.client
+	if client == nil {
+		client = DefaultClient
+	}
+	if r.timeout <= 0 {
+		return client.Do(req.WithContext(ctx))
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (r *DefaultRequest) shouldRetry(resp *http.Response, err error) bool {
+	if r.shouldRetryFn != nil {
+		return r.shouldRetryFn(resp, err)
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return IsHTTPStatusRetryable(resp.StatusCode)
+}
+
+// cancelBody releases the context of an attempt once its body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// IsIdempotent reports whether req may be sent twice: its method is
+// idempotent or it carries an Idempotency-Key header.
+func IsIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
+
+// ReplayableBody buffers the body of req, unless it can already be
+// replayed, so that it can be sent again.
+func ReplayableBody(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody != nil {
+		return nil
+	}
+	raw, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(raw))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(raw)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return nil
+}
+
+// retryAfter returns the delay asked by the Retry-After header of a 429 or
+// 503 response, in seconds or as a date.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
 }
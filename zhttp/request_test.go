@@ -0,0 +1,164 @@
+package zhttp_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/zhttp"
+)
+
+func fastRetry() zhttp.RequestOption {
+	return zhttp.WithRetry(zhttp.WithMaxRetry(3), zhttp.WithRetryUnit(time.Millisecond))
+}
+
+func TestRequest_Retry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("attempt %d got body %q", calls.Load(), body)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodPut, srv.URL, io.NopCloser(strings.NewReader("payload")))
+	resp, err := zhttp.NewRequest(fastRetry()).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Fatalf("status %d after %d calls", resp.StatusCode, calls.Load())
+	}
+
+	// a POST without idempotency key is sent once
+	calls.Store(-10)
+	req, _ = http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+	if resp, err = zhttp.NewRequest(fastRetry()).Do(req); err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != -9 {
+		t.Fatalf("status %d, calls %d", resp.StatusCode, calls.Load())
+	}
+
+	calls.Store(1)
+	req, _ = http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+	req.Header.Set("Idempotency-Key", "k1")
+	if resp, err = zhttp.NewRequest(fastRetry()).Do(req); err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Fatalf("status %d, calls %d", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRequest_ConcurrentJitter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := zhttp.NewRequest(zhttp.WithRetry(zhttp.WithMaxRetry(3), zhttp.WithRetryUnit(time.Millisecond), zhttp.WithJitter(zhttp.MaxJitter)))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRequest_NetworkErrorRetry(t *testing.T) {
+	var calls atomic.Int32
+	fail := zhttp.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return nil, errors.New("connection reset")
+	})
+	req, _ := http.NewRequest(http.MethodGet, "http://example.invalid", nil)
+	_, err := zhttp.NewRequest(zhttp.WithTransport(fail), fastRetry()).Do(req)
+	if err == nil || calls.Load() != 3 {
+		t.Fatalf("err %v after %d calls", err, calls.Load())
+	}
+}
+
+func TestRequest_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	start := time.Now()
+	_, err := zhttp.NewRequest(zhttp.WithTimeout(20 * time.Millisecond)).Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("err %v after %v", err, time.Since(start))
+	}
+}
+
+func TestRequest_Middleware(t *testing.T) {
+	var order []string
+	trace := func(name string) zhttp.Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return zhttp.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("X-Api")))
+	}))
+	defer srv.Close()
+
+	var logged int
+	r := zhttp.NewRequest(zhttp.WithMiddleware(
+		trace("outer"),
+		zhttp.AuthMiddleware(func(*http.Request) (string, error) { return "Bearer t", nil }),
+		zhttp.HeaderMiddleware(http.Header{"X-Api": {"k"}}),
+		trace("inner"),
+		zhttp.LogMiddleware(func(_ *http.Request, resp *http.Response, err error, _ time.Duration) {
+			if err == nil && resp.StatusCode == http.StatusOK {
+				logged++
+			}
+		}),
+	))
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := r.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "Bearer t|k" || strings.Join(order, ",") != "outer,inner" || logged != 1 {
+		t.Fatalf("body %q, order %v, logged %d", body, order, logged)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Fatal("middlewares must not modify the caller's request")
+	}
+}
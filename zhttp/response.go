@@ -2,7 +2,6 @@ package zhttp
 
 import (
 	"bytes"
-	"encoding/json"
 	"io"
 	"net/http"
 
@@ -33,15 +32,20 @@ func (p *defaultParse) ParseHeader(r *http.Response, obj any) error {
 	return binder.Header.Binding(r.Header, obj)
 }
 
+// ParseBody decodes the body with the codec of its Content-Type, bodies of
+// other types are left unread.
 func (p *defaultParse) ParseBody(r *http.Response, obj any) error {
-	switch r.Header.Get("Content-Type") {
-	case "application/json":
-		raw, err := io.ReadAll(r.Body)
-		if err != nil {
-			return err
-		}
-		r.Body = io.NopCloser(bytes.NewReader(raw))
-		return json.Unmarshal(raw, obj)
+	c, ok := CodecFor(r.Header.Get("Content-Type"))
+	if !ok {
+		return nil
 	}
-	return nil
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	if len(raw) == 0 {
+		return nil
+	}
+	return c.Unmarshal(raw, obj)
 }
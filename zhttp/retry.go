@@ -4,6 +4,7 @@ import (
 	"context"
 	"math/rand"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -14,6 +15,10 @@ var (
 	Random    = rand.New(rand.NewSource(time.Now().UTC().UnixNano()))
 )
 
+// randomLock guards the sources of the retry timers, a rand.Rand being
+// unsafe for concurrent use and Random shared by default.
+var randomLock sync.Mutex
+
 const (
 	MaxJitter = 1.0
 	NoJitter  = 0.0
@@ -88,7 +93,10 @@ func NewRetryTimer(ctx context.Context, opts ...func(option *RetryOption)) <-cha
 			sleep = retry.retryCap
 		}
 		if retry.jitter != NoJitter {
-			sleep -= time.Duration(retry.random.Float64() * float64(sleep) * retry.jitter)
+			randomLock.Lock()
+			r := retry.random.Float64()
+			randomLock.Unlock()
+			sleep -= time.Duration(r * float64(sleep) * retry.jitter)
 		}
 		return sleep
 	}
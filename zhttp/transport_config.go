@@ -0,0 +1,246 @@
+package zhttp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+)
+
+// TransportConfig describes an http.Transport. Its yaml tags let it be
+// loaded with zconf, the durations written as "5s".
+//
+// The bools and durations are pointers, so that a profile can set them to
+// false or 0 over its default profile, see Bool and Duration. The limits
+// are unset by a negative value instead, e.g. -1 for no MaxConnsPerHost.
+type TransportConfig struct {
+	// Proxy is an http, https or socks5 URL parsed by ParseProxy, the
+	// proxy of the environment when empty.
+	Proxy string `yaml:"proxy"`
+
+	// CAFile is a PEM bundle of the CAs trusted in addition to the system
+	// ones, CertFile and KeyFile the client certificate of mutual TLS.
+	CAFile             string `yaml:"caFile"`
+	CertFile           string `yaml:"certFile"`
+	KeyFile            string `yaml:"keyFile"`
+	ServerName         string `yaml:"serverName"`
+	InsecureSkipVerify *bool  `yaml:"insecureSkipVerify"`
+
+	DialTimeout           *time.Duration `yaml:"dialTimeout"`
+	KeepAlive             *time.Duration `yaml:"keepAlive"`
+	TLSHandshakeTimeout   *time.Duration `yaml:"tlsHandshakeTimeout"`
+	ResponseHeaderTimeout *time.Duration `yaml:"responseHeaderTimeout"`
+	ExpectContinueTimeout *time.Duration `yaml:"expectContinueTimeout"`
+	IdleConnTimeout       *time.Duration `yaml:"idleConnTimeout"`
+
+	MaxIdleConns        int `yaml:"maxIdleConns"`
+	MaxIdleConnsPerHost int `yaml:"maxIdleConnsPerHost"`
+	MaxConnsPerHost     int `yaml:"maxConnsPerHost"`
+	ReadBufferSize      int `yaml:"readBufferSize"`
+	WriteBufferSize     int `yaml:"writeBufferSize"`
+
+	DisableHTTP2       *bool `yaml:"disableHTTP2"`
+	DisableKeepAlives  *bool `yaml:"disableKeepAlives"`
+	DisableCompression *bool `yaml:"disableCompression"`
+}
+
+// Bool returns a pointer to b, for the bools of a TransportConfig.
+func Bool(b bool) *bool {
+	return &b
+}
+
+// Duration returns a pointer to d, for the durations of a TransportConfig.
+func Duration(d time.Duration) *time.Duration {
+	return &d
+}
+
+const (
+	ProfileInternal  = "internal"
+	ProfileExternal  = "external"
+	ProfileStreaming = "streaming"
+)
+
+// DefaultProfiles are the built-in transport profiles:
+//
+//	internal   services of the same network, failing fast with many idle connections
+//	external   third party APIs, with longer timeouts and fewer connections per host
+//	streaming  long lived responses, such as downloads and server-sent events
+var DefaultProfiles = TransportProfiles{
+	ProfileInternal: {
+		DialTimeout:           Duration(2 * time.Second),
+		KeepAlive:             Duration(30 * time.Second),
+		TLSHandshakeTimeout:   Duration(3 * time.Second),
+		ResponseHeaderTimeout: Duration(10 * time.Second),
+		ExpectContinueTimeout: Duration(time.Second),
+		IdleConnTimeout:       Duration(90 * time.Second),
+		MaxIdleConns:          512,
+		MaxIdleConnsPerHost:   64,
+	},
+	ProfileExternal: {
+		DialTimeout:           Duration(10 * time.Second),
+		KeepAlive:             Duration(30 * time.Second),
+		TLSHandshakeTimeout:   Duration(10 * time.Second),
+		ResponseHeaderTimeout: Duration(30 * time.Second),
+		ExpectContinueTimeout: Duration(time.Second),
+		IdleConnTimeout:       Duration(time.Minute),
+		MaxIdleConns:          256,
+		MaxIdleConnsPerHost:   16,
+		MaxConnsPerHost:       64,
+	},
+	ProfileStreaming: {
+		DialTimeout:           Duration(10 * time.Second),
+		KeepAlive:             Duration(15 * time.Second),
+		TLSHandshakeTimeout:   Duration(10 * time.Second),
+		ResponseHeaderTimeout: Duration(30 * time.Second),
+		ExpectContinueTimeout: Duration(time.Second),
+		IdleConnTimeout:       Duration(5 * time.Minute),
+		MaxIdleConns:          64,
+		MaxIdleConnsPerHost:   8,
+		ReadBufferSize:        64 << 10,
+		DisableCompression:    Bool(true),
+	},
+}
+
+// TransportProfiles maps profile names to their config, e.g. loaded with
+// zconf from:
+//
+//	transports:
+//	  internal:
+//	    caFile: /etc/ssl/internal-ca.pem
+//	    certFile: /etc/ssl/client.pem
+//	    keyFile: /etc/ssl/client-key.pem
+//	  external:
+//	    proxy: socks5://proxy:1080
+type TransportProfiles map[string]TransportConfig
+
+// Config returns the config of the profile name, its unset fields taken
+// from the default profile of the same name.
+func (p TransportProfiles) Config(name string) (TransportConfig, error) {
+	cfg, ok := p[name]
+	def, isDefault := DefaultProfiles[name]
+	if !ok && !isDefault {
+		return TransportConfig{}, fmt.Errorf("zhttp: unknown transport profile %q", name)
+	}
+	if isDefault {
+		mergeConfig(&cfg, def)
+	}
+	return cfg, nil
+}
+
+// Transport builds the transport of the profile name.
+func (p TransportProfiles) Transport(name string) (*http.Transport, error) {
+	cfg, err := p.Config(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransport(cfg)
+}
+
+// mergeConfig sets the unset fields of cfg, nil or zero, to those of def.
+func mergeConfig(cfg *TransportConfig, def TransportConfig) {
+	v, d := reflect.ValueOf(cfg).Elem(), reflect.ValueOf(def)
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Field(i).IsZero() {
+			continue
+		}
+		f := d.Field(i)
+		if f.Kind() == reflect.Pointer && !f.IsNil() {
+			// copied, not to share the defaults
+			p := reflect.New(f.Type().Elem())
+			p.Elem().Set(f.Elem())
+			f = p
+		}
+		v.Field(i).Set(f)
+	}
+}
+
+// NewTransport builds the transport described by cfg.
+func NewTransport(cfg TransportConfig) (*http.Transport, error) {
+	tr := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   value(cfg.DialTimeout),
+			KeepAlive: value(cfg.KeepAlive),
+		}).DialContext,
+		TLSHandshakeTimeout:   value(cfg.TLSHandshakeTimeout),
+		ResponseHeaderTimeout: value(cfg.ResponseHeaderTimeout),
+		ExpectContinueTimeout: value(cfg.ExpectContinueTimeout),
+		IdleConnTimeout:       value(cfg.IdleConnTimeout),
+		MaxIdleConns:          limit(cfg.MaxIdleConns),
+		MaxIdleConnsPerHost:   limit(cfg.MaxIdleConnsPerHost),
+		MaxConnsPerHost:       limit(cfg.MaxConnsPerHost),
+		ReadBufferSize:        limit(cfg.ReadBufferSize),
+		WriteBufferSize:       limit(cfg.WriteBufferSize),
+		DisableKeepAlives:     value(cfg.DisableKeepAlives),
+		DisableCompression:    value(cfg.DisableCompression),
+		ForceAttemptHTTP2:     !value(cfg.DisableHTTP2),
+	}
+	if cfg.Proxy != "" {
+		proxyURL, err := ParseProxy(cfg.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		tr.Proxy = http.ProxyURL(proxyURL)
+	}
+	if value(cfg.DisableHTTP2) {
+		// a non nil empty map disables the HTTP/2 upgrade
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	tr.TLSClientConfig = tlsConfig
+	return tr, nil
+}
+
+func (cfg TransportConfig) tlsConfig() (*tls.Config, error) {
+	c := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: value(cfg.InsecureSkipVerify),
+	}
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("zhttp: CA bundle: %w", err)
+		}
+		c.RootCAs = mustGetSystemCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("zhttp: no certificate in CA bundle %s", cfg.CAFile)
+		}
+	}
+	switch {
+	case cfg.CertFile != "" && cfg.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("zhttp: client certificate: %w", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	case cfg.CertFile != "" || cfg.KeyFile != "":
+		return nil, errors.New("zhttp: client certificate needs both certFile and keyFile")
+	}
+	return c, nil
+}
+
+// value returns *p, the zero value when p is nil.
+func value[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
+
+// limit returns n, 0 for the negative values unsetting a default limit.
+func limit(n int) int {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,163 @@
+package zhttp_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/uaxe/infra/zconf"
+	"github.com/uaxe/infra/zhttp"
+)
+
+// writeClientCert writes a self-signed client certificate and its key.
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+	return certFile, keyFile, cert
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransportConfig_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCert := writeClientCert(t, dir)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+	caFile := filepath.Join(dir, "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", srv.Certificate().Raw)
+
+	configFile := filepath.Join(dir, "config.yaml")
+	config := "transports:\n" +
+		"  internal:\n" +
+		"    caFile: " + caFile + "\n" +
+		"    certFile: " + certFile + "\n" +
+		"    keyFile: " + keyFile + "\n" +
+		"    dialTimeout: 1s\n" +
+		"  streaming:\n" +
+		"    responseHeaderTimeout: 0s\n" +
+		"    disableCompression: false\n" +
+		"  external:\n" +
+		"    maxConnsPerHost: -1\n" +
+		"  noclient:\n" +
+		"    caFile: " + caFile + "\n"
+	if err := os.WriteFile(configFile, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Transports zhttp.TransportProfiles `yaml:"transports"`
+	}
+	if err := zconf.Load(configFile, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	internal, err := cfg.Transports.Config(zhttp.ProfileInternal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *internal.DialTimeout != time.Second || internal.MaxIdleConnsPerHost != 64 {
+		t.Fatalf("profile not merged with its defaults: %+v", internal)
+	}
+	// the defaults are overridden by false, 0 and negative limits
+	tr, err := cfg.Transports.Transport(zhttp.ProfileStreaming)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.DisableCompression || tr.ResponseHeaderTimeout != 0 || tr.IdleConnTimeout != 5*time.Minute {
+		t.Fatalf("streaming defaults not overridden: %v %v", tr.DisableCompression, tr.ResponseHeaderTimeout)
+	}
+	if tr, err = cfg.Transports.Transport(zhttp.ProfileExternal); err != nil || tr.MaxConnsPerHost != 0 {
+		t.Fatalf("external limit not unset: %v", err)
+	}
+
+	if tr, err = cfg.Transports.Transport(zhttp.ProfileInternal); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+
+	tr, err = cfg.Transports.Transport("noclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = (&http.Client{Transport: tr}).Get(srv.URL); err == nil {
+		_ = resp.Body.Close()
+		t.Fatal("expected the server to require a client certificate")
+	}
+
+	if _, err = zhttp.NewTransport(zhttp.TransportConfig{CertFile: certFile}); err == nil {
+		t.Fatal("expected an error without key file")
+	}
+	if _, err = cfg.Transports.Transport("unknown"); err == nil {
+		t.Fatal("expected an error for an unknown profile")
+	}
+}
+
+func TestTransportConfig_Proxy(t *testing.T) {
+	tr, err := zhttp.NewTransport(zhttp.TransportConfig{Proxy: "socks5://127.0.0.1:1080", DisableHTTP2: zhttp.Bool(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	proxy, err := tr.Proxy(req)
+	if err != nil || proxy.String() != "socks5://127.0.0.1:1080" {
+		t.Fatalf("proxy %v, %v", proxy, err)
+	}
+	if tr.TLSNextProto == nil || tr.ForceAttemptHTTP2 {
+		t.Fatal("HTTP/2 not disabled")
+	}
+}
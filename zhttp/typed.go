@@ -0,0 +1,217 @@
+package zhttp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// MaxErrorBody bounds the body kept by StatusError.
+var MaxErrorBody = 4 << 10
+
+// StatusError is returned by the typed helpers for non 2xx responses.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body holds at most MaxErrorBody bytes of the response body.
+	Body      []byte
+	Truncated bool
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("zhttp: %s %s: %s", e.Method, e.URL, e.Status)
+	if len(e.Body) > 0 {
+		msg += ": " + string(e.Body)
+		if e.Truncated {
+			msg += "..."
+		}
+	}
+	return msg
+}
+
+// newStatusError reads at most MaxErrorBody bytes of the body of resp.
+func newStatusError(method string, u *url.URL, resp *http.Response) *StatusError {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, int64(MaxErrorBody)+1))
+	e := &StatusError{
+		Method:     method,
+		URL:        u.Redacted(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       raw,
+	}
+	if len(raw) > MaxErrorBody {
+		e.Body, e.Truncated = raw[:MaxErrorBody], true
+	}
+	return e
+}
+
+type callOptions struct {
+	request Request
+	codec   Codec
+	header  http.Header
+	query   url.Values
+}
+
+type CallOption func(o *callOptions)
+
+// WithRequest sends the calls with r, e.g. one built with retries, rather
+// than with a plain NewRequest().
+func WithRequest(r Request) CallOption {
+	return func(o *callOptions) {
+		o.request = r
+	}
+}
+
+// WithCodec encodes the request body with c and decodes the response with
+// it when its Content-Type has no registered codec.
+func WithCodec(c Codec) CallOption {
+	return func(o *callOptions) {
+		o.codec = c
+	}
+}
+
+func WithHeader(key, value string) CallOption {
+	return func(o *callOptions) {
+		o.header.Add(key, value)
+	}
+}
+
+func WithQuery(key, value string) CallOption {
+	return func(o *callOptions) {
+		o.query.Add(key, value)
+	}
+}
+
+var defaultRequest = NewRequest()
+
+// Call sends body encoded by the codec, JSON by default, and decodes the
+// response into a Resp. A nil body, e.g. a nil *T, sends no body. A Resp
+// of []byte or string receives the raw body.
+func Call[Resp any](ctx context.Context, method, rawURL string, body any, opts ...CallOption) (Resp, error) {
+	var out Resp
+	o := callOptions{request: defaultRequest, codec: JSONCodec, header: make(http.Header), query: make(url.Values)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return out, err
+	}
+	if len(o.query) > 0 {
+		q := u.Query()
+		for k, vs := range o.query {
+			q[k] = append(q[k], vs...)
+		}
+		u.RawQuery = q.Encode()
+	}
+	var reader io.Reader
+	hasBody := !isNil(body)
+	if hasBody {
+		raw, err := o.codec.Marshal(body)
+		if err != nil {
+			return out, fmt.Errorf("zhttp: encode %s %s: %w", method, u.Redacted(), err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return out, err
+	}
+	if hasBody {
+		req.Header.Set("Content-Type", o.codec.ContentType())
+	}
+	if o.codec == FormCodec {
+		req.Header.Set("Accept", JSONCodec.ContentType())
+	} else {
+		req.Header.Set("Accept", o.codec.ContentType())
+	}
+	for k, vs := range o.header {
+		req.Header[k] = vs
+	}
+
+	resp, err := o.request.ContextDo(ctx, req)
+	if err != nil {
+		return out, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return out, newStatusError(method, u, resp)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return out, err
+	}
+	switch v := any(&out).(type) {
+	case *[]byte:
+		*v = raw
+		return out, nil
+	case *string:
+		*v = string(raw)
+		return out, nil
+	}
+	if len(raw) == 0 {
+		return out, nil
+	}
+	c, ok := CodecFor(resp.Header.Get("Content-Type"))
+	if !ok {
+		c = o.codec
+	}
+	if err = c.Unmarshal(raw, &out); err != nil {
+		return out, fmt.Errorf("zhttp: decode %s %s: %w", method, u.Redacted(), err)
+	}
+	return out, nil
+}
+
+// isNil reports whether body is nil or a nil pointer, map or slice, such as
+// the (*T)(nil) of a Req, which are sent without a body rather than as null.
+func isNil(body any) bool {
+	if body == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(body); v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
+func Get[Resp any](ctx context.Context, rawURL string, opts ...CallOption) (Resp, error) {
+	return Call[Resp](ctx, http.MethodGet, rawURL, nil, opts...)
+}
+
+func Delete[Resp any](ctx context.Context, rawURL string, opts ...CallOption) (Resp, error) {
+	return Call[Resp](ctx, http.MethodDelete, rawURL, nil, opts...)
+}
+
+func PostJSON[Req, Resp any](ctx context.Context, rawURL string, body Req, opts ...CallOption) (Resp, error) {
+	return Call[Resp](ctx, http.MethodPost, rawURL, body, append([]CallOption{WithCodec(JSONCodec)}, opts...)...)
+}
+
+func PutJSON[Req, Resp any](ctx context.Context, rawURL string, body Req, opts ...CallOption) (Resp, error) {
+	return Call[Resp](ctx, http.MethodPut, rawURL, body, append([]CallOption{WithCodec(JSONCodec)}, opts...)...)
+}
+
+func PatchJSON[Req, Resp any](ctx context.Context, rawURL string, body Req, opts ...CallOption) (Resp, error) {
+	return Call[Resp](ctx, http.MethodPatch, rawURL, body, append([]CallOption{WithCodec(JSONCodec)}, opts...)...)
+}
+
+func PostXML[Req, Resp any](ctx context.Context, rawURL string, body Req, opts ...CallOption) (Resp, error) {
+	return Call[Resp](ctx, http.MethodPost, rawURL, body, append([]CallOption{WithCodec(XMLCodec)}, opts...)...)
+}
+
+// PostForm posts form, url.Values, a map of strings or a struct, url
+// encoded.
+func PostForm[Resp any](ctx context.Context, rawURL string, form any, opts ...CallOption) (Resp, error) {
+	return Call[Resp](ctx, http.MethodPost, rawURL, form, append([]CallOption{WithCodec(FormCodec)}, opts...)...)
+}
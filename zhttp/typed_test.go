@@ -0,0 +1,115 @@
+package zhttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/uaxe/infra/zhttp"
+)
+
+type user struct {
+	XMLName xml.Name `json:"-" xml:"user"`
+	Name    string   `json:"name" xml:"name" form:"name"`
+	Age     int      `json:"age" xml:"age" form:"age"`
+}
+
+func TestTypedHelpers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			if r.URL.Query().Get("id") != "7" || r.Header.Get("X-Token") != "t" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = w.Write([]byte(`{"name":"zkep","age":18}`))
+		case "/json":
+			var u user
+			_ = json.NewDecoder(r.Body).Decode(&u)
+			u.Age++
+			w.Header().Set("Content-Type", "application/problem+json")
+			_ = json.NewEncoder(w).Encode(u)
+		case "/xml":
+			var u user
+			_ = xml.NewDecoder(r.Body).Decode(&u)
+			u.Age++
+			w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+			_ = xml.NewEncoder(w).Encode(u)
+		case "/form":
+			_ = r.ParseForm()
+			w.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+			_, _ = w.Write([]byte(url.Values{"name": {r.PostForm.Get("name") + "!"}, "age": {"19"}}.Encode()))
+		case "/echo":
+			raw, _ := io.ReadAll(r.Body)
+			_, _ = w.Write([]byte(r.Header.Get("Content-Type") + "|" + string(raw)))
+		default:
+			w.Header().Set("X-Reason", "missing")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(strings.Repeat("x", 5000)))
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	got, err := zhttp.Get[user](ctx, srv.URL+"/user", zhttp.WithQuery("id", "7"), zhttp.WithHeader("X-Token", "t"))
+	if err != nil || got.Name != "zkep" || got.Age != 18 {
+		t.Fatalf("Get: %+v, %v", got, err)
+	}
+
+	got, err = zhttp.PostJSON[user, user](ctx, srv.URL+"/json", user{Name: "a", Age: 1})
+	if err != nil || got.Name != "a" || got.Age != 2 {
+		t.Fatalf("PostJSON: %+v, %v", got, err)
+	}
+
+	got, err = zhttp.PostXML[user, user](ctx, srv.URL+"/xml", user{Name: "b", Age: 2})
+	if err != nil || got.Name != "b" || got.Age != 3 {
+		t.Fatalf("PostXML: %+v, %v", got, err)
+	}
+
+	got, err = zhttp.PostForm[user](ctx, srv.URL+"/form", user{Name: "c", Age: 3})
+	if err != nil || got.Name != "c!" || got.Age != 19 {
+		t.Fatalf("PostForm: %+v, %v", got, err)
+	}
+
+	raw, err := zhttp.Get[string](ctx, srv.URL+"/user", zhttp.WithQuery("id", "7"), zhttp.WithHeader("X-Token", "t"))
+	if err != nil || !strings.Contains(raw, "zkep") {
+		t.Fatalf("Get[string]: %q, %v", raw, err)
+	}
+
+	raw, err = zhttp.PostJSON[*user, string](ctx, srv.URL+"/echo", nil)
+	if err != nil || raw != "|" {
+		t.Fatalf("a nil *user should be sent without a body, got %q, %v", raw, err)
+	}
+
+	secret := strings.Replace(srv.URL, "http://", "http://user:secret@", 1)
+	_, err = zhttp.PostJSON[chan int, string](ctx, secret+"/echo", make(chan int))
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Fatalf("expected an encode error without the password, got %v", err)
+	}
+
+	_, err = zhttp.Get[user](ctx, srv.URL+"/missing")
+	var se *zhttp.StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound || se.Header.Get("X-Reason") != "missing" ||
+		len(se.Body) != zhttp.MaxErrorBody || !se.Truncated {
+		t.Fatalf("expected a truncated StatusError, got %v", err)
+	}
+}
+
+func TestParseBody_MediaType(t *testing.T) {
+	resp := &http.Response{
+		Header: http.Header{"Content-Type": {"application/json; charset=utf-8"}},
+		Body:   io.NopCloser(strings.NewReader(`{"name":"zkep"}`)),
+	}
+	var v Ret
+	if err := zhttp.DefaultRespParse.ParseBody(resp, &v); err != nil || v.Name != "zkep" {
+		t.Fatalf("%+v, %v", v, err)
+	}
+}
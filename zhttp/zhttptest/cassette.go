@@ -0,0 +1,91 @@
+// Package zhttptest provides transports for testing HTTP clients: a
+// Recorder replaying exchanges recorded in cassette files, and a programmable
+// MockTransport.
+package zhttptest
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"unicode/utf8"
+
+	"github.com/uaxe/infra/zconf"
+)
+
+// Cassette is the yaml file of the exchanges recorded by a Recorder.
+type Cassette struct {
+	Interactions []*Interaction `yaml:"interactions"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `yaml:"request"`
+	Response RecordedResponse `yaml:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `yaml:"method"`
+	URL    string      `yaml:"url"`
+	Header http.Header `yaml:"header,omitempty"`
+	Body   Body        `yaml:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `yaml:"statusCode"`
+	Status     string      `yaml:"status"`
+	Header     http.Header `yaml:"header,omitempty"`
+	Body       Body        `yaml:"body,omitempty"`
+}
+
+// Body is a recorded body, kept as text when it is valid UTF-8 and base64
+// encoded otherwise.
+type Body struct {
+	Text   string `yaml:"text,omitempty"`
+	Base64 string `yaml:"base64,omitempty"`
+}
+
+func NewBody(raw []byte) Body {
+	if utf8.Valid(raw) {
+		return Body{Text: string(raw)}
+	}
+	return Body{Base64: base64.StdEncoding.EncodeToString(raw)}
+}
+
+func (b Body) Bytes() []byte {
+	if b.Base64 != "" {
+		raw, err := base64.StdEncoding.DecodeString(b.Base64)
+		if err == nil {
+			return raw
+		}
+	}
+	return []byte(b.Text)
+}
+
+// LoadCassette reads the cassette at path, an empty one if it does not
+// exist.
+func LoadCassette(path string) (*Cassette, error) {
+	c := &Cassette{}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// decoded without zconf.Load, whose placeholders would be resolved in
+	// the recorded bodies
+	dirver, ok := zconf.LookupDriver(zconf.YamlName)
+	if !ok {
+		return nil, errors.New("zhttptest: no yaml driver")
+	}
+	if err = dirver.Unmarshal(raw, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Save writes the cassette to path.
+func (c *Cassette) Save(path string) error {
+	return zconf.Save(path, c)
+}
@@ -0,0 +1,226 @@
+package zhttptest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var ErrUnexpectedRequest = errors.New("zhttptest: unexpected request")
+
+// MockTransport is a RoundTripper answering the requests matching its
+// expectations:
+//
+//	mock := zhttptest.NewMockTransport()
+//	mock.On(http.MethodGet, "/users/*").ReplyJSON(http.StatusOK, user).Times(2)
+//	client := zhttp.NewRequest(zhttp.WithTransport(mock))
+//	...
+//	mock.AssertExpectations(t)
+type MockTransport struct {
+	lock         sync.Mutex
+	expectations []*Expectation
+	unexpected   []string
+}
+
+var _ http.RoundTripper = (*MockTransport)(nil)
+
+func NewMockTransport() *MockTransport {
+	return &MockTransport{}
+}
+
+// On expects requests of method, any if empty, whose path, or whole URL if
+// pattern has a scheme, matches pattern as path.Match does.
+func (m *MockTransport) On(method, pattern string) *Expectation {
+	e := &Expectation{method: method, pattern: pattern, status: http.StatusOK, header: make(http.Header)}
+	m.lock.Lock()
+	m.expectations = append(m.expectations, e)
+	m.lock.Unlock()
+	return e
+}
+
+func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	m.lock.Lock()
+	var found *Expectation
+	for _, e := range m.expectations {
+		if e.exhausted() || !e.matches(req, body) {
+			continue
+		}
+		found = e
+		e.calls++
+		break
+	}
+	if found == nil {
+		m.unexpected = append(m.unexpected, req.Method+" "+req.URL.String())
+	}
+	m.lock.Unlock()
+
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s %s", ErrUnexpectedRequest, req.Method, req.URL)
+	}
+	return found.respond(withBody(req, body))
+}
+
+// AssertExpectations fails t for every expectation not called as many
+// times as expected, and for every unexpected request.
+func (m *MockTransport) AssertExpectations(t testing.TB) {
+	t.Helper()
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, e := range m.expectations {
+		switch {
+		case e.times > 0 && e.calls != e.times:
+			t.Errorf("zhttptest: %s %s called %d times, expected %d", e.method, e.pattern, e.calls, e.times)
+		case e.times == 0 && e.calls == 0:
+			t.Errorf("zhttptest: %s %s not called", e.method, e.pattern)
+		}
+	}
+	for _, req := range m.unexpected {
+		t.Errorf("zhttptest: unexpected request %s", req)
+	}
+}
+
+// Expectation describes the requests a MockTransport expects and how it
+// answers them. Its methods return it to be chained.
+type Expectation struct {
+	method  string
+	pattern string
+	header  http.Header
+	body    func([]byte) bool
+
+	status  int
+	reply   []byte
+	rheader http.Header
+	err     error
+	fn      func(*http.Request) (*http.Response, error)
+
+	times int
+	calls int
+}
+
+// WithHeader only matches requests carrying the header value.
+func (e *Expectation) WithHeader(key, value string) *Expectation {
+	e.header.Add(key, value)
+	return e
+}
+
+// WithBody only matches requests whose body satisfies fn.
+func (e *Expectation) WithBody(fn func(body []byte) bool) *Expectation {
+	e.body = fn
+	return e
+}
+
+// WithJSONBody only matches requests whose JSON body equals v.
+func (e *Expectation) WithJSONBody(v any) *Expectation {
+	want, err := json.Marshal(v)
+	return e.WithBody(func(body []byte) bool {
+		var got, expected any
+		return err == nil && json.Unmarshal(body, &got) == nil && json.Unmarshal(want, &expected) == nil &&
+			reflect.DeepEqual(got, expected)
+	})
+}
+
+func (e *Expectation) Reply(status int, body string) *Expectation {
+	e.status, e.reply = status, []byte(body)
+	return e
+}
+
+func (e *Expectation) ReplyJSON(status int, v any) *Expectation {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		e.err = err
+		return e
+	}
+	e.status, e.reply = status, raw
+	return e.ReplyHeader("Content-Type", "application/json")
+}
+
+func (e *Expectation) ReplyHeader(key, value string) *Expectation {
+	if e.rheader == nil {
+		e.rheader = make(http.Header)
+	}
+	e.rheader.Add(key, value)
+	return e
+}
+
+// ReplyError fails the matching requests with err, e.g. to simulate a
+// network error.
+func (e *Expectation) ReplyError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+// ReplyFunc answers the matching requests with fn.
+func (e *Expectation) ReplyFunc(fn func(*http.Request) (*http.Response, error)) *Expectation {
+	e.fn = fn
+	return e
+}
+
+// Times expects exactly n calls, further requests are not matched. Without
+// Times an expectation matches any number of requests, at least one.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+func (e *Expectation) Once() *Expectation {
+	return e.Times(1)
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.times > 0 && e.calls >= e.times
+}
+
+func (e *Expectation) matches(req *http.Request, body []byte) bool {
+	if e.method != "" && !strings.EqualFold(e.method, req.Method) {
+		return false
+	}
+	target := req.URL.Path
+	if strings.Contains(e.pattern, "://") {
+		target = req.URL.String()
+	}
+	if ok, err := path.Match(e.pattern, target); err != nil || !ok {
+		return false
+	}
+	for k := range e.header {
+		if req.Header.Get(k) != e.header.Get(k) {
+			return false
+		}
+	}
+	return e.body == nil || e.body(body)
+}
+
+func (e *Expectation) respond(req *http.Request) (*http.Response, error) {
+	if e.fn != nil {
+		return e.fn(req)
+	}
+	if e.err != nil {
+		return nil, e.err
+	}
+	header := e.rheader.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.reply)),
+		ContentLength: int64(len(e.reply)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,343 @@
+package zhttptest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/uaxe/infra/zhttp"
+)
+
+var ErrInteractionNotFound = errors.New("zhttptest: no recorded interaction matches the request")
+
+const Redacted = "REDACTED"
+
+// DefaultRedactedHeaders are replaced by Redacted in cassettes.
+var DefaultRedactedHeaders = []string{
+	"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "X-Auth-Token",
+}
+
+// DefaultRedactedQuery are the query parameters, matched case-insensitively,
+// replaced by Redacted in the URLs of cassettes.
+var DefaultRedactedQuery = []string{
+	"api_key", "apikey", "key", "access_token", "token", "secret", "password", "signature",
+}
+
+type Mode int
+
+const (
+	// ModeReplayOrRecord replays the recorded interactions and records the
+	// requests matching none.
+	ModeReplayOrRecord Mode = iota
+	// ModeReplay only replays, unmatched requests fail with
+	// ErrInteractionNotFound without reaching the network.
+	ModeReplay
+	// ModeRecord sends every request and records it, replacing the cassette.
+	ModeRecord
+)
+
+// Matcher reports whether a request, whose body is body, matches a recorded
+// one. The query parameters of the request are redacted as recorded.
+type Matcher func(req *http.Request, body []byte, recorded *RecordedRequest) bool
+
+func MatchMethod(req *http.Request, _ []byte, recorded *RecordedRequest) bool {
+	return req.Method == recorded.Method
+}
+
+func MatchURL(req *http.Request, _ []byte, recorded *RecordedRequest) bool {
+	return req.URL.String() == recorded.URL
+}
+
+// MatchPath ignores the query and the host, e.g. for requests carrying a
+// timestamp.
+func MatchPath(req *http.Request, _ []byte, recorded *RecordedRequest) bool {
+	u, err := url.Parse(recorded.URL)
+	return err == nil && u.Path == req.URL.Path
+}
+
+func MatchBody(_ *http.Request, body []byte, recorded *RecordedRequest) bool {
+	return bytes.Equal(body, recorded.Body.Bytes())
+}
+
+// MatchHeaders compares the values of the named headers.
+func MatchHeaders(names ...string) Matcher {
+	return func(req *http.Request, _ []byte, recorded *RecordedRequest) bool {
+		for _, name := range names {
+			if req.Header.Get(name) != recorded.Header.Get(name) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAll matches when every matcher does.
+func MatchAll(matchers ...Matcher) Matcher {
+	return func(req *http.Request, body []byte, recorded *RecordedRequest) bool {
+		for _, m := range matchers {
+			if !m(req, body, recorded) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// DefaultMatcher matches the method and the URL.
+var DefaultMatcher = MatchAll(MatchMethod, MatchURL)
+
+type RecorderOption func(r *Recorder)
+
+func WithMode(mode Mode) RecorderOption {
+	return func(r *Recorder) {
+		r.mode = mode
+	}
+}
+
+// WithRealTransport sets the transport of recorded requests,
+// zhttp.DefaultClient's by default.
+func WithRealTransport(rt http.RoundTripper) RecorderOption {
+	return func(r *Recorder) {
+		r.real = rt
+	}
+}
+
+func WithMatcher(m Matcher) RecorderOption {
+	return func(r *Recorder) {
+		r.matcher = m
+	}
+}
+
+// WithRedactHeaders adds headers to DefaultRedactedHeaders.
+func WithRedactHeaders(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.redact = append(r.redact, names...)
+	}
+}
+
+// WithRedactQuery adds query parameters to DefaultRedactedQuery.
+func WithRedactQuery(names ...string) RecorderOption {
+	return func(r *Recorder) {
+		r.redactQuery = append(r.redactQuery, names...)
+	}
+}
+
+// Recorder is a RoundTripper replaying the interactions of a cassette and
+// recording the new ones, so that tests against external APIs run offline:
+//
+//	rec, err := zhttptest.NewRecorder("testdata/github.yaml")
+//	defer rec.Stop()
+//	client := zhttp.NewRequest(zhttp.WithTransport(rec))
+type Recorder struct {
+	path        string
+	mode        Mode
+	real        http.RoundTripper
+	matcher     Matcher
+	redact      []string
+	redactQuery []string
+
+	lock     sync.Mutex
+	cassette *Cassette
+	used     map[*Interaction]bool
+	recorded bool
+}
+
+var _ http.RoundTripper = (*Recorder)(nil)
+
+// NewRecorder loads the cassette at path, a .yaml file.
+func NewRecorder(path string, opts ...RecorderOption) (*Recorder, error) {
+	r := &Recorder{
+		path:        path,
+		real:        zhttp.DefaultClient.Transport,
+		matcher:     DefaultMatcher,
+		redact:      append([]string(nil), DefaultRedactedHeaders...),
+		redactQuery: append([]string(nil), DefaultRedactedQuery...),
+		used:        make(map[*Interaction]bool),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.mode == ModeRecord {
+		r.cassette = &Cassette{}
+		return r, nil
+	}
+	var err error
+	if r.cassette, err = LoadCassette(path); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode != ModeRecord {
+		if it := r.match(r.redactedRequest(req), body); it != nil {
+			return replay(req, it), nil
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+		}
+	}
+	return r.record(req, body)
+}
+
+// Stop saves the cassette if interactions were recorded.
+func (r *Recorder) Stop() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.recorded {
+		return nil
+	}
+	r.recorded = false
+	return r.cassette.Save(r.path)
+}
+
+// match returns the first unused matching interaction, or the last used one
+// so that repeated requests replay their last answer.
+func (r *Recorder) match(req *http.Request, body []byte) *Interaction {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var last *Interaction
+	for _, it := range r.cassette.Interactions {
+		if !r.matcher(req, body, &it.Request) {
+			continue
+		}
+		if !r.used[it] {
+			r.used[it] = true
+			return it
+		}
+		last = it
+	}
+	return last
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.real.RoundTrip(withBody(req.Clone(req.Context()), body))
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+
+	it := &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    r.redactedURL(req.URL).String(),
+			Header: r.redacted(req.Header),
+			Body:   NewBody(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     r.redacted(resp.Header),
+			Body:       NewBody(raw),
+		},
+	}
+	r.lock.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, it)
+	r.used[it] = true
+	r.recorded = true
+	r.lock.Unlock()
+	return resp, nil
+}
+
+func (r *Recorder) redacted(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range r.redact {
+		if _, ok := out[http.CanonicalHeaderKey(name)]; ok {
+			out.Set(name, Redacted)
+		}
+	}
+	return out
+}
+
+// redactedRequest returns a shallow copy of req with its URL redacted, to be
+// matched against the recorded requests.
+func (r *Recorder) redactedRequest(req *http.Request) *http.Request {
+	out := req.WithContext(req.Context())
+	out.URL = r.redactedURL(req.URL)
+	return out
+}
+
+func (r *Recorder) redactedURL(u *url.URL) *url.URL {
+	out := *u
+	if u.RawQuery == "" {
+		return &out
+	}
+	query := u.Query()
+	for name := range query {
+		for _, redacted := range r.redactQuery {
+			if strings.EqualFold(name, redacted) {
+				query[name] = []string{Redacted}
+				break
+			}
+		}
+	}
+	out.RawQuery = query.Encode()
+	return &out
+}
+
+func replay(req *http.Request, it *Interaction) *http.Response {
+	raw := it.Response.Body.Bytes()
+	status := it.Response.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", it.Response.StatusCode, http.StatusText(it.Response.StatusCode))
+	}
+	header := it.Response.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        status,
+		StatusCode:    it.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(raw)),
+		ContentLength: int64(len(raw)),
+		Request:       req,
+	}
+}
+
+// readBody reads the body of req, from a copy when req has GetBody, and
+// closes it as a RoundTripper must. req itself is left untouched.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer func() { _ = req.Body.Close() }()
+	body := req.Body
+	if req.GetBody != nil {
+		rc, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = rc.Close() }()
+		body = rc
+	}
+	return io.ReadAll(body)
+}
+
+// withBody returns a copy of req whose body reads raw, for the handlers of
+// a request whose body was read by readBody.
+func withBody(req *http.Request, raw []byte) *http.Request {
+	if raw == nil {
+		return req
+	}
+	out := req.WithContext(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(raw))
+	return out
+}
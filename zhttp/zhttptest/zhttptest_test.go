@@ -0,0 +1,133 @@
+package zhttptest_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/uaxe/infra/zhttp"
+	"github.com/uaxe/infra/zhttp/zhttptest"
+)
+
+type greeting struct {
+	Message string `json:"message"`
+}
+
+func TestRecorder(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		_, _ = w.Write([]byte(`{"message":"hello ` + r.URL.Query().Get("name") + `"}`))
+	}))
+	defer srv.Close()
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+	ctx := context.Background()
+
+	rec, err := zhttptest.NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := zhttp.WithRequest(zhttp.NewRequest(zhttp.WithTransport(rec)))
+	got, err := zhttp.Get[greeting](ctx, srv.URL+"/greet?name=a&api_key=secret", client, zhttp.WithHeader("Authorization", "Bearer secret"))
+	if err != nil || got.Message != "hello a" {
+		t.Fatalf("%+v, %v", got, err)
+	}
+	if err = rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "secret") || !strings.Contains(string(raw), zhttptest.Redacted) {
+		t.Fatalf("sensitive headers and query not redacted:\n%s", raw)
+	}
+
+	// replayed offline
+	srv.Close()
+	rec, err = zhttptest.NewRecorder(path, zhttptest.WithMode(zhttptest.ModeReplay))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = zhttp.WithRequest(zhttp.NewRequest(zhttp.WithTransport(rec)))
+	got, err = zhttp.Get[greeting](ctx, srv.URL+"/greet?name=a&api_key=other", client)
+	if err != nil || got.Message != "hello a" || hits.Load() != 1 {
+		t.Fatalf("%+v, %v, %d hits", got, err, hits.Load())
+	}
+	if _, err = zhttp.Get[greeting](ctx, srv.URL+"/greet?name=b", client); !errors.Is(err, zhttptest.ErrInteractionNotFound) {
+		t.Fatalf("expected ErrInteractionNotFound, got %v", err)
+	}
+
+	rec, err = zhttptest.NewRecorder(path, zhttptest.WithMode(zhttptest.ModeReplay),
+		zhttptest.WithMatcher(zhttptest.MatchAll(zhttptest.MatchMethod, zhttptest.MatchPath)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = zhttp.WithRequest(zhttp.NewRequest(zhttp.WithTransport(rec)))
+	if got, err = zhttp.Get[greeting](ctx, srv.URL+"/greet?name=b", client); err != nil || got.Message != "hello a" {
+		t.Fatalf("%+v, %v", got, err)
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestMockTransport(t *testing.T) {
+	mock := zhttptest.NewMockTransport()
+	mock.On(http.MethodGet, "/users/*").ReplyJSON(http.StatusOK, greeting{Message: "user"}).Times(2)
+	mock.On(http.MethodPost, "/users").
+		WithHeader("X-Token", "t").
+		WithJSONBody(map[string]string{"name": "a"}).
+		Reply(http.StatusCreated, "").Once()
+	mock.On("", "/down").ReplyError(errors.New("connection refused"))
+
+	ctx := context.Background()
+	client := zhttp.WithRequest(zhttp.NewRequest(zhttp.WithTransport(mock)))
+	for i := 0; i < 2; i++ {
+		got, err := zhttp.Get[greeting](ctx, "http://api.test/users/1", client)
+		if err != nil || got.Message != "user" {
+			t.Fatalf("%+v, %v", got, err)
+		}
+	}
+	if _, err := zhttp.PostJSON[map[string]string, string](ctx, "http://api.test/users",
+		map[string]string{"name": "a"}, client, zhttp.WithHeader("X-Token", "t")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zhttp.Get[string](ctx, "http://api.test/down", client); err == nil {
+		t.Fatal("expected the error of the expectation")
+	}
+	mock.AssertExpectations(t)
+
+	// the request is left untouched, its body only closed
+	body := &closeRecorder{Reader: strings.NewReader(`{"name":"a"}`)}
+	req, _ := http.NewRequest(http.MethodPost, "http://api.test/users", nil)
+	req.Body = body
+	req.Header.Set("X-Token", "t")
+	if _, err := mock.RoundTrip(req); !errors.Is(err, zhttptest.ErrUnexpectedRequest) {
+		t.Fatalf("expected ErrUnexpectedRequest, got %v", err)
+	}
+	if req.Body != body || !body.closed {
+		t.Fatal("request body replaced or not closed")
+	}
+
+	// the third call exceeds Times(2)
+	if _, err := zhttp.Get[greeting](ctx, "http://api.test/users/1", client); !errors.Is(err, zhttptest.ErrUnexpectedRequest) {
+		t.Fatalf("expected ErrUnexpectedRequest, got %v", err)
+	}
+}
@@ -1,10 +1,13 @@
 package zreflect
 
 import (
+	"encoding"
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Struct2MapOption func(v reflect.Value, f reflect.StructField) (string, bool)
@@ -93,13 +96,108 @@ func MapBindStruct(src map[string]any, dst any, tag string) error {
 		}
 		if j, ok := yt.Field(i).Tag.Lookup(tag); ok && j != "-" {
 			if field, exists := src[strings.ToLower(j)]; exists {
-				yv.FieldByName(yt.Field(i).Name).Set(reflect.ValueOf(field))
+				if err := setField(yv.Field(i), field); err != nil {
+					return fmt.Errorf("field %s: %w", yt.Field(i).Name, err)
+				}
 			}
 		}
 	}
 	return nil
 }
 
+// setField sets v to x, parsing x when it is a string, or a []string, of
+// another type than v.
+func setField(v reflect.Value, x any) error {
+	xv := reflect.ValueOf(x)
+	if xv.IsValid() && xv.Type().AssignableTo(v.Type()) {
+		v.Set(xv)
+		return nil
+	}
+	switch s := x.(type) {
+	case string:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+			return setField(v, []string{s})
+		}
+		return SetString(v, s)
+	case []string:
+		if v.Kind() != reflect.Slice {
+			if len(s) == 0 {
+				return nil
+			}
+			return SetString(v, s[0])
+		}
+		slice := reflect.MakeSlice(v.Type(), len(s), len(s))
+		for i := range s {
+			if err := SetString(slice.Index(i), s[i]); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return fmt.Errorf("cannot set %T to %s", x, v.Type())
+}
+
+// SetString parses s into v according to its type: encoding.TextUnmarshaler,
+// string, bool, numbers, time.Duration or a pointer to one of them.
+func SetString(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return SetString(v.Elem(), s)
+	}
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == reflect.TypeOf(time.Duration(0)) {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.Set(reflect.ValueOf(s))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
 func MergeStruct(src, dst any, opts ...MergeStructOption) error {
 	xt, xv := TypeAndValue(src)
 	yt, yv := TypeAndValue(dst)